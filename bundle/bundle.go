@@ -0,0 +1,149 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"ampho.xyz/core/content"
+)
+
+// Format is a bundle serialisation format.
+type Format string
+
+const (
+	FormatJSON     Format = "json"     // single JSON file
+	FormatYAML     Format = "yaml"     // single YAML file
+	FormatMarkdown Format = "markdown" // directory of Markdown files with front matter
+)
+
+// FormatOf returns a format by a file name extension. A path without an extension is a Markdown directory.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case "":
+		return FormatMarkdown, nil
+	}
+
+	return "", fmt.Errorf("unknown bundle format of %s", path)
+}
+
+// Bundle is a set of exported content.
+type Bundle struct {
+	Version int             `json:"version"`
+	Types   []*content.Type `json:"types,omitempty"`
+	Items   []*Entry        `json:"items"`
+}
+
+// Entry is an exported content item.
+type Entry struct {
+	UUID        string                 `json:"uuid"`
+	Type        string                 `json:"type"`
+	Status      content.Status         `json:"status,omitempty"`
+	Revision    int                    `json:"revision,omitempty"`
+	CreatedAt   *time.Time             `json:"createdAt,omitempty"`
+	UpdatedAt   *time.Time             `json:"updatedAt,omitempty"`
+	PublishAt   *time.Time             `json:"publishAt,omitempty"`
+	ExpireAt    *time.Time             `json:"expireAt,omitempty"`
+	PublishedAt *time.Time             `json:"publishedAt,omitempty"`
+	Permalink   string                 `json:"permalink,omitempty"`
+	Terms       map[string][][]string  `json:"terms,omitempty"` // term names from the root by vocabularies
+	Media       []string               `json:"media,omitempty"` // UUIDs of referenced media, informational
+	Data        map[string]interface{} `json:"data"`
+	Revisions   []*Revision            `json:"revisions,omitempty"`
+}
+
+// Revision is an exported content item revision.
+type Revision struct {
+	Number    int                    `json:"number"`
+	CreatedAt *time.Time             `json:"createdAt,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Encode writes a bundle in JSON or YAML format. YAML keys are the same as JSON ones.
+func Encode(w io.Writer, b *Bundle, f Format) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(b)
+	case FormatYAML:
+		v, err := toPlain(b)
+		if err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err = enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	return fmt.Errorf("format %s cannot be written to a single file", f)
+}
+
+// Decode reads a bundle in JSON or YAML format.
+func Decode(r io.Reader, f Format) (*Bundle, error) {
+	b := &Bundle{}
+
+	switch f {
+	case FormatJSON:
+		if err := json.NewDecoder(r).Decode(b); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		var v interface{}
+		if err := yaml.NewDecoder(r).Decode(&v); err != nil {
+			return nil, err
+		}
+		if err := fromPlain(v, b); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("format %s cannot be read from a single file", f)
+	}
+
+	if b.Version > Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+
+	return b, nil
+}
+
+// toPlain converts a value to maps, slices and scalars using its JSON representation.
+func toPlain(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var r interface{}
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// fromPlain converts maps, slices and scalars to a value using its JSON representation.
+func fromPlain(v interface{}, dst interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,126 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package bundle_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/bundle"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+)
+
+func testBundle() *bundle.Bundle {
+	created := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	return &bundle.Bundle{
+		Version: bundle.Version,
+		Types: []*content.Type{{Name: "article", Title: "Article", Fields: []content.Field{
+			{Name: "title", Type: content.FieldString, Required: true},
+			{Name: "body", Type: content.FieldRichText},
+		}}},
+		Items: []*bundle.Entry{{
+			UUID:      "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f",
+			Type:      "article",
+			Status:    content.StatusPublished,
+			Revision:  2,
+			CreatedAt: &created,
+			Permalink: "/news/hello",
+			Terms:     map[string][][]string{"category": {{"news", "world"}}},
+			Data:      map[string]interface{}{"title": "Hello", "body": "# Hello\n\nWorld\n"},
+			Revisions: []*bundle.Revision{
+				{Number: 1, Data: map[string]interface{}{"title": "Draft"}},
+				{Number: 2, Data: map[string]interface{}{"title": "Hello", "body": "# Hello\n\nWorld\n"}},
+			},
+		}},
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, f := range map[string]bundle.Format{
+		"export.json": bundle.FormatJSON,
+		"export.YAML": bundle.FormatYAML,
+		"export.yml":  bundle.FormatYAML,
+		"export":      bundle.FormatMarkdown,
+	} {
+		got, err := bundle.FormatOf(path)
+		require.NoError(t, err)
+		require.Equal(t, f, got, path)
+	}
+
+	_, err := bundle.FormatOf("export.zip")
+	require.Error(t, err)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, f := range []bundle.Format{bundle.FormatJSON, bundle.FormatYAML} {
+		var buf bytes.Buffer
+		require.NoError(t, bundle.Encode(&buf, testBundle(), f))
+
+		b, err := bundle.Decode(&buf, f)
+		require.NoError(t, err)
+		require.Equal(t, testBundle(), b, f)
+	}
+
+	_, err := bundle.Decode(bytes.NewBufferString(`{"version": 99, "items": []}`), bundle.FormatJSON)
+	require.Error(t, err)
+
+	require.Error(t, bundle.Encode(&bytes.Buffer{}, testBundle(), bundle.FormatMarkdown))
+}
+
+func TestMarkdown(t *testing.T) {
+	e := testBundle().Items[0]
+
+	out, err := bundle.MarshalMarkdown(e, "body")
+	require.NoError(t, err)
+	require.Contains(t, string(out), "---\n# Hello\n\nWorld\n")
+
+	got, err := bundle.UnmarshalMarkdown(out, "body")
+	require.NoError(t, err)
+	require.Equal(t, e, got)
+
+	got, err = bundle.UnmarshalMarkdown([]byte("---\ntype: page\ntitle: About\n---\nAbout us\n"), "body")
+	require.NoError(t, err)
+	require.Equal(t, "page", got.Type)
+	require.Equal(t, map[string]interface{}{"title": "About", "body": "About us\n"}, got.Data)
+
+	_, err = bundle.UnmarshalMarkdown([]byte("About us\n"), "body")
+	require.ErrorIs(t, err, bundle.ErrNoFrontMatter)
+}
+
+func TestPathUUID(t *testing.T) {
+	u := bundle.PathUUID("page/about")
+	require.True(t, database.IsUUID(u))
+	require.Equal(t, u, bundle.PathUUID("page/about"))
+	require.NotEqual(t, u, bundle.PathUUID("page/contacts"))
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	b := testBundle()
+	require.NoError(t, bundle.WriteDir(dir, b, "body"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "page"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "page", "about.md"), []byte("---\ntitle: About\n---\n"), 0644))
+
+	got, err := bundle.ReadDir(dir, "body")
+	require.NoError(t, err)
+	require.Equal(t, b.Types, got.Types)
+	require.Len(t, got.Items, 2)
+	require.Equal(t, b.Items[0], got.Items[0])
+	require.Equal(t, "page", got.Items[1].Type)
+	require.Equal(t, bundle.PathUUID("page/about"), got.Items[1].UUID)
+	require.Equal(t, map[string]interface{}{"title": "About"}, got.Items[1].Data)
+}
@@ -0,0 +1,11 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package bundle
+
+const (
+	Version      = 1            // version of the bundle format
+	DftBodyField = "body"       // item data field holding the Markdown file body
+	TypesFile    = "types.yaml" // file of content types in a Markdown directory
+)
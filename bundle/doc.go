@@ -0,0 +1,14 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package bundle provides export and import of content.
+//
+// A bundle holds content types and content items with their revisions, taxonomy terms, permalinks and references
+// to media. It is serialised to a single JSON or YAML file, or to a directory of Markdown files with YAML front
+// matter, one file per item. Import is idempotent: items are keyed by UUID, so importing the same bundle again leaves
+// the database unchanged, and an interrupted import may simply be repeated.
+//
+// Markdown files written by hand, e.g. taken from a static site repository, need no UUID and type: the UUID is
+// derived from the file path and the type from its first directory. Unknown front matter keys become item data.
+package bundle
@@ -0,0 +1,234 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const fence = "---\n"
+
+// ErrNoFrontMatter is returned when a Markdown file does not start with front matter.
+var ErrNoFrontMatter = errors.New("no front matter")
+
+// entryKeys lists front matter keys of entry fields, other keys are item data.
+var entryKeys = map[string]bool{
+	"uuid": true, "type": true, "status": true, "revision": true, "createdAt": true, "updatedAt": true,
+	"publishAt": true, "expireAt": true, "publishedAt": true, "permalink": true, "terms": true, "media": true,
+	"data": true, "revisions": true,
+}
+
+// MarshalMarkdown converts an entry to a Markdown file. The body field of the item data becomes the file body, the
+// rest of the entry goes to the front matter.
+func MarshalMarkdown(e *Entry, bodyField string) ([]byte, error) {
+	v, err := toPlain(e)
+	if err != nil {
+		return nil, err
+	}
+
+	m := v.(map[string]interface{})
+	body := ""
+	if data, ok := m["data"].(map[string]interface{}); ok {
+		body, _ = data[bodyField].(string)
+		delete(data, bodyField)
+		if len(data) == 0 {
+			delete(m, "data")
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fence)
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(m); err != nil {
+		return nil, err
+	}
+	if err = enc.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(fence)
+	buf.WriteString(body)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMarkdown converts a Markdown file to an entry. Front matter keys which are not entry fields are added to
+// the item data, the file body becomes the body field.
+func UnmarshalMarkdown(b []byte, bodyField string) (*Entry, error) {
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if !strings.HasPrefix(s, fence) {
+		return nil, ErrNoFrontMatter
+	}
+	s = s[len(fence):]
+
+	var front, body string
+	if strings.HasPrefix(s, fence) {
+		body = s[len(fence):]
+	} else if i := strings.Index(s, "\n"+fence); i >= 0 {
+		front, body = s[:i+1], s[i+1+len(fence):]
+	} else if strings.HasSuffix(s, "\n---") {
+		front = s[:len(s)-3]
+	} else {
+		return nil, ErrNoFrontMatter
+	}
+
+	m := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(front), &m); err != nil {
+		return nil, err
+	}
+
+	data, _ := m["data"].(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	for k, v := range m {
+		if !entryKeys[k] {
+			data[k] = v
+			delete(m, k)
+		}
+	}
+	if body != "" {
+		data[bodyField] = body
+	}
+	m["data"] = data
+
+	e := &Entry{}
+	if err := fromPlain(m, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// WriteDir writes a bundle to a directory: content types to TypesFile and every item to <type>/<uuid>.md.
+func WriteDir(dir string, b *Bundle, bodyField string) error {
+	if len(b.Types) > 0 {
+		v, err := toPlain(map[string]interface{}{"version": b.Version, "types": b.Types})
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, TypesFile), out, 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range b.Items {
+		out, err := MarshalMarkdown(e, bodyField)
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.UUID, err)
+		}
+
+		typeDir := filepath.Join(dir, e.Type)
+		if err = os.MkdirAll(typeDir, 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(typeDir, e.UUID+".md"), out, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadDir reads a bundle from a directory written by WriteDir or from a tree of Markdown files. Items without a UUID
+// get one derived from the file path relative to the directory, items without a type get the name of the first
+// directory of the path.
+func ReadDir(dir string, bodyField string) (*Bundle, error) {
+	b := &Bundle{Version: Version}
+
+	if f, err := os.Open(filepath.Join(dir, TypesFile)); err == nil {
+		types, err := Decode(f, FormatYAML)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", TypesFile, err)
+		}
+		b.Types = types.Types
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		in, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := UnmarshalMarkdown(in, bodyField)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", rel, err)
+		}
+
+		if e.UUID == "" {
+			e.UUID = PathUUID(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		}
+		if e.Type == "" {
+			if i := strings.Index(rel, "/"); i > 0 {
+				e.Type = rel[:i]
+			} else {
+				return nil, fmt.Errorf("%s: no content type", rel)
+			}
+		}
+
+		b.Items = append(b.Items, e)
+	}
+
+	return b, nil
+}
+
+// pathNamespace is the namespace of UUIDs derived from file paths.
+var pathNamespace = []byte{0x5b, 0x1c, 0x2d, 0x0e, 0x8a, 0x3f, 0x4b, 0x6e, 0x9d, 0x70, 0x21, 0xc4, 0xa3, 0x55, 0xe8,
+	0x17}
+
+// PathUUID returns a name-based UUID (version 5) of a file path, so the same file always gets the same UUID.
+func PathUUID(path string) string {
+	h := sha1.New()
+	h.Write(pathNamespace)
+	h.Write([]byte(path))
+
+	u := h.Sum(nil)[:16]
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
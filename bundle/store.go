@@ -0,0 +1,291 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/permalink"
+	"ampho.xyz/core/taxonomy"
+)
+
+// Store exports content from the database to bundles and imports it back.
+type Store struct {
+	items      *content.Store
+	types      *content.Registry
+	taxonomy   *taxonomy.Taxonomy
+	permalinks *permalink.Permalinks
+}
+
+// Export returns a bundle of items of the content types, or of all items if no types are given, along with the types
+// definitions.
+func (s *Store) Export(ctx context.Context, types ...string) (*Bundle, error) {
+	all, err := s.types.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Version: Version, Items: []*Entry{}}
+	byName := itemTypes(all)
+	var queries []content.Query
+
+	if len(types) == 0 {
+		b.Types = all
+		queries = append(queries, content.Query{OrderBy: "id"})
+	}
+	for _, name := range types {
+		t := byName[name]
+		if t == nil {
+			return nil, fmt.Errorf("%s: %w", name, content.ErrTypeNotFound)
+		}
+		b.Types = append(b.Types, t)
+		queries = append(queries, content.Query{Type: name, OrderBy: "id"})
+	}
+
+	for _, q := range queries {
+		items, err := s.items.List(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			e, err := s.entry(ctx, item, byName[item.Type])
+			if err != nil {
+				return nil, fmt.Errorf("item %s: %v", item.GetUUID(), err)
+			}
+			b.Items = append(b.Items, e)
+		}
+	}
+
+	return b, nil
+}
+
+// entry converts an item to an entry with its revisions, terms, permalink and media references. The item type may be
+// nil.
+func (s *Store) entry(ctx context.Context, item *content.Item, t *content.Type) (*Entry, error) {
+	e := &Entry{
+		UUID:        item.GetUUID(),
+		Type:        item.Type,
+		Status:      item.Status,
+		Revision:    item.Revision,
+		CreatedAt:   entityTime(item.CreatedAt),
+		UpdatedAt:   entityTime(item.UpdatedAt),
+		PublishAt:   timeOrNil(item.PublishAt),
+		ExpireAt:    timeOrNil(item.ExpireAt),
+		PublishedAt: timeOrNil(item.PublishedAt),
+		Data:        item.Data,
+	}
+
+	revisions, err := s.items.Revisions(ctx, e.UUID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		e.Revisions = append(e.Revisions, &Revision{
+			Number:    revisions[i].Number,
+			CreatedAt: timeOrNil(revisions[i].CreatedAt),
+			Data:      revisions[i].Data,
+		})
+	}
+
+	terms, err := s.taxonomy.Terms(ctx, e.UUID, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		names, err := s.taxonomy.Names(ctx, term.GetUUID())
+		if err != nil {
+			return nil, err
+		}
+		if e.Terms == nil {
+			e.Terms = make(map[string][][]string)
+		}
+		e.Terms[term.Vocabulary] = append(e.Terms[term.Vocabulary], names)
+	}
+
+	e.Permalink, err = s.permalinks.Path(ctx, e.UUID)
+	if err != nil && err != permalink.ErrNotFound {
+		return nil, err
+	}
+
+	if t != nil {
+		e.Media = mediaRefs(t, item.Data)
+	}
+
+	return e, nil
+}
+
+// Import imports a bundle. Content types are created or updated by name, items are created or overwritten by UUID
+// after their data is validated against their types. Import stops at the first failed item, and as it is idempotent,
+// it may be repeated after the cause is fixed.
+func (s *Store) Import(ctx context.Context, b *Bundle) error {
+	for _, t := range b.Types {
+		if err := s.types.Save(ctx, t); err != nil {
+			return fmt.Errorf("type %s: %v", t.Name, err)
+		}
+	}
+
+	all, err := s.types.List(ctx)
+	if err != nil {
+		return err
+	}
+	types := itemTypes(all)
+
+	for _, e := range b.Items {
+		if err = s.importEntry(ctx, e, types[e.Type]); err != nil {
+			return fmt.Errorf("item %s: %v", e.UUID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) importEntry(ctx context.Context, e *Entry, t *content.Type) error {
+	if !database.IsUUID(e.UUID) {
+		return fmt.Errorf("invalid UUID")
+	}
+	if t == nil {
+		return fmt.Errorf("%s: %w", e.Type, content.ErrTypeNotFound)
+	}
+	if err := t.Validate(e.Data); err != nil {
+		return err
+	}
+
+	item := &content.Item{
+		Type:        e.Type,
+		Status:      e.Status,
+		Revision:    e.Revision,
+		Data:        e.Data,
+		PublishAt:   timestamptz(e.PublishAt),
+		ExpireAt:    timestamptz(e.ExpireAt),
+		PublishedAt: timestamptz(e.PublishedAt),
+	}
+	_ = item.UUID.Set(e.UUID)
+	item.CreatedAt = timestamp(e.CreatedAt)
+	item.UpdatedAt = timestamp(e.UpdatedAt)
+
+	switch item.Status {
+	case "":
+		item.Status = content.StatusDraft
+	case content.StatusDraft, content.StatusScheduled, content.StatusPublished, content.StatusExpired:
+	default:
+		return fmt.Errorf("unknown status %q", item.Status)
+	}
+
+	revisions := make([]*content.Revision, len(e.Revisions))
+	for i, r := range e.Revisions {
+		revisions[i] = &content.Revision{Number: r.Number, Data: r.Data, CreatedAt: timestamptz(r.CreatedAt)}
+		if r.Number > item.Revision {
+			item.Revision = r.Number
+		}
+	}
+	if item.Revision == 0 {
+		item.Revision = 1
+	}
+
+	if err := s.items.Import(ctx, item, revisions); err != nil {
+		return err
+	}
+
+	for vocabulary, paths := range e.Terms {
+		for _, names := range paths {
+			term, err := s.taxonomy.Ensure(ctx, vocabulary, names...)
+			if err != nil {
+				return err
+			}
+			if err = s.taxonomy.Attach(ctx, term.GetUUID(), e.UUID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if e.Permalink != "" {
+		return s.permalinks.Set(ctx, e.UUID, e.Permalink)
+	}
+
+	return nil
+}
+
+// mediaRefs returns UUIDs referenced by media fields of item data.
+func mediaRefs(t *content.Type, data map[string]interface{}) []string {
+	var r []string
+
+	for _, f := range t.Fields {
+		if f.Type != content.FieldMedia {
+			continue
+		}
+
+		switch v := data[f.Name].(type) {
+		case string:
+			r = append(r, v)
+		case []interface{}:
+			for _, u := range v {
+				if s, ok := u.(string); ok {
+					r = append(r, s)
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// itemTypes returns content types by names.
+func itemTypes(types []*content.Type) map[string]*content.Type {
+	r := make(map[string]*content.Type, len(types))
+	for _, t := range types {
+		r[t.Name] = t
+	}
+
+	return r
+}
+
+func entityTime(t pgtype.Timestamp) *time.Time {
+	if t.Status != pgtype.Present {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func timeOrNil(t pgtype.Timestamptz) *time.Time {
+	if t.Status != pgtype.Present {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func timestamp(t *time.Time) pgtype.Timestamp {
+	if t == nil {
+		return pgtype.Timestamp{Status: pgtype.Null}
+	}
+
+	return pgtype.Timestamp{Time: *t, Status: pgtype.Present}
+}
+
+func timestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{Status: pgtype.Null}
+	}
+
+	return pgtype.Timestamptz{Time: *t, Status: pgtype.Present}
+}
+
+// New creates a new bundle store.
+func New(db *database.Database) *Store {
+	return &Store{
+		items:      content.NewStore(db),
+		types:      content.NewRegistry(db),
+		taxonomy:   taxonomy.New(db),
+		permalinks: permalink.New(db),
+	}
+}
@@ -0,0 +1,189 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Command ampho-content exports content to bundles and imports it back.
+//
+// Usage:
+//
+//	ampho-content [-config name] [-dsn dsn]... export [-type name]... [path]
+//	ampho-content [-config name] [-dsn dsn]... import path
+//
+// The bundle format is chosen by the path extension: .json, .yaml or .yml, and a directory of Markdown files if there
+// is no extension. Export writes JSON to the standard output if no path is given.
+//
+// Database replicas are taken from the database.dsn setting of the configuration file unless given with -dsn.
+// The item data field holding Markdown file bodies is set by the bundle.bodyField setting.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"ampho.xyz/core/bundle"
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+)
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintln(out, "Usage:")
+	_, _ = fmt.Fprintln(out, "  ampho-content [flags] export [-type name]... [path]")
+	_, _ = fmt.Fprintln(out, "  ampho-content [flags] import path")
+	_, _ = fmt.Fprintln(out, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var dsn stringsFlag
+
+	name := flag.String("config", "ampho", "configuration file `name`")
+	flag.Var(&dsn, "dsn", "database replica `DSN`, prefixed with ro: for read-only ones, may be repeated")
+	flag.Usage = usage
+	flag.Parse()
+
+	if err := run(*name, dsn, flag.Args()); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "ampho-content:", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, dsn []string, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("no command given")
+	}
+
+	cfg, err := config.New(name, "yaml", config.DefaultSearchPaths()...)
+	if err != nil {
+		return err
+	}
+	cfg.SetDefault("bundle.bodyField", bundle.DftBodyField)
+
+	if len(dsn) == 0 {
+		dsn = cfg.GetStringSlice("database.dsn")
+	}
+	if len(dsn) == 0 {
+		return errors.New("no database configured")
+	}
+
+	ctx := context.Background()
+	db, err := database.NewFromDSN(ctx, dsn...)
+	if err != nil {
+		return err
+	}
+
+	store := bundle.New(db)
+	bodyField := cfg.GetString("bundle.bodyField")
+
+	switch args[0] {
+	case "export":
+		return export(ctx, store, bodyField, args[1:])
+	case "import":
+		return load(ctx, store, bodyField, args[1:])
+	}
+
+	flag.Usage()
+
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func export(ctx context.Context, store *bundle.Store, bodyField string, args []string) error {
+	var types stringsFlag
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Var(&types, "type", "content type `name` to export, may be repeated, all types if omitted")
+	_ = fs.Parse(args)
+
+	b, err := store.Export(ctx, types...)
+	if err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		return bundle.Encode(os.Stdout, b, bundle.FormatJSON)
+	}
+
+	format, err := bundle.FormatOf(path)
+	if err != nil {
+		return err
+	}
+	if format == bundle.FormatMarkdown {
+		err = bundle.WriteDir(path, b, bodyField)
+	} else {
+		err = writeFile(path, b, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d types and %d items to %s\n", len(b.Types), len(b.Items), path)
+
+	return nil
+}
+
+func writeFile(path string, b *bundle.Bundle, format bundle.Format) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = bundle.Encode(f, b, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func load(ctx context.Context, store *bundle.Store, bodyField string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("import needs a bundle path")
+	}
+	path := args[0]
+
+	format, err := bundle.FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	var b *bundle.Bundle
+	if format == bundle.FormatMarkdown {
+		b, err = bundle.ReadDir(path, bodyField)
+	} else {
+		var f io.ReadCloser
+		if f, err = os.Open(path); err != nil {
+			return err
+		}
+		b, err = bundle.Decode(f, format)
+		_ = f.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = store.Import(ctx, b); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d types and %d items from %s\n", len(b.Types), len(b.Items), path)
+
+	return nil
+}
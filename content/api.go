@@ -0,0 +1,676 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/lock"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/relation"
+	"ampho.xyz/core/security"
+)
+
+// API provides HTTP handlers for managing content types and items.
+//
+// If locks are enabled, writes of an item are refused unless the user taken from the request context, see
+// security.Middleware, may write it, see lock.Locks.CheckTx.
+type API struct {
+	types     *Registry
+	items     *Store
+	relations *relation.Relations
+	locks     *lock.Locks
+}
+
+// Mount registers API handlers on a router. Usually it is a subrouter with a path prefix:
+//
+//	api.Mount(svc.Router().PathPrefix("/content").Subrouter())
+func (a *API) Mount(r *mux.Router) {
+	r.HandleFunc("/types", a.listTypes).Methods(http.MethodGet)
+	r.HandleFunc("/types", a.createType).Methods(http.MethodPost)
+	r.HandleFunc("/types/{type}", a.getType).Methods(http.MethodGet)
+	r.HandleFunc("/types/{type}", a.updateType).Methods(http.MethodPut)
+	r.HandleFunc("/types/{type}", a.deleteType).Methods(http.MethodDelete)
+	r.HandleFunc("/types/{type}/schema", a.getTypeSchema).Methods(http.MethodGet)
+
+	r.HandleFunc("/items/{type}", a.listItems).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}", a.createItem).Methods(http.MethodPost)
+	r.HandleFunc("/items/{type}/{uuid}", a.getItem).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}/{uuid}", a.updateItem).Methods(http.MethodPut)
+	r.HandleFunc("/items/{type}/{uuid}", a.deleteItem).Methods(http.MethodDelete)
+	r.HandleFunc("/items/{type}/{uuid}/revisions", a.listRevisions).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}/{uuid}/schedule", a.scheduleItem).Methods(http.MethodPut)
+	r.HandleFunc("/items/{type}/{uuid}/preview", a.previewItem).Methods(http.MethodPost)
+	r.HandleFunc("/items/{type}/{uuid}/references", a.listReferences).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}/{uuid}/variants", a.listVariants).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}/{uuid}/variants/{locale}", a.getVariant).Methods(http.MethodGet)
+	r.HandleFunc("/items/{type}/{uuid}/variants/{locale}", a.saveVariant).Methods(http.MethodPut)
+	r.HandleFunc("/items/{type}/{uuid}/variants/{locale}", a.deleteVariant).Methods(http.MethodDelete)
+
+	r.HandleFunc("/trash", a.listTrash).Methods(http.MethodGet)
+	r.HandleFunc("/trash/{uuid}/restore", a.restoreItem).Methods(http.MethodPost)
+	r.HandleFunc("/trash/{uuid}", a.purgeItem).Methods(http.MethodDelete)
+
+	if a.locks != nil {
+		r.HandleFunc("/items/{type}/{uuid}/lock", a.getLock).Methods(http.MethodGet)
+		r.HandleFunc("/items/{type}/{uuid}/lock", a.acquireLock).Methods(http.MethodPost)
+		r.HandleFunc("/items/{type}/{uuid}/lock", a.heartbeatLock).Methods(http.MethodPut)
+		r.HandleFunc("/items/{type}/{uuid}/lock", a.releaseLock).Methods(http.MethodDelete)
+	}
+}
+
+func (a *API) listTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := a.types.List(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if types == nil {
+		types = []*Type{}
+	}
+
+	_, _ = httputil.WriteJSON(w, types)
+}
+
+func (a *API) createType(w http.ResponseWriter, r *http.Request) {
+	t := &Type{}
+	if err := httputil.ReadJSON(w, r, t); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := t.Check(); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+
+	if err := a.types.Create(r.Context(), t); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, http.StatusCreated, t)
+}
+
+func (a *API) getType(w http.ResponseWriter, r *http.Request) {
+	t, err := a.types.Get(r.Context(), mux.Vars(r)["type"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, t)
+}
+
+func (a *API) getTypeSchema(w http.ResponseWriter, r *http.Request) {
+	t, err := a.types.Get(r.Context(), mux.Vars(r)["type"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = httputil.WriteJSON(w, t.JSONSchema())
+}
+
+func (a *API) updateType(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["type"]
+	if _, err := a.types.Get(r.Context(), name); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	t := &Type{}
+	if err := httputil.ReadJSON(w, r, t); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	t.Name = name
+
+	a.saveType(w, r, t, http.StatusOK)
+}
+
+func (a *API) saveType(w http.ResponseWriter, r *http.Request, t *Type, code int) {
+	if err := t.Check(); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+
+	if err := a.types.Save(r.Context(), t); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, code, t)
+}
+
+func (a *API) deleteType(w http.ResponseWriter, r *http.Request) {
+	if err := a.types.Delete(r.Context(), mux.Vars(r)["type"]); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) listItems(w http.ResponseWriter, r *http.Request) {
+	t, err := a.types.Get(r.Context(), mux.Vars(r)["type"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	q := Query{Type: t.Name, Status: Status(r.URL.Query().Get("status"))}
+	q.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	q.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+
+	items, err := a.items.List(r.Context(), q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if items == nil {
+		items = []*Item{}
+	}
+
+	_, _ = httputil.WriteJSON(w, items)
+}
+
+func (a *API) createItem(w http.ResponseWriter, r *http.Request) {
+	t, err := a.types.Get(r.Context(), mux.Vars(r)["type"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	item := &Item{Type: t.Name}
+	if err = httputil.ReadJSON(w, r, &item.Data); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err = t.Validate(item.Data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err = a.items.Create(r.Context(), item); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, http.StatusCreated, item)
+}
+
+func (a *API) getItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, item)
+}
+
+func (a *API) updateItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	t, err := a.types.Get(r.Context(), item.Type)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	item.Data = nil
+	if err = httputil.ReadJSON(w, r, &item.Data); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err = t.Validate(item.Data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err = a.items.Update(r.Context(), item, a.writeChecks(r)...); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, item)
+}
+
+func (a *API) deleteItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err = a.items.Delete(r.Context(), item.GetUUID(), a.writeChecks(r)...); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTrash lists items in the trash, the latest deleted first. Items may be filtered by the type query parameter.
+func (a *API) listTrash(w http.ResponseWriter, r *http.Request) {
+	q := Query{Type: r.URL.Query().Get("type"), Deleted: true}
+	q.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	q.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+
+	items, err := a.items.List(r.Context(), q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if items == nil {
+		items = []*Item{}
+	}
+
+	_, _ = httputil.WriteJSON(w, items)
+}
+
+func (a *API) restoreItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.items.Restore(r.Context(), mux.Vars(r)["uuid"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, item)
+}
+
+func (a *API) purgeItem(w http.ResponseWriter, r *http.Request) {
+	if err := a.items.Purge(r.Context(), mux.Vars(r)["uuid"]); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) listRevisions(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	revisions, err := a.items.Revisions(r.Context(), item.GetUUID())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []*Revision{}
+	}
+
+	_, _ = httputil.WriteJSON(w, revisions)
+}
+
+func (a *API) listVariants(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	variants, err := a.items.Variants(r.Context(), item.GetUUID())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if variants == nil {
+		variants = []*Variant{}
+	}
+
+	_, _ = httputil.WriteJSON(w, variants)
+}
+
+func (a *API) getVariant(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	v, err := a.items.Variant(r.Context(), item.GetUUID(), i18n.Canonical(mux.Vars(r)["locale"]))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, v)
+}
+
+// saveVariant creates or replaces item data in the locale. The data is validated as the item data is.
+func (a *API) saveVariant(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	locale := i18n.Canonical(mux.Vars(r)["locale"])
+	if locale == "" {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, "invalid locale", nil)
+		return
+	}
+
+	t, err := a.types.Get(r.Context(), item.Type)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	v := &Variant{ItemUUID: item.GetUUID(), Locale: locale}
+	if err = httputil.ReadJSON(w, r, &v.Data); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err = t.Validate(v.Data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err = a.items.SaveVariant(r.Context(), v, a.writeChecks(r)...); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, v)
+}
+
+func (a *API) deleteVariant(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = a.items.DeleteVariant(r.Context(), item.GetUUID(), i18n.Canonical(mux.Vars(r)["locale"]), a.writeChecks(r)...)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listReferences lists relations referring to an item, optionally only the ones named by the name query parameter.
+func (a *API) listReferences(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	rels, err := a.relations.Sources(r.Context(), item.GetUUID(), r.URL.Query().Get("name"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if rels == nil {
+		rels = []*relation.Relation{}
+	}
+
+	_, _ = httputil.WriteJSON(w, rels)
+}
+
+func (a *API) scheduleItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req struct {
+		PublishAt *time.Time `json:"publishAt"`
+		ExpireAt  *time.Time `json:"expireAt"`
+	}
+	if err = httputil.ReadJSON(w, r, &req); err != nil {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if item, err = a.items.Schedule(r.Context(), item.GetUUID(), req.PublishAt, req.ExpireAt,
+		a.writeChecks(r)...); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, item)
+}
+
+// previewItem issues a preview token of the current item revision or of the revision given by the revision query
+// parameter. The token lifetime may be requested by the ttl query parameter, e.g. ttl=2h.
+func (a *API) previewItem(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	q := r.URL.Query()
+	revision, ttl := item.Revision, DftPreviewTTL
+
+	if v := q.Get("revision"); v != "" {
+		if revision, err = strconv.Atoi(v); err != nil {
+			_, _ = httputil.WriteError(w, http.StatusBadRequest, "invalid revision", nil)
+			return
+		}
+		if _, err = a.items.Revision(r.Context(), item.GetUUID(), revision); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if v := q.Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 || ttl > MaxPreviewTTL {
+			_, _ = httputil.WriteError(w, http.StatusBadRequest, "invalid ttl", nil)
+			return
+		}
+	}
+
+	token, exp, err := NewPreviewToken(item.GetUUID(), revision, ttl)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, http.StatusCreated, map[string]interface{}{
+		"token":     token,
+		"revision":  revision,
+		"expiresAt": exp.UTC(),
+	})
+}
+
+func (a *API) getLock(w http.ResponseWriter, r *http.Request) {
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	l, err := a.locks.Get(r.Context(), item.GetUUID())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, l)
+}
+
+// acquireLock locks an item by the user. The lease time may be requested by the ttl query parameter, e.g. ttl=10m.
+// If another user holds the lock, it responds with 423 status and the lock.
+func (a *API) acquireLock(w http.ResponseWriter, r *http.Request) {
+	a.lease(w, r, a.locks.Acquire, http.StatusCreated)
+}
+
+// heartbeatLock prolongs the lock held by the user, see acquireLock.
+func (a *API) heartbeatLock(w http.ResponseWriter, r *http.Request) {
+	a.lease(w, r, a.locks.Heartbeat, http.StatusOK)
+}
+
+func (a *API) lease(w http.ResponseWriter, r *http.Request,
+	f func(context.Context, string, string, time.Duration) (*lock.Lock, error), code int) {
+	owner := subject(r)
+	if owner == "" {
+		_, _ = httputil.WriteError(w, http.StatusUnauthorized, "", nil)
+		return
+	}
+
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil {
+			_, _ = httputil.WriteError(w, http.StatusBadRequest, "invalid ttl", nil)
+			return
+		}
+	}
+
+	l, err := f(r.Context(), item.GetUUID(), owner, ttl)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, code, l)
+}
+
+// releaseLock releases the lock held by the user. Administrators may release a lock of another user with the force
+// query parameter, e.g. force=true.
+func (a *API) releaseLock(w http.ResponseWriter, r *http.Request) {
+	owner := subject(r)
+	if owner == "" {
+		_, _ = httputil.WriteError(w, http.StatusUnauthorized, "", nil)
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if force && !a.locks.IsAdmin(security.PrincipalFrom(r.Context())) {
+		_, _ = httputil.WriteError(w, http.StatusForbidden, "", nil)
+		return
+	}
+
+	item, err := a.item(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if force {
+		err = a.locks.ForceRelease(r.Context(), item.GetUUID())
+	} else {
+		err = a.locks.Release(r.Context(), item.GetUUID(), owner)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// item returns an item addressed by the request path.
+func (a *API) item(r *http.Request) (*Item, error) {
+	vars := mux.Vars(r)
+
+	item, err := a.items.Get(r.Context(), vars["uuid"])
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Type != vars["type"] {
+		return nil, ErrNotFound
+	}
+
+	return item, nil
+}
+
+// writeChecks returns the checks of writes of items by the request user: if locks are enabled, the user may write
+// only items which are not locked by other users.
+func (a *API) writeChecks(r *http.Request) []WriteCheck {
+	if a.locks == nil {
+		return nil
+	}
+
+	owner := subject(r)
+
+	return []WriteCheck{func(ctx context.Context, tx pgx.Tx, uuid string) error {
+		return a.locks.CheckTx(ctx, tx, uuid, owner)
+	}}
+}
+
+// subject returns the subject of the request principal or an empty string if the request is anonymous.
+func subject(r *http.Request) string {
+	if p := security.PrincipalFrom(r.Context()); p != nil {
+		return p.Subject
+	}
+
+	return ""
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		vErr       ValidationError
+		restricted relation.RestrictedError
+		locked     *lock.LockedError
+	)
+
+	switch {
+	case errors.As(err, &vErr):
+		_, _ = httputil.WriteError(w, http.StatusUnprocessableEntity, "invalid content", vErr)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrTypeNotFound):
+		_, _ = httputil.WriteError(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, ErrTypeExists), errors.Is(err, ErrTypeInUse):
+		_, _ = httputil.WriteError(w, http.StatusConflict, err.Error(), nil)
+	case errors.As(err, &restricted):
+		_, _ = httputil.WriteError(w, http.StatusConflict, relation.ErrRestricted.Error(), restricted)
+	case errors.As(err, &locked):
+		_, _ = httputil.WriteError(w, http.StatusLocked, lock.ErrLocked.Error(), locked.Lock)
+	case errors.Is(err, lock.ErrNotHeld):
+		_, _ = httputil.WriteError(w, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, lock.ErrNotFound):
+		_, _ = httputil.WriteError(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, lock.ErrInvalidTTL):
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+	default:
+		logger.FromContext(r.Context()).Error("content API error", "error", err)
+		_, _ = httputil.WriteError(w, http.StatusInternalServerError, "", nil)
+	}
+}
+
+// NewAPI creates a new content API. Locks may be nil to disable locking of items.
+func NewAPI(types *Registry, items *Store, locks *lock.Locks) *API {
+	return &API{types, items, relation.New(items.DB()), locks}
+}
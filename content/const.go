@@ -0,0 +1,21 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import "time"
+
+const (
+	DftSchedulerInterval = time.Second * 30 // how often scheduled items are checked
+	DftPreviewTTL        = time.Hour * 24   // how long a preview token is valid if no ttl is requested
+	MaxPreviewTTL        = time.Hour * 168  // maximum lifetime of a preview token
+	DftTrashRetention    = time.Hour * 720  // how long items are kept in the trash before they are purged
+	DftPurgerInterval    = time.Hour        // how often the trash is checked for items to purge
+)
+
+const (
+	EventPublished = "content.published" // an item went live
+	EventExpired   = "content.expired"   // an item expired
+	EventPurged    = "content.purged"    // an item was deleted permanently, the payload is its UUID
+)
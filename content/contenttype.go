@@ -0,0 +1,157 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/relation"
+)
+
+// FieldType is a type of content field value.
+type FieldType string
+
+const (
+	FieldString    FieldType = "string"    // single line of text
+	FieldText      FieldType = "text"      // multiline plain text
+	FieldRichText  FieldType = "richtext"  // formatted text
+	FieldInteger   FieldType = "integer"   // integer number
+	FieldNumber    FieldType = "number"    // floating point number
+	FieldBoolean   FieldType = "boolean"   // true or false
+	FieldDateTime  FieldType = "datetime"  // RFC 3339 date and time
+	FieldReference FieldType = "reference" // UUID of another content item
+	FieldMedia     FieldType = "media"     // UUID of a media asset
+)
+
+var (
+	typeNameRe  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	fieldNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+)
+
+// Validation holds field value constraints. Only constraints applicable to the field type are taken into account.
+type Validation struct {
+	MinLength *int          `json:"minLength,omitempty"` // minimum string length in characters
+	MaxLength *int          `json:"maxLength,omitempty"` // maximum string length in characters
+	Pattern   string        `json:"pattern,omitempty"`   // regular expression a string must match
+	Minimum   *float64      `json:"minimum,omitempty"`   // minimum numeric value
+	Maximum   *float64      `json:"maximum,omitempty"`   // maximum numeric value
+	Enum      []interface{} `json:"enum,omitempty"`      // list of allowed values
+	MinItems  *int          `json:"minItems,omitempty"`  // minimum number of values of a multiple field
+	MaxItems  *int          `json:"maxItems,omitempty"`  // maximum number of values of a multiple field
+}
+
+// Field describes a content type field.
+type Field struct {
+	Name       string          `json:"name"`
+	Title      string          `json:"title,omitempty"`
+	Type       FieldType       `json:"type"`
+	Required   bool            `json:"required,omitempty"`
+	Multiple   bool            `json:"multiple,omitempty"`
+	RefType    string          `json:"refType,omitempty"`  // type of referenced items, any type if empty
+	OnDelete   relation.Action `json:"onDelete,omitempty"` // action on deletion of referenced entities
+	Validation Validation      `json:"validation"`
+}
+
+// Type is a user-defined content type.
+type Type struct {
+	database.Entity
+	Name        string
+	Title       string
+	Description string
+	Fields      []Field
+}
+
+// Field returns a field by its name or nil if the type has no such field.
+func (t *Type) Field(name string) *Field {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+
+	return nil
+}
+
+// Check checks the type definition is correct.
+func (t *Type) Check() error {
+	if !typeNameRe.MatchString(t.Name) {
+		return fmt.Errorf("invalid type name %q", t.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range t.Fields {
+		if !fieldNameRe.MatchString(f.Name) {
+			return fmt.Errorf("invalid field name %q", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate field %q", f.Name)
+		}
+		seen[f.Name] = true
+
+		switch f.Type {
+		case FieldString, FieldText, FieldRichText, FieldInteger, FieldNumber, FieldBoolean, FieldDateTime,
+			FieldReference, FieldMedia:
+		default:
+			return fmt.Errorf("field %q has unknown type %q", f.Name, f.Type)
+		}
+
+		if f.RefType != "" && (f.Type != FieldReference || !typeNameRe.MatchString(f.RefType)) {
+			return fmt.Errorf("field %q has invalid reference type %q", f.Name, f.RefType)
+		}
+
+		if f.OnDelete != "" && f.Type != FieldReference && f.Type != FieldMedia {
+			return fmt.Errorf("field %q is not a reference and has a delete action", f.Name)
+		}
+		if err := relation.Check(f.OnDelete); err != nil {
+			return fmt.Errorf("field %q: %v", f.Name, err)
+		}
+
+		if f.Validation.Pattern != "" {
+			if _, err := regexp.Compile(f.Validation.Pattern); err != nil {
+				return fmt.Errorf("field %q has invalid pattern: %v", f.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *Type) MarshalJSON() ([]byte, error) {
+	fields := t.Fields
+	if fields == nil {
+		fields = []Field{}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"name":        t.Name,
+		"title":       t.Title,
+		"description": t.Description,
+		"fields":      fields,
+		"createdAt":   t.GetCreatedAt(),
+		"updatedAt":   t.GetUpdatedAt(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Type) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Name        string  `json:"name"`
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		Fields      []Field `json:"fields"`
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	t.Name, t.Title, t.Description, t.Fields = v.Name, v.Title, v.Description, v.Fields
+
+	return nil
+}
@@ -0,0 +1,15 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package content provides versioned content items stored in the database.
+//
+// Every change of item data creates a new revision, so the full history of an item is kept. Items can be scheduled
+// to be published and expired at a given time, see Scheduler. Unpublished revisions can be shared with reviewers by
+// signed preview tokens, see NewPreviewToken. Editors may lock items they are editing, so other editors cannot
+// overwrite their changes, see API and package lock. Besides data in the default locale, an item may have data in
+// other locales, see Variant.
+//
+// Deleted items are moved to the trash, where they can be restored from until they are purged permanently, either
+// explicitly or by Purger when the retention period is over.
+package content
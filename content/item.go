@@ -0,0 +1,115 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgtype"
+
+	"ampho.xyz/core/database"
+)
+
+// Status is a publication status of a content item.
+type Status string
+
+const (
+	StatusDraft     Status = "draft"     // not published yet
+	StatusScheduled Status = "scheduled" // waiting for its publish time
+	StatusPublished Status = "published" // live
+	StatusExpired   Status = "expired"   // was live, but its expire time has come
+)
+
+// Item is a versioned content item.
+type Item struct {
+	database.Entity
+	Type        string
+	Status      Status
+	Revision    int
+	Data        map[string]interface{}
+	PublishAt   pgtype.Timestamptz
+	ExpireAt    pgtype.Timestamptz
+	PublishedAt pgtype.Timestamptz
+}
+
+// IsLive checks whether the item is published, not expired at the moment t and not in the trash.
+func (i *Item) IsLive(t time.Time) bool {
+	if i.Status != StatusPublished || i.DeletedAt.Status == pgtype.Present {
+		return false
+	}
+
+	return i.ExpireAt.Status != pgtype.Present || t.Before(i.ExpireAt.Time)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i *Item) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"uuid":        i.GetUUID(),
+		"type":        i.Type,
+		"status":      i.Status,
+		"revision":    i.Revision,
+		"data":        i.Data,
+		"createdAt":   i.GetCreatedAt(),
+		"updatedAt":   i.GetUpdatedAt(),
+		"publishAt":   timeOrNil(i.PublishAt),
+		"expireAt":    timeOrNil(i.ExpireAt),
+		"publishedAt": timeOrNil(i.PublishedAt),
+		"deletedAt":   entityTime(i.DeletedAt),
+	})
+}
+
+// Revision is a snapshot of content item data.
+type Revision struct {
+	ID        uint
+	ItemID    uint
+	Number    int
+	Data      map[string]interface{}
+	CreatedAt pgtype.Timestamptz
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Revision) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"number":    r.Number,
+		"data":      r.Data,
+		"createdAt": r.CreatedAt.Time,
+	})
+}
+
+func timeOrNil(t pgtype.Timestamptz) interface{} {
+	if t.Status != pgtype.Present {
+		return nil
+	}
+
+	return t.Time
+}
+
+func entityTime(t pgtype.Timestamp) interface{} {
+	if t.Status != pgtype.Present {
+		return nil
+	}
+
+	return t.Time
+}
+
+func timestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{Status: pgtype.Null}
+	}
+
+	return pgtype.Timestamptz{Time: *t, Status: pgtype.Present}
+}
+
+// LiveSQL returns an SQL condition on content_items columns selecting live items, see Item.IsLive. Alias is the
+// table alias used in the query, it may be empty.
+func LiveSQL(alias string) string {
+	if alias != "" {
+		alias += "."
+	}
+
+	return alias + "status = 'published' AND (" + alias + "expire_at IS NULL OR " + alias + "expire_at > now()) AND " +
+		alias + "deleted_at IS NULL"
+}
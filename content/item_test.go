@@ -0,0 +1,53 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/content"
+)
+
+func TestIsLive(t *testing.T) {
+	now := time.Now()
+	item := &content.Item{Status: content.StatusPublished}
+	require.True(t, item.IsLive(now))
+
+	item.ExpireAt = pgtype.Timestamptz{Time: now.Add(-time.Minute), Status: pgtype.Present}
+	require.False(t, item.IsLive(now))
+
+	item.ExpireAt = pgtype.Timestamptz{Status: pgtype.Null}
+	item.DeletedAt = pgtype.Timestamp{Time: now, Status: pgtype.Present}
+	require.False(t, item.IsLive(now))
+
+	item.DeletedAt = pgtype.Timestamp{Status: pgtype.Null}
+	item.Status = content.StatusDraft
+	require.False(t, item.IsLive(now))
+}
+
+func TestItemJSON(t *testing.T) {
+	deleted := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	item := &content.Item{Status: content.StatusPublished}
+
+	b, err := json.Marshal(item)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"deletedAt":null`)
+
+	item.DeletedAt = pgtype.Timestamp{Time: deleted, Status: pgtype.Present}
+	b, err = json.Marshal(item)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"deletedAt":"2021-05-01T12:00:00Z"`)
+}
+
+func TestLiveSQL(t *testing.T) {
+	require.Equal(t, "status = 'published' AND (expire_at IS NULL OR expire_at > now()) AND deleted_at IS NULL",
+		content.LiveSQL(""))
+	require.Contains(t, content.LiveSQL("i"), "i.deleted_at IS NULL")
+}
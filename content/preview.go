@@ -0,0 +1,63 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"ampho.xyz/core/security"
+)
+
+// previewAudience is the audience of preview tokens, so other tokens signed with the same key are not accepted.
+const previewAudience = "ampho.preview"
+
+// ErrInvalidPreviewToken is returned when a preview token is malformed, expired or not a preview token at all.
+var ErrInvalidPreviewToken = errors.New("invalid preview token")
+
+// PreviewClaims are the claims of a preview token granting read access to a single revision of an item. The subject
+// is the item UUID.
+type PreviewClaims struct {
+	jwt.StandardClaims
+	Revision int `json:"rev"`
+}
+
+// NewPreviewToken issues a signed token granting read access to a revision of an item for the ttl.
+func NewPreviewToken(uuid string, revision int, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(ttl)
+
+	token := security.NewTokenWithClaims(&PreviewClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  previewAudience,
+			Subject:   uuid,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: exp.Unix(),
+		},
+		Revision: revision,
+	})
+
+	s, err := security.GetTokenSignedString(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return s, exp, nil
+}
+
+// ParsePreviewToken verifies a preview token and returns its claims.
+func ParsePreviewToken(s string) (*PreviewClaims, error) {
+	claims := &PreviewClaims{}
+
+	token, err := security.ParseTokenWithClaims(s, claims)
+	if err != nil || !token.Valid || !claims.VerifyAudience(previewAudience, true) || claims.Subject == "" ||
+		claims.Revision < 1 {
+		return nil, ErrInvalidPreviewToken
+	}
+
+	return claims, nil
+}
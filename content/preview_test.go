@@ -0,0 +1,46 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/security"
+)
+
+func TestPreviewToken(t *testing.T) {
+	const uuid = "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"
+	security.SetHMACKey([]byte("secret"))
+
+	token, exp, err := content.NewPreviewToken(uuid, 3, time.Hour)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), exp, time.Second*5)
+
+	claims, err := content.ParsePreviewToken(token)
+	require.NoError(t, err)
+	require.Equal(t, uuid, claims.Subject)
+	require.Equal(t, 3, claims.Revision)
+
+	// Tampered
+	_, err = content.ParsePreviewToken(token + "x")
+	require.ErrorIs(t, err, content.ErrInvalidPreviewToken)
+
+	// Expired
+	token, _, err = content.NewPreviewToken(uuid, 3, -time.Minute)
+	require.NoError(t, err)
+	_, err = content.ParsePreviewToken(token)
+	require.ErrorIs(t, err, content.ErrInvalidPreviewToken)
+
+	// Not a preview token
+	token, err = security.GetTokenSignedString(security.NewTokenWithClaims(&jwt.StandardClaims{Subject: uuid}))
+	require.NoError(t, err)
+	_, err = content.ParsePreviewToken(token)
+	require.ErrorIs(t, err, content.ErrInvalidPreviewToken)
+}
@@ -0,0 +1,81 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/event"
+	"ampho.xyz/core/job"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/relation"
+	"ampho.xyz/core/service"
+)
+
+// Purger permanently deletes items which have been in the trash longer than the retention period, see Store.Purge.
+//
+// Items which cannot be purged because other entities refer to them with the restrict action, or live items refer
+// to them with the cascade action, are skipped and retried at the next run, and so are items failed to be purged for
+// other reasons. When several service instances run a purger against the same database, each item is purged and its
+// EventPurged is published once.
+type Purger struct {
+	store     *Store
+	bus       *event.Bus
+	retention time.Duration
+	job       *job.Periodic
+}
+
+// Attach binds the purger to a service lifecycle.
+func (p *Purger) Attach(svc service.Service) {
+	p.job.Attach(svc)
+}
+
+// Run purges all items whose retention period is over. Failures of single items are logged with the context logger,
+// see logger.FromContext, the rest are purged anyway.
+func (p *Purger) Run(ctx context.Context) error {
+	var uuids []string
+
+	err := p.store.db.SelectAll(ctx, &uuids, "SELECT uuid::text FROM content_items "+
+		"WHERE deleted_at <= now() - $1 * interval '1 millisecond' ORDER BY deleted_at", p.retention.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	for _, uuid := range uuids {
+		err = p.store.Purge(ctx, uuid)
+		if errors.Is(err, relation.ErrRestricted) {
+			logger.FromContext(ctx).Warn("content item is referenced and cannot be purged", "uuid", uuid, "error", err)
+			continue
+		} else if errors.Is(err, ErrNotFound) {
+			// Purged along with another item or by another instance
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.FromContext(ctx).Error("failed to purge content item", "uuid", uuid, "error", err)
+			continue
+		}
+
+		p.bus.Publish(ctx, EventPurged, uuid)
+	}
+
+	return nil
+}
+
+// NewPurger creates a new trash purger. Events are published to the bus.
+func NewPurger(cfg config.Config, db *database.Database, bus *event.Bus) *Purger {
+	cfg.SetDefault("content.trash.retention", DftTrashRetention)
+	cfg.SetDefault("content.trash.interval", DftPurgerInterval)
+
+	p := &Purger{store: NewStore(db), bus: bus, retention: cfg.GetDuration("content.trash.retention")}
+	p.job = job.NewPeriodic("content purger", cfg.GetDuration("content.trash.interval"), p.Run)
+
+	return p
+}
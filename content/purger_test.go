@@ -0,0 +1,66 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/event"
+	"ampho.xyz/core/relation"
+)
+
+func TestPurger(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+
+	types := content.NewRegistry(db)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "page", Fields: []content.Field{}}))
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "review", Fields: []content.Field{
+		{Name: "page", Type: content.FieldReference, OnDelete: relation.ActionRestrict},
+	}}))
+
+	store := content.NewStore(db)
+	create := func(typ string, data map[string]interface{}) string {
+		item := &content.Item{Type: typ, Data: data}
+		require.NoError(t, store.Create(ctx, item))
+		return item.GetUUID()
+	}
+	trash := func(uuid, ago string) {
+		_, err := db.Exec(ctx, "UPDATE content_items SET deleted_at = now() - $2::interval WHERE uuid = $1", uuid, ago)
+		require.NoError(t, err)
+	}
+
+	expired, recent, live, referenced := create("page", nil), create("page", nil), create("page", nil),
+		create("page", nil)
+	create("review", map[string]interface{}{"page": referenced})
+	trash(expired, "2 hours")
+	trash(recent, "30 minutes")
+	trash(referenced, "2 hours")
+
+	var purged []string
+	bus := event.NewBus()
+	bus.Subscribe(content.EventPurged, func(_ context.Context, e event.Event) {
+		purged = append(purged, e.Payload.(string))
+	})
+
+	cfg := config.NewTesting("content")
+	cfg.Set("content.trash.retention", "1h")
+	require.NoError(t, content.NewPurger(cfg, db, bus).Run(ctx))
+
+	// Only the item past the retention period is purged, the referenced one is skipped
+	require.Equal(t, []string{expired}, purged)
+	require.Equal(t, content.ErrNotFound, store.Purge(ctx, expired))
+
+	var count int
+	require.NoError(t, db.QueryRow(ctx, "SELECT count(*) FROM content_items WHERE uuid = ANY($1)",
+		[]string{recent, live, referenced}).Scan(&count))
+	require.Equal(t, 3, count)
+}
@@ -0,0 +1,147 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+)
+
+var (
+	// ErrTypeNotFound is returned when a content type does not exist.
+	ErrTypeNotFound = errors.New("content type not found")
+
+	// ErrTypeExists is returned on attempt to create a content type with the name of an existing one.
+	ErrTypeExists = errors.New("content type already exists")
+
+	// ErrTypeInUse is returned on attempt to delete a content type which still has items.
+	ErrTypeInUse = errors.New("content type has items")
+)
+
+const typeColumns = "id, uuid, created_at, updated_at, deleted_at, name, title, description, fields"
+
+// Registry is a content types registry stored in the database.
+type Registry struct {
+	db *database.Database
+}
+
+// Get returns a type by its name.
+func (r *Registry) Get(ctx context.Context, name string) (*Type, error) {
+	t := &Type{}
+
+	err := r.db.SelectOne(ctx, t, "SELECT "+typeColumns+" FROM content_types WHERE name = $1", name)
+	if pgxscan.NotFound(err) {
+		return nil, ErrTypeNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// List returns all types ordered by name.
+func (r *Registry) List(ctx context.Context) ([]*Type, error) {
+	var types []*Type
+
+	if err := r.db.SelectAll(ctx, &types, "SELECT "+typeColumns+" FROM content_types ORDER BY name"); err != nil {
+		return nil, err
+	}
+
+	return types, nil
+}
+
+// Version returns a string which changes whenever types are created, updated or deleted.
+func (r *Registry) Version(ctx context.Context) (string, error) {
+	var v string
+
+	err := r.db.QueryRow(ctx, "SELECT count(*)::text || '/' || COALESCE(max(updated_at)::text, '') "+
+		"FROM content_types").Scan(&v)
+	if err != nil {
+		return "", err
+	}
+
+	return v, nil
+}
+
+// Create creates a new type. If a type with the same name exists, it returns ErrTypeExists. On success the type is
+// filled with stored values.
+func (r *Registry) Create(ctx context.Context, t *Type) error {
+	if err := t.Check(); err != nil {
+		return err
+	}
+
+	if t.Fields == nil {
+		t.Fields = []Field{}
+	}
+
+	err := r.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return pgxscan.Get(ctx, tx, t, "INSERT INTO content_types (name, title, description, fields) "+
+			"VALUES ($1, $2, $3, $4) RETURNING "+typeColumns, t.Name, t.Title, t.Description, t.Fields)
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrTypeExists
+	}
+
+	return err
+}
+
+// Save creates a new type or updates an existing one with the same name. On success the type is filled with stored
+// values.
+func (r *Registry) Save(ctx context.Context, t *Type) error {
+	if err := t.Check(); err != nil {
+		return err
+	}
+
+	if t.Fields == nil {
+		t.Fields = []Field{}
+	}
+
+	return r.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return pgxscan.Get(ctx, tx, t, "INSERT INTO content_types (name, title, description, fields) "+
+			"VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO UPDATE SET title = excluded.title, "+
+			"description = excluded.description, fields = excluded.fields, updated_at = now() "+
+			"RETURNING "+typeColumns, t.Name, t.Title, t.Description, t.Fields)
+	})
+}
+
+// Delete deletes a type. A type which still has items cannot be deleted. The type row is locked, so items of the
+// type cannot be created concurrently, see Store.Create.
+func (r *Registry) Delete(ctx context.Context, name string) error {
+	return r.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var id int64
+		err := tx.QueryRow(ctx, "SELECT id FROM content_types WHERE name = $1 FOR UPDATE", name).Scan(&id)
+		if err == pgx.ErrNoRows {
+			return ErrTypeNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var inUse bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM content_items WHERE type = $1)", name).Scan(&inUse)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrTypeInUse
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM content_types WHERE id = $1", id)
+
+		return err
+	})
+}
+
+// NewRegistry creates a new content types registry.
+func NewRegistry(db *database.Database) *Registry {
+	return &Registry{db}
+}
@@ -0,0 +1,83 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/event"
+	"ampho.xyz/core/job"
+	"ampho.xyz/core/service"
+)
+
+// Scheduler publishes and expires scheduled content items when their time comes.
+//
+// Every state change is made by a single conditional UPDATE which skips rows locked by concurrent transactions, so
+// when several service instances run a scheduler against the same database each item changes its state exactly once,
+// and only the instance which made the change publishes the corresponding event.
+type Scheduler struct {
+	db  *database.Database
+	bus *event.Bus
+	job *job.Periodic
+}
+
+// Attach binds the scheduler to a service lifecycle.
+func (s *Scheduler) Attach(svc service.Service) {
+	s.job.Attach(svc)
+}
+
+// Run publishes and expires all items whose time has come.
+func (s *Scheduler) Run(ctx context.Context) error {
+	published, err := s.transit(ctx, "UPDATE content_items SET status = 'published', published_at = now(), "+
+		"updated_at = now() WHERE id IN (SELECT id FROM content_items WHERE status = 'scheduled' "+
+		"AND publish_at <= now() AND (expire_at IS NULL OR expire_at > now()) AND deleted_at IS NULL "+
+		"FOR UPDATE SKIP LOCKED) "+
+		"RETURNING "+itemColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range published {
+		s.bus.Publish(ctx, EventPublished, item)
+	}
+
+	expired, err := s.transit(ctx, "UPDATE content_items SET status = 'expired', updated_at = now() "+
+		"WHERE id IN (SELECT id FROM content_items WHERE status IN ('scheduled', 'published') "+
+		"AND expire_at <= now() AND deleted_at IS NULL FOR UPDATE SKIP LOCKED) RETURNING "+itemColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range expired {
+		s.bus.Publish(ctx, EventExpired, item)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) transit(ctx context.Context, sql string) ([]*Item, error) {
+	var items []*Item
+
+	err := s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return pgxscan.Select(ctx, tx, &items, sql)
+	})
+
+	return items, err
+}
+
+// NewScheduler creates a new content scheduler. Events are published to the bus.
+func NewScheduler(cfg config.Config, db *database.Database, bus *event.Bus) *Scheduler {
+	cfg.SetDefault("content.scheduler.interval", DftSchedulerInterval)
+
+	s := &Scheduler{db: db, bus: bus}
+	s.job = job.NewPeriodic("content scheduler", cfg.GetDuration("content.scheduler.interval"), s.Run)
+
+	return s
+}
@@ -0,0 +1,117 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/event"
+)
+
+func TestSchedulerConcurrently(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+	require.NoError(t, content.NewRegistry(db).Create(ctx, &content.Type{Name: "page", Fields: []content.Field{}}))
+
+	store := content.NewStore(db)
+	schedule := func(publishIn, expireIn time.Duration) string {
+		item := &content.Item{Type: "page"}
+		require.NoError(t, store.Create(ctx, item))
+
+		publishAt, expireAt := time.Now().Add(publishIn), time.Now().Add(expireIn)
+		item, err := store.Schedule(ctx, item.GetUUID(), &publishAt, &expireAt)
+		require.NoError(t, err)
+		require.Equal(t, content.StatusScheduled, item.Status)
+
+		return item.GetUUID()
+	}
+
+	var due, expired, later []string
+	for i := 0; i < 10; i++ {
+		due = append(due, schedule(-time.Minute, time.Hour))
+		expired = append(expired, schedule(-2*time.Hour, -time.Hour))
+		later = append(later, schedule(time.Hour, 2*time.Hour))
+	}
+
+	var mu sync.Mutex
+	events := make(map[string]map[string]int) // counts by event names and item UUIDs
+	bus := event.NewBus()
+	bus.Subscribe("", func(ctx context.Context, e event.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if events[e.Name] == nil {
+			events[e.Name] = make(map[string]int)
+		}
+		events[e.Name][e.Payload.(*content.Item).GetUUID()]++
+	})
+
+	// Two instances run at once, several times
+	run := func() {
+		schedulers := []*content.Scheduler{
+			content.NewScheduler(config.NewTesting("content"), db, bus),
+			content.NewScheduler(config.NewTesting("content"), db, bus),
+		}
+
+		for i := 0; i < 3; i++ {
+			errs := make([]error, len(schedulers))
+
+			var wg sync.WaitGroup
+			for j, s := range schedulers {
+				wg.Add(1)
+				go func(j int, s *content.Scheduler) {
+					defer wg.Done()
+					errs[j] = s.Run(ctx)
+				}(j, s)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	status := func(uuid string) content.Status {
+		var s content.Status
+		require.NoError(t, db.QueryRow(ctx, "SELECT status FROM content_items WHERE uuid = $1", uuid).Scan(&s))
+		return s
+	}
+
+	run()
+	require.Len(t, events[content.EventPublished], len(due))
+	require.Len(t, events[content.EventExpired], len(expired))
+	for _, uuid := range due {
+		require.Equal(t, 1, events[content.EventPublished][uuid])
+		require.Equal(t, content.StatusPublished, status(uuid))
+	}
+	for _, uuid := range expired {
+		require.Equal(t, 1, events[content.EventExpired][uuid])
+		require.Equal(t, content.StatusExpired, status(uuid))
+	}
+	for _, uuid := range later {
+		require.Equal(t, content.StatusScheduled, status(uuid))
+	}
+
+	// Published items expire once as well
+	_, err := db.Exec(ctx, "UPDATE content_items SET expire_at = now() WHERE uuid = ANY($1)", due)
+	require.NoError(t, err)
+
+	run()
+	require.Len(t, events[content.EventPublished], len(due))
+	require.Len(t, events[content.EventExpired], len(due)+len(expired))
+	for _, uuid := range due {
+		require.Equal(t, 1, events[content.EventPublished][uuid])
+		require.Equal(t, 1, events[content.EventExpired][uuid])
+		require.Equal(t, content.StatusExpired, status(uuid))
+	}
+}
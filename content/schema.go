@@ -0,0 +1,93 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+// JSONSchema returns a JSON Schema (draft 2020-12) document describing item data of the type.
+func (t *Type) JSONSchema() map[string]interface{} {
+	props := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for _, f := range t.Fields {
+		props[f.Name] = f.jsonSchema()
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	r := map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  t.Name,
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+
+	if t.Title != "" {
+		r["title"] = t.Title
+	}
+	if t.Description != "" {
+		r["description"] = t.Description
+	}
+
+	return r
+}
+
+func (f *Field) jsonSchema() map[string]interface{} {
+	vl := f.Validation
+	s := make(map[string]interface{})
+
+	switch f.Type {
+	case FieldString, FieldText, FieldRichText:
+		s["type"] = "string"
+	case FieldDateTime:
+		s["type"] = "string"
+		s["format"] = "date-time"
+	case FieldReference, FieldMedia:
+		s["type"] = "string"
+		s["format"] = "uuid"
+	case FieldInteger:
+		s["type"] = "integer"
+	case FieldNumber:
+		s["type"] = "number"
+	case FieldBoolean:
+		s["type"] = "boolean"
+	}
+
+	if vl.MinLength != nil {
+		s["minLength"] = *vl.MinLength
+	}
+	if vl.MaxLength != nil {
+		s["maxLength"] = *vl.MaxLength
+	}
+	if vl.Pattern != "" {
+		s["pattern"] = vl.Pattern
+	}
+	if vl.Minimum != nil {
+		s["minimum"] = *vl.Minimum
+	}
+	if vl.Maximum != nil {
+		s["maximum"] = *vl.Maximum
+	}
+	if len(vl.Enum) > 0 {
+		s["enum"] = vl.Enum
+	}
+
+	if f.Multiple {
+		s = map[string]interface{}{"type": "array", "items": s}
+		if vl.MinItems != nil {
+			s["minItems"] = *vl.MinItems
+		}
+		if vl.MaxItems != nil {
+			s["maxItems"] = *vl.MaxItems
+		}
+	}
+
+	if f.Title != "" {
+		s["title"] = f.Title
+	}
+
+	return s
+}
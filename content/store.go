@@ -0,0 +1,603 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/relation"
+)
+
+// ErrNotFound is returned when a content item does not exist or is in the trash.
+var ErrNotFound = errors.New("content item not found")
+
+const itemColumns = "id, uuid, created_at, updated_at, deleted_at, type, status, revision, data, publish_at, " +
+	"expire_at, published_at"
+
+// WriteCheck checks an item may be written within the transaction writing it, e.g. that it is not locked by another
+// user, see lock.Locks.CheckTx. If it returns an error, nothing is written.
+type WriteCheck func(ctx context.Context, tx pgx.Tx, uuid string) error
+
+// Store is a content items storage.
+type Store struct {
+	db *database.Database
+}
+
+// DB returns the underlying database.
+func (s *Store) DB() *database.Database {
+	return s.db
+}
+
+// Get returns an item by its UUID. Items in the trash are not returned.
+func (s *Store) Get(ctx context.Context, uuid string) (*Item, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	item := &Item{}
+
+	err := s.db.SelectOne(ctx, item, "SELECT "+itemColumns+" FROM content_items WHERE uuid = $1 AND "+
+		"deleted_at IS NULL", uuid)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Cond is an extra SQL condition on content_items columns. Placeholders are numbered from $1 within the condition.
+type Cond struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Where joins conditions with AND and appends their arguments to args. Placeholders of the conditions are
+// renumbered to follow args. It returns an empty string if there are no conditions.
+func Where(args []interface{}, conds ...Cond) (string, []interface{}) {
+	where := make([]string, 0, len(conds))
+	for _, c := range conds {
+		where = append(where, "("+renumber(c.SQL, len(args))+")")
+		args = append(args, c.Args...)
+	}
+
+	return strings.Join(where, " AND "), args
+}
+
+// Query describes a list of items to select.
+type Query struct {
+	Type    string // items of the type only, all types if empty
+	Status  Status // items with the status only, any status if empty
+	Where   []Cond // extra conditions
+	Deleted bool   // items in the trash instead of the other ones
+	OrderBy string // SQL ORDER BY expression, the latest updated first or, in the trash, the latest deleted first
+	Limit   int    // maximum number of items, no limit if zero
+	Offset  int    // number of items to skip
+}
+
+// where returns the WHERE clause of the query, including the keyword, and its arguments.
+func (q *Query) where() (string, []interface{}) {
+	conds := make([]Cond, 0, len(q.Where)+3)
+	if q.Deleted {
+		conds = append(conds, Cond{SQL: "deleted_at IS NOT NULL"})
+	} else {
+		conds = append(conds, Cond{SQL: "deleted_at IS NULL"})
+	}
+	if q.Type != "" {
+		conds = append(conds, Cond{"type = $1", []interface{}{q.Type}})
+	}
+	if q.Status != "" {
+		conds = append(conds, Cond{"status = $1", []interface{}{q.Status}})
+	}
+	conds = append(conds, q.Where...)
+
+	where, args := Where(nil, conds...)
+	if where != "" {
+		where = " WHERE " + where
+	}
+
+	return where, args
+}
+
+// List returns items matching the query.
+func (s *Store) List(ctx context.Context, q Query) ([]*Item, error) {
+	var items []*Item
+
+	where, args := q.where()
+	orderBy := q.OrderBy
+	if orderBy == "" && q.Deleted {
+		orderBy = "deleted_at DESC"
+	} else if orderBy == "" {
+		orderBy = "updated_at DESC"
+	}
+
+	sql := "SELECT " + itemColumns + " FROM content_items" + where + " ORDER BY " + orderBy + ", id DESC"
+	if q.Limit > 0 {
+		sql += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+	if q.Offset > 0 {
+		sql += " OFFSET " + strconv.Itoa(q.Offset)
+	}
+
+	if err := s.db.SelectAll(ctx, &items, sql, args...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Count returns the number of items matching the query. Limit and Offset are ignored.
+func (s *Store) Count(ctx context.Context, q Query) (int, error) {
+	var n int
+
+	where, args := q.where()
+	if err := s.db.QueryRow(ctx, "SELECT count(*) FROM content_items"+where, args...).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Create stores a new item and its first revision. If the item UUID is not set, it is generated. The item type must
+// be registered, otherwise ErrTypeNotFound is returned; it is locked, so it cannot be deleted concurrently. On success
+// the item is filled with stored values.
+func (s *Store) Create(ctx context.Context, item *Item) error {
+	if item.Data == nil {
+		item.Data = make(map[string]interface{})
+	}
+
+	var uuid interface{}
+	if item.UUID.Status == pgtype.Present {
+		uuid = item.UUID
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var typeID int64
+		err := tx.QueryRow(ctx, "SELECT id FROM content_types WHERE name = $1 FOR SHARE", item.Type).Scan(&typeID)
+		if err == pgx.ErrNoRows {
+			return ErrTypeNotFound
+		} else if err != nil {
+			return err
+		}
+
+		err = pgxscan.Get(ctx, tx, item, "INSERT INTO content_items (uuid, type, data) "+
+			"VALUES (COALESCE($1, gen_random_uuid()), $2, $3) RETURNING "+itemColumns, uuid, item.Type, item.Data)
+		if err != nil {
+			return err
+		}
+
+		if err = insertRevision(ctx, tx, item); err != nil {
+			return err
+		}
+
+		return syncRelations(ctx, tx, item)
+	})
+}
+
+// Update stores new item data as a next revision, if the checks pass. On success the item is filled with stored
+// values.
+func (s *Store) Update(ctx context.Context, item *Item, checks ...WriteCheck) error {
+	if item.Data == nil {
+		item.Data = make(map[string]interface{})
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if err := runChecks(ctx, tx, item.GetUUID(), checks); err != nil {
+			return err
+		}
+
+		err := pgxscan.Get(ctx, tx, item, "UPDATE content_items SET data = $2, revision = revision + 1, "+
+			"updated_at = now() WHERE uuid = $1 AND deleted_at IS NULL RETURNING "+itemColumns, item.GetUUID(),
+			item.Data)
+		if pgxscan.NotFound(err) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if err = insertRevision(ctx, tx, item); err != nil {
+			return err
+		}
+
+		return syncRelations(ctx, tx, item)
+	})
+}
+
+// Delete moves an item to the trash along with the items referring to it with the cascade delete action. Items in
+// the trash are neither listed nor served until they are restored, see Restore, and are deleted permanently when
+// purged, see Purge. If an entity refers to the item with the restrict action, nothing is deleted and
+// relation.RestrictedError is returned. So it is if the checks of the item fail.
+func (s *Store) Delete(ctx context.Context, uuid string, checks ...WriteCheck) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if err := runChecks(ctx, tx, uuid, checks); err != nil {
+			return err
+		}
+
+		return trashItem(ctx, tx, uuid, make(map[string]bool))
+	})
+}
+
+// Restore takes an item out of the trash. Items moved to the trash along with it stay there.
+func (s *Store) Restore(ctx context.Context, uuid string) (*Item, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	item := &Item{}
+
+	err := s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return pgxscan.Get(ctx, tx, item, "UPDATE content_items SET deleted_at = NULL, updated_at = now() "+
+			"WHERE uuid = $1 AND deleted_at IS NOT NULL RETURNING "+itemColumns, uuid)
+	})
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Purge permanently deletes an item in the trash with all its revisions, variants and relations, and applies delete
+// actions of relations referring to it: items referring to it with the cascade action are deleted as well, and it is
+// removed from data of items referring to it with the nullify action. If an entity refers to it with the restrict
+// action, nothing is deleted and relation.RestrictedError is returned. So it is if an item referring to it with the
+// cascade action is not in the trash, e.g. it has been restored.
+func (s *Store) Purge(ctx context.Context, uuid string) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var id int64
+		err := tx.QueryRow(ctx, "SELECT id FROM content_items WHERE uuid = $1 AND deleted_at IS NOT NULL "+
+			"FOR UPDATE", uuid).Scan(&id)
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+
+		return deleteItem(ctx, tx, uuid, make(map[string]bool))
+	})
+}
+
+// Import stores an item with its revisions as they are, keyed by the item UUID. An existing item is overwritten,
+// revisions are added or replaced by number and revisions newer than the item revision are deleted, so importing
+// the same item again leaves the storage unchanged. On success the item is filled with stored values.
+func (s *Store) Import(ctx context.Context, item *Item, revisions []*Revision) error {
+	if item.Data == nil {
+		item.Data = make(map[string]interface{})
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		err := pgxscan.Get(ctx, tx, item, "INSERT INTO content_items (uuid, created_at, updated_at, type, status, "+
+			"revision, data, publish_at, expire_at, published_at) "+
+			"VALUES ($1, COALESCE($2, now()), COALESCE($3, now()), $4, $5, $6, $7, $8, $9, $10) "+
+			"ON CONFLICT (uuid) DO UPDATE SET created_at = excluded.created_at, updated_at = excluded.updated_at, "+
+			"type = excluded.type, status = excluded.status, revision = excluded.revision, data = excluded.data, "+
+			"publish_at = excluded.publish_at, expire_at = excluded.expire_at, published_at = excluded.published_at "+
+			"RETURNING "+itemColumns, item.GetUUID(), entityTime(item.CreatedAt), entityTime(item.UpdatedAt),
+			item.Type, item.Status, item.Revision, item.Data, timeOrNil(item.PublishAt), timeOrNil(item.ExpireAt),
+			timeOrNil(item.PublishedAt))
+		if err != nil {
+			return err
+		}
+
+		current := false
+		for _, r := range revisions {
+			_, err = tx.Exec(ctx, "INSERT INTO content_revisions (item_id, number, data, created_at) "+
+				"VALUES ($1, $2, $3, COALESCE($4, now())) ON CONFLICT (item_id, number) DO UPDATE "+
+				"SET data = excluded.data, created_at = excluded.created_at",
+				item.ID, r.Number, r.Data, timeOrNil(r.CreatedAt))
+			if err != nil {
+				return err
+			}
+			current = current || r.Number == item.Revision
+		}
+
+		if !current {
+			_, err = tx.Exec(ctx, "INSERT INTO content_revisions (item_id, number, data) VALUES ($1, $2, $3) "+
+				"ON CONFLICT (item_id, number) DO UPDATE SET data = excluded.data", item.ID, item.Revision, item.Data)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM content_revisions WHERE item_id = $1 AND number > $2", item.ID,
+			item.Revision)
+		if err != nil {
+			return err
+		}
+
+		return syncRelations(ctx, tx, item)
+	})
+}
+
+// Revisions returns all revisions of an item, the latest first.
+func (s *Store) Revisions(ctx context.Context, uuid string) ([]*Revision, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	var r []*Revision
+
+	err := s.db.SelectAll(ctx, &r, "SELECT r.id, r.item_id, r.number, r.data, r.created_at FROM content_revisions r "+
+		"JOIN content_items i ON i.id = r.item_id WHERE i.uuid = $1 ORDER BY r.number DESC", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Revision returns a revision of an item by its number.
+func (s *Store) Revision(ctx context.Context, uuid string, number int) (*Revision, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	r := &Revision{}
+
+	err := s.db.SelectOne(ctx, r, "SELECT r.id, r.item_id, r.number, r.data, r.created_at FROM content_revisions r "+
+		"JOIN content_items i ON i.id = r.item_id WHERE i.uuid = $1 AND r.number = $2", uuid, number)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Schedule sets the time an item should be published and the time it should expire. Either time may be nil.
+//
+// An unpublished item with the publish time set becomes scheduled, a scheduled item without the publish time becomes
+// a draft again. Actual publishing and expiring is done by Scheduler. If both times are set, the expire time must
+// be later than the publish time, otherwise ValidationError is returned. Nothing is changed if the checks fail.
+func (s *Store) Schedule(ctx context.Context, uuid string, publishAt, expireAt *time.Time,
+	checks ...WriteCheck) (*Item, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+	if publishAt != nil && expireAt != nil && !expireAt.After(*publishAt) {
+		return nil, ValidationError{"expireAt": "must be later than publishAt"}
+	}
+
+	item := &Item{}
+
+	err := s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if err := runChecks(ctx, tx, uuid, checks); err != nil {
+			return err
+		}
+
+		return pgxscan.Get(ctx, tx, item, "UPDATE content_items SET publish_at = $2, expire_at = $3, "+
+			"status = CASE "+
+			"WHEN $2::timestamptz IS NOT NULL AND status <> 'published' THEN 'scheduled' "+
+			"WHEN $2::timestamptz IS NULL AND status = 'scheduled' THEN 'draft' "+
+			"ELSE status END, "+
+			"updated_at = now() WHERE uuid = $1 AND deleted_at IS NULL RETURNING "+itemColumns,
+			uuid, timestamptz(publishAt), timestamptz(expireAt))
+	})
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func runChecks(ctx context.Context, tx pgx.Tx, uuid string, checks []WriteCheck) error {
+	for _, check := range checks {
+		if err := check(ctx, tx, uuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var placeholderRe = regexp.MustCompile(`\$(\d+)`)
+
+// renumber shifts placeholder numbers in an SQL fragment by offset.
+func renumber(sql string, offset int) string {
+	return placeholderRe.ReplaceAllStringFunc(sql, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		return "$" + strconv.Itoa(n+offset)
+	})
+}
+
+func insertRevision(ctx context.Context, tx pgx.Tx, item *Item) error {
+	_, err := tx.Exec(ctx, "INSERT INTO content_revisions (item_id, number, data) VALUES ($1, $2, $3)",
+		item.ID, item.Revision, item.Data)
+
+	return err
+}
+
+// syncRelations stores references of item reference and media fields as relations named after the fields. Items of
+// unknown types have no relations.
+func syncRelations(ctx context.Context, tx pgx.Tx, item *Item) error {
+	var fields []Field
+
+	err := tx.QueryRow(ctx, "SELECT fields FROM content_types WHERE name = $1", item.Type).Scan(&fields)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+
+	var refs []relation.Ref
+	for _, f := range fields {
+		if f.Type != FieldReference && f.Type != FieldMedia {
+			continue
+		}
+
+		for _, target := range refTargets(item.Data[f.Name]) {
+			refs = append(refs, relation.Ref{Name: f.Name, Target: target, OnDelete: f.OnDelete})
+		}
+	}
+
+	return relation.SetTx(ctx, tx, item.GetUUID(), refs)
+}
+
+// refTargets returns UUIDs of a single or multiple reference field value.
+func refTargets(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		r := make([]string, 0, len(v))
+		for _, u := range v {
+			if s, ok := u.(string); ok {
+				r = append(r, s)
+			}
+		}
+		return r
+	}
+
+	return nil
+}
+
+// trashItem moves an item and the items referring to it with the cascade action to the trash. Trashed items are
+// tracked to stop at reference cycles.
+func trashItem(ctx context.Context, tx pgx.Tx, uuid string, trashed map[string]bool) error {
+	trashed[uuid] = true
+
+	effects, err := relation.EffectsTx(ctx, tx, uuid)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, "UPDATE content_items SET deleted_at = now() WHERE uuid = $1 AND deleted_at IS NULL",
+		uuid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	for _, source := range effects.Cascade {
+		if trashed[source] {
+			continue
+		}
+
+		// Sources may be other entities than content items or be in the trash already
+		if err = trashItem(ctx, tx, source, trashed); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteItem deletes an item and applies delete actions of relations referring to it. Deleted items are tracked to
+// stop at reference cycles.
+func deleteItem(ctx context.Context, tx pgx.Tx, uuid string, deleted map[string]bool) error {
+	deleted[uuid] = true
+
+	// Items referring to the item with the cascade action are deleted only if they are in the trash: they may have
+	// been restored or created since the item was trashed
+	var live []*relation.Relation
+	err := pgxscan.Select(ctx, tx, &live, "SELECT r.source_uuid::text AS source_uuid, "+
+		"r.target_uuid::text AS target_uuid, r.name, r.position, r.on_delete FROM relations r "+
+		"JOIN content_items i ON i.uuid = r.source_uuid WHERE r.target_uuid = $1 AND r.source_uuid <> $1 "+
+		"AND r.on_delete = $2 AND i.deleted_at IS NULL ORDER BY r.id", uuid, string(relation.ActionCascade))
+	if err != nil {
+		return err
+	}
+	if len(live) > 0 {
+		return relation.RestrictedError(live)
+	}
+
+	effects, err := relation.DeleteTx(ctx, tx, uuid)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range effects.Nullify {
+		if !deleted[rel.Source] {
+			if err = nullify(ctx, tx, rel); err != nil {
+				return err
+			}
+		}
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM content_items WHERE uuid = $1", uuid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	for _, source := range effects.Cascade {
+		if deleted[source] {
+			continue
+		}
+
+		// Sources may be other entities than content items, they are deleted by their storages
+		if err = deleteItem(ctx, tx, source, deleted); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nullify removes the target of a relation from the field of the source item data, storing a new revision.
+func nullify(ctx context.Context, tx pgx.Tx, rel *relation.Relation) error {
+	item := &Item{}
+
+	err := pgxscan.Get(ctx, tx, item, "SELECT "+itemColumns+" FROM content_items WHERE uuid = $1 FOR UPDATE",
+		rel.Source)
+	if pgxscan.NotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch v := item.Data[rel.Name].(type) {
+	case string:
+		if v == rel.Target {
+			delete(item.Data, rel.Name)
+		}
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, u := range v {
+			if u != rel.Target {
+				list = append(list, u)
+			}
+		}
+		item.Data[rel.Name] = list
+	}
+
+	err = pgxscan.Get(ctx, tx, item, "UPDATE content_items SET data = $2, revision = revision + 1, "+
+		"updated_at = now() WHERE uuid = $1 RETURNING "+itemColumns, rel.Source, item.Data)
+	if err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, item)
+}
+
+// NewStore creates a new content items storage.
+func NewStore(db *database.Database) *Store {
+	return &Store{db}
+}
@@ -0,0 +1,164 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/lock"
+	"ampho.xyz/core/relation"
+)
+
+func TestStoreChecks(t *testing.T) {
+	// Checked before reaching the database
+	store := content.NewStore(nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := store.Revisions(ctx, "1")
+	require.Equal(t, content.ErrNotFound, err)
+	_, err = store.Schedule(ctx, "1", &now, nil)
+	require.Equal(t, content.ErrNotFound, err)
+
+	for _, expireAt := range []time.Time{now, now.Add(-time.Hour)} {
+		_, err = store.Schedule(ctx, "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f0", &now, &expireAt)
+		var vErr content.ValidationError
+		require.ErrorAs(t, err, &vErr)
+		require.Contains(t, vErr, "expireAt")
+	}
+}
+
+func TestPurgeRestoredCascade(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+
+	require.NoError(t, content.NewRegistry(db).Create(ctx, &content.Type{Name: "comment", Fields: []content.Field{
+		{Name: "parent", Type: content.FieldReference, OnDelete: relation.ActionCascade},
+	}}))
+
+	store := content.NewStore(db)
+	parent := &content.Item{Type: "comment"}
+	require.NoError(t, store.Create(ctx, parent))
+	child := &content.Item{Type: "comment", Data: map[string]interface{}{"parent": parent.GetUUID()}}
+	require.NoError(t, store.Create(ctx, child))
+
+	// The child is trashed along with the parent, but then restored
+	require.NoError(t, store.Delete(ctx, parent.GetUUID()))
+	_, err := store.Get(ctx, child.GetUUID())
+	require.Equal(t, content.ErrNotFound, err)
+	_, err = store.Restore(ctx, child.GetUUID())
+	require.NoError(t, err)
+
+	err = store.Purge(ctx, parent.GetUUID())
+	require.ErrorIs(t, err, relation.ErrRestricted)
+	_, err = store.Get(ctx, child.GetUUID())
+	require.NoError(t, err)
+
+	// Trashed again, both are purged
+	require.NoError(t, store.Delete(ctx, child.GetUUID()))
+	require.NoError(t, store.Purge(ctx, parent.GetUUID()))
+	require.Equal(t, content.ErrNotFound, store.Purge(ctx, child.GetUUID()))
+}
+
+func TestPurgeCascadeChain(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+
+	types := content.NewRegistry(db)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "author", Fields: []content.Field{}}))
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "post", Fields: []content.Field{
+		{Name: "author", Type: content.FieldReference, OnDelete: relation.ActionCascade},
+	}}))
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "comment", Fields: []content.Field{
+		{Name: "post", Type: content.FieldReference, OnDelete: relation.ActionCascade},
+		{Name: "mention", Type: content.FieldReference, OnDelete: relation.ActionNullify},
+	}}))
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "review", Fields: []content.Field{
+		{Name: "post", Type: content.FieldReference, OnDelete: relation.ActionRestrict},
+	}}))
+
+	store := content.NewStore(db)
+	create := func(typ string, data map[string]interface{}) string {
+		item := &content.Item{Type: typ, Data: data}
+		require.NoError(t, store.Create(ctx, item))
+		return item.GetUUID()
+	}
+
+	author := create("author", nil)
+	post := create("post", map[string]interface{}{"author": author})
+	comment := create("comment", map[string]interface{}{"post": post})
+	other := create("post", nil)
+	mention := create("comment", map[string]interface{}{"post": other, "mention": author})
+	review := create("review", map[string]interface{}{"post": post})
+
+	// Restricted by the review, nothing is trashed
+	require.ErrorIs(t, store.Delete(ctx, author), relation.ErrRestricted)
+	_, err := store.Get(ctx, comment)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Delete(ctx, review))
+	require.NoError(t, store.Purge(ctx, review))
+
+	// The chain is trashed and purged along with the author
+	require.NoError(t, store.Delete(ctx, author))
+	for _, uuid := range []string{post, comment} {
+		_, err = store.Get(ctx, uuid)
+		require.Equal(t, content.ErrNotFound, err)
+	}
+
+	require.NoError(t, store.Purge(ctx, author))
+	for _, uuid := range []string{post, comment} {
+		require.Equal(t, content.ErrNotFound, store.Purge(ctx, uuid))
+	}
+
+	// The nullified reference is removed from a new revision
+	item, err := store.Get(ctx, mention)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"post": other}, item.Data)
+	require.Equal(t, 2, item.Revision)
+
+	rels, err := relation.New(db).Targets(ctx, mention, "")
+	require.NoError(t, err)
+	require.Len(t, rels, 1)
+	require.Equal(t, other, rels[0].Target)
+}
+
+func TestUpdateLocked(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+	require.NoError(t, content.NewRegistry(db).Create(ctx, &content.Type{Name: "page", Fields: []content.Field{}}))
+
+	store := content.NewStore(db)
+	item := &content.Item{Type: "page"}
+	require.NoError(t, store.Create(ctx, item))
+
+	locks := lock.New(config.NewTesting("content"), db)
+	_, err := locks.Acquire(ctx, item.GetUUID(), "ann", 0)
+	require.NoError(t, err)
+	checks := func(owner string) content.WriteCheck {
+		return func(ctx context.Context, tx pgx.Tx, uuid string) error {
+			return locks.CheckTx(ctx, tx, uuid, owner)
+		}
+	}
+
+	// Nothing is written by another user
+	item.Data = map[string]interface{}{"title": "Bob's"}
+	require.ErrorIs(t, store.Update(ctx, item, checks("bob")), lock.ErrLocked)
+	require.ErrorIs(t, store.Delete(ctx, item.GetUUID(), checks("bob")), lock.ErrLocked)
+	stored, err := store.Get(ctx, item.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, 1, stored.Revision)
+
+	require.NoError(t, store.Update(ctx, item, checks("ann")))
+	require.Equal(t, 2, item.Revision)
+}
@@ -0,0 +1,163 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"ampho.xyz/core/database"
+)
+
+// ValidationError is returned when content item data does not conform to its type schema. It maps field names to
+// problem descriptions.
+type ValidationError map[string]string
+
+// Error implements error.
+func (e ValidationError) Error() string {
+	var fields []string
+	for k := range e {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f + ": " + e[f]
+	}
+
+	return "invalid content: " + strings.Join(msgs, "; ")
+}
+
+// Validate validates item data against the type schema. It enforces the same rules as the JSON Schema returned by
+// JSONSchema.
+func (t *Type) Validate(data map[string]interface{}) error {
+	errs := make(ValidationError)
+
+	for k := range data {
+		if t.Field(k) == nil {
+			errs[k] = "unknown field"
+		}
+	}
+
+	for _, f := range t.Fields {
+		v, ok := data[f.Name]
+		if !ok || v == nil {
+			if f.Required {
+				errs[f.Name] = "required"
+			}
+			continue
+		}
+
+		if err := f.validate(v); err != nil {
+			errs[f.Name] = err.Error()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (f *Field) validate(v interface{}) error {
+	if !f.Multiple {
+		return f.validateValue(v)
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("must be an array")
+	}
+
+	if f.Validation.MinItems != nil && len(items) < *f.Validation.MinItems {
+		return fmt.Errorf("must contain at least %d items", *f.Validation.MinItems)
+	}
+	if f.Validation.MaxItems != nil && len(items) > *f.Validation.MaxItems {
+		return fmt.Errorf("must contain at most %d items", *f.Validation.MaxItems)
+	}
+
+	for i, item := range items {
+		if err := f.validateValue(item); err != nil {
+			return fmt.Errorf("item %d %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *Field) validateValue(v interface{}) error {
+	vl := f.Validation
+
+	switch f.Type {
+	case FieldString, FieldText, FieldRichText, FieldDateTime, FieldReference, FieldMedia:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+
+		switch f.Type {
+		case FieldDateTime:
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("must be an RFC 3339 date and time")
+			}
+		case FieldReference, FieldMedia:
+			if !database.IsUUID(s) {
+				return fmt.Errorf("must be a UUID")
+			}
+		}
+
+		n := utf8.RuneCountInString(s)
+		if vl.MinLength != nil && n < *vl.MinLength {
+			return fmt.Errorf("must be at least %d characters long", *vl.MinLength)
+		}
+		if vl.MaxLength != nil && n > *vl.MaxLength {
+			return fmt.Errorf("must be at most %d characters long", *vl.MaxLength)
+		}
+		if vl.Pattern != "" {
+			if re, err := regexp.Compile(vl.Pattern); err != nil || !re.MatchString(s) {
+				return fmt.Errorf("must match pattern %s", vl.Pattern)
+			}
+		}
+	case FieldInteger, FieldNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("must be a number")
+		}
+		if f.Type == FieldInteger && n != math.Trunc(n) {
+			return fmt.Errorf("must be an integer")
+		}
+
+		if vl.Minimum != nil && n < *vl.Minimum {
+			return fmt.Errorf("must be greater than or equal to %v", *vl.Minimum)
+		}
+		if vl.Maximum != nil && n > *vl.Maximum {
+			return fmt.Errorf("must be less than or equal to %v", *vl.Maximum)
+		}
+	case FieldBoolean:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	}
+
+	if len(vl.Enum) > 0 {
+		for _, e := range vl.Enum {
+			if reflect.DeepEqual(e, v) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %v", vl.Enum)
+	}
+
+	return nil
+}
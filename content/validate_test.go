@@ -0,0 +1,128 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/relation"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func articleType() *content.Type {
+	return &content.Type{
+		Name:  "article",
+		Title: "Article",
+		Fields: []content.Field{
+			{Name: "title", Type: content.FieldString, Required: true,
+				Validation: content.Validation{MinLength: intPtr(1), MaxLength: intPtr(10)}},
+			{Name: "body", Type: content.FieldRichText},
+			{Name: "rating", Type: content.FieldInteger, Validation: content.Validation{
+				Minimum: floatPtr(1), Maximum: floatPtr(5)}},
+			{Name: "published", Type: content.FieldDateTime},
+			{Name: "author", Type: content.FieldReference},
+			{Name: "kind", Type: content.FieldString, Validation: content.Validation{
+				Enum: []interface{}{"news", "opinion"}}},
+			{Name: "tags", Type: content.FieldString, Multiple: true, Validation: content.Validation{
+				MaxItems: intPtr(2), Pattern: "^[a-z]+$"}},
+		},
+	}
+}
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	var r map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &r))
+	return r
+}
+
+func TestTypeValidate(t *testing.T) {
+	typ := articleType()
+	require.NoError(t, typ.Check())
+
+	tests := []struct {
+		data   string
+		fields []string
+	}{
+		{`{"title": "Hello"}`, nil},
+		{`{"title": "Hello", "body": "<p>Hi</p>", "rating": 5, "published": "2021-06-01T10:00:00Z",
+			"author": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "kind": "news", "tags": ["go", "cms"]}`, nil},
+		{`{}`, []string{"title"}},
+		{`{"title": null}`, []string{"title"}},
+		{`{"title": ""}`, []string{"title"}},
+		{`{"title": "Hello, world!"}`, []string{"title"}},
+		{`{"title": 1}`, []string{"title"}},
+		{`{"title": "Hello", "foo": 1}`, []string{"foo"}},
+		{`{"title": "Hello", "rating": 2.5}`, []string{"rating"}},
+		{`{"title": "Hello", "rating": 6}`, []string{"rating"}},
+		{`{"title": "Hello", "published": "yesterday"}`, []string{"published"}},
+		{`{"title": "Hello", "author": "john"}`, []string{"author"}},
+		{`{"title": "Hello", "kind": "rumor"}`, []string{"kind"}},
+		{`{"title": "Hello", "tags": "go"}`, []string{"tags"}},
+		{`{"title": "Hello", "tags": ["a", "b", "c"]}`, []string{"tags"}},
+		{`{"title": "Hello", "tags": ["Go"]}`, []string{"tags"}},
+	}
+
+	for _, tt := range tests {
+		err := typ.Validate(decode(t, tt.data))
+		if tt.fields == nil {
+			require.NoError(t, err, tt.data)
+			continue
+		}
+
+		require.IsType(t, content.ValidationError{}, err, tt.data)
+		var fields []string
+		for f := range err.(content.ValidationError) {
+			fields = append(fields, f)
+		}
+		require.ElementsMatch(t, tt.fields, fields, tt.data)
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []*content.Type{
+		{Name: "Article"},
+		{Name: "article", Fields: []content.Field{{Name: "1st", Type: content.FieldString}}},
+		{Name: "article", Fields: []content.Field{{Name: "a", Type: "color"}}},
+		{Name: "article", Fields: []content.Field{{Name: "a", Type: content.FieldText}, {Name: "a",
+			Type: content.FieldText}}},
+		{Name: "article", Fields: []content.Field{{Name: "a", Type: content.FieldText,
+			Validation: content.Validation{Pattern: "("}}}},
+		{Name: "article", Fields: []content.Field{{Name: "a", Type: content.FieldText,
+			OnDelete: relation.ActionCascade}}},
+		{Name: "article", Fields: []content.Field{{Name: "a", Type: content.FieldReference, OnDelete: "ignore"}}},
+	}
+
+	for _, typ := range tests {
+		require.Error(t, typ.Check(), "%+v", typ)
+	}
+}
+
+func TestTypeJSONSchema(t *testing.T) {
+	s := articleType().JSONSchema()
+
+	require.Equal(t, "object", s["type"])
+	require.Equal(t, false, s["additionalProperties"])
+	require.Equal(t, []string{"title"}, s["required"])
+
+	props := s["properties"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 10}, props["title"])
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "uuid"}, props["author"])
+	require.Equal(t, map[string]interface{}{
+		"type":     "array",
+		"items":    map[string]interface{}{"type": "string", "pattern": "^[a-z]+$"},
+		"maxItems": 2,
+	}, props["tags"])
+}
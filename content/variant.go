@@ -0,0 +1,136 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package content
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+)
+
+const variantColumns = "item_uuid::text AS item_uuid, locale, data, created_at, updated_at"
+
+// Variant is item data in a locale. Items of all locales share the item UUID, revisions and publication status, the
+// item data itself is in the default locale.
+type Variant struct {
+	ItemUUID  string                 `db:"item_uuid" json:"item"`
+	Locale    string                 `json:"locale"`
+	Data      map[string]interface{} `json:"data"`
+	CreatedAt time.Time              `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time              `db:"updated_at" json:"updatedAt"`
+}
+
+// Variants returns all locale variants of an item.
+func (s *Store) Variants(ctx context.Context, uuid string) ([]*Variant, error) {
+	if !database.IsUUID(uuid) {
+		return nil, nil
+	}
+
+	var r []*Variant
+
+	err := s.db.SelectAll(ctx, &r, "SELECT "+variantColumns+" FROM content_variants WHERE item_uuid = $1 "+
+		"ORDER BY locale", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// VariantsOf returns variants of the items in the locales.
+func (s *Store) VariantsOf(ctx context.Context, uuids, locales []string) ([]*Variant, error) {
+	valid := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		if database.IsUUID(uuid) {
+			valid = append(valid, uuid)
+		}
+	}
+	if len(valid) == 0 || len(locales) == 0 {
+		return nil, nil
+	}
+
+	var r []*Variant
+
+	err := s.db.SelectAll(ctx, &r, "SELECT "+variantColumns+" FROM content_variants "+
+		"WHERE item_uuid = ANY($1::uuid[]) AND locale = ANY($2)", valid, locales)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Variant returns a variant of an item in the locale.
+func (s *Store) Variant(ctx context.Context, uuid, locale string) (*Variant, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	v := &Variant{}
+
+	err := s.db.SelectOne(ctx, v, "SELECT "+variantColumns+" FROM content_variants WHERE item_uuid = $1 AND "+
+		"locale = $2", uuid, locale)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// SaveVariant creates or replaces a variant of an item, if the checks of the item pass. On success the variant is
+// filled with stored values.
+func (s *Store) SaveVariant(ctx context.Context, v *Variant, checks ...WriteCheck) error {
+	if !database.IsUUID(v.ItemUUID) {
+		return ErrNotFound
+	}
+	if v.Data == nil {
+		v.Data = make(map[string]interface{})
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if err := runChecks(ctx, tx, v.ItemUUID, checks); err != nil {
+			return err
+		}
+
+		err := pgxscan.Get(ctx, tx, v, "INSERT INTO content_variants (item_uuid, locale, data) "+
+			"SELECT uuid, $2, $3 FROM content_items WHERE uuid = $1 AND deleted_at IS NULL "+
+			"ON CONFLICT (item_uuid, locale) DO UPDATE SET data = excluded.data, updated_at = now() "+
+			"RETURNING "+variantColumns, v.ItemUUID, v.Locale, v.Data)
+		if pgxscan.NotFound(err) {
+			return ErrNotFound
+		}
+
+		return err
+	})
+}
+
+// DeleteVariant deletes a variant of an item in the locale, if the checks of the item pass.
+func (s *Store) DeleteVariant(ctx context.Context, uuid, locale string, checks ...WriteCheck) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	return s.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if err := runChecks(ctx, tx, uuid, checks); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM content_variants WHERE item_uuid = $1 AND locale = $2", uuid, locale)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package database
+
+import (
+	"context"
+
+	"ampho.xyz/core/requestid"
+)
+
+// CommentQueries enables or disables prepending the request ID of a query context to the query as an SQL comment,
+// e.g. `/* request_id=4bf92f35 */ SELECT ...`, so slow query logs and pg_stat_activity can be correlated with service
+// logs. Queries sent in batches are not commented.
+//
+// Since prepared statements are cached by the query text, each request prepares its statements anew, which costs an
+// extra round trip per query. Disabled by default, expected to be enabled before the database is used.
+func (d *Database) CommentQueries(enabled bool) {
+	d.comments = enabled
+}
+
+// comment prepends the request ID of a context to a query if query comments are enabled. Request IDs are validated
+// by the requestid package, so they cannot close the comment.
+func (d *Database) comment(ctx context.Context, sql string) string {
+	if !d.comments {
+		return sql
+	}
+
+	id := requestid.FromContext(ctx)
+	if !requestid.IsValid(id) {
+		return sql
+	}
+
+	return "/* request_id=" + id + " */ " + sql
+}
@@ -24,6 +24,7 @@ type Database struct {
 	roReplicas []*Replica
 	rwIndex    int
 	roIndex    int
+	comments   bool
 }
 
 // GetReplica returns a next replica of type t from a pool.
@@ -58,42 +59,106 @@ func (d *Database) GetReplica(t ReplicaType) *Replica {
 	return list[*index]
 }
 
+// Replicas returns all replicas, RW ones first.
+func (d *Database) Replicas() []*Replica {
+	r := make([]*Replica, 0, len(d.rwReplicas)+len(d.roReplicas))
+
+	return append(append(r, d.rwReplicas...), d.roReplicas...)
+}
+
+// HealthChecks returns ping checks of all replicas named database.rw.N and database.ro.N, which can be registered as
+// service health checks:
+//
+//	for name, fn := range db.HealthChecks() {
+//		svc.AddHealthCheck(name, fn)
+//	}
+func (d *Database) HealthChecks() map[string]func(ctx context.Context) error {
+	r := make(map[string]func(ctx context.Context) error)
+	for _, rp := range d.Replicas() {
+		r["database."+rp.Name()] = rp.Ping
+	}
+
+	return r
+}
+
 // Exec executes a non-SELECT query using an RW replica.
 func (d *Database) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
-	return d.GetReplica(ReplicaTypeRW).pool.Exec(ctx, sql, args...)
+	r := d.GetReplica(ReplicaTypeRW)
+
+	ctx, span := startSpan(ctx, r, sql)
+	tag, err := r.pool.Exec(ctx, d.comment(ctx, sql), args...)
+	endSpan(span, err)
+
+	return tag, err
 }
 
 // Query executes a SELECT query using an RO replica.
 func (d *Database) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
-	return d.GetReplica(ReplicaTypeRO).pool.Query(ctx, sql, args...)
+	r := d.GetReplica(ReplicaTypeRO)
+
+	ctx, span := startSpan(ctx, r, sql)
+	rows, err := r.pool.Query(ctx, d.comment(ctx, sql), args...)
+
+	return traceRows(span, rows, err)
 }
 
 func (d *Database) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
-	return d.GetReplica(ReplicaTypeRO).pool.QueryRow(ctx, sql, args...)
+	r := d.GetReplica(ReplicaTypeRO)
+
+	ctx, span := startSpan(ctx, r, sql)
+
+	return &tracedRow{r.pool.QueryRow(ctx, d.comment(ctx, sql), args...), span}
 }
 
 func (d *Database) QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{}, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
-	return d.GetReplica(ReplicaTypeRO).pool.QueryFunc(ctx, sql, args, scans, f)
+	r := d.GetReplica(ReplicaTypeRO)
+
+	ctx, span := startSpan(ctx, r, sql)
+	tag, err := r.pool.QueryFunc(ctx, d.comment(ctx, sql), args, scans, f)
+	endSpan(span, err)
+
+	return tag, err
 }
 
 func (d *Database) SendBatch(ctx context.Context, t ReplicaType, b *pgx.Batch) pgx.BatchResults {
-	return d.GetReplica(t).pool.SendBatch(ctx, b)
+	r := d.GetReplica(t)
+
+	ctx, span := startOpSpan(ctx, r, opBatch)
+
+	return &tracedBatch{r.pool.SendBatch(ctx, b), span}
 }
 
 func (d *Database) Begin(ctx context.Context, t ReplicaType) (pgx.Tx, error) {
-	return d.GetReplica(t).pool.Begin(ctx)
+	return d.BeginTx(ctx, t, pgx.TxOptions{})
 }
 
 func (d *Database) BeginTx(ctx context.Context, t ReplicaType, txOptions pgx.TxOptions) (pgx.Tx, error) {
-	return d.GetReplica(t).pool.BeginTx(ctx, txOptions)
+	r := d.GetReplica(t)
+
+	ctx, span := startOpSpan(ctx, r, opBegin)
+	tx, err := r.pool.BeginTx(ctx, txOptions)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.wrapTx(r, tx), nil
 }
 
 func (d *Database) BeginFunc(ctx context.Context, t ReplicaType, f func(pgx.Tx) error) error {
-	return d.GetReplica(t).pool.BeginFunc(ctx, f)
+	return d.BeginTxFunc(ctx, t, pgx.TxOptions{}, f)
 }
 
 func (d *Database) BeginTxFunc(ctx context.Context, t ReplicaType, txOptions pgx.TxOptions, f func(pgx.Tx) error) error {
-	return d.GetReplica(t).pool.BeginTxFunc(ctx, txOptions, f)
+	r := d.GetReplica(t)
+
+	ctx, span := startOpSpan(ctx, r, opTransaction)
+	err := r.pool.BeginTxFunc(ctx, txOptions, func(tx pgx.Tx) error {
+		return f(d.wrapTx(r, tx))
+	})
+	endSpan(span, err)
+
+	return err
 }
 
 func (d *Database) Migrate(source migration.Source, direction migration.Direction, max int) (int, error) {
@@ -133,6 +198,13 @@ func (d *Database) SelectAll(ctx context.Context, dest interface{}, sql string,
 	return nil
 }
 
+// Close closes connections of all replicas.
+func (d *Database) Close() {
+	for _, r := range d.Replicas() {
+		r.pool.Close()
+	}
+}
+
 // New creates a new database replicas pool.
 func New(ctx context.Context, replica ...*Replica) (*Database, error) {
 	if len(replica) == 0 {
@@ -150,8 +222,10 @@ func New(ctx context.Context, replica ...*Replica) (*Database, error) {
 		r.pool = pool
 		switch r.Type {
 		case ReplicaTypeRW:
+			r.name = fmt.Sprintf("rw.%d", len(db.rwReplicas))
 			db.rwReplicas = append(db.rwReplicas, r)
 		case ReplicaTypeRO:
+			r.name = fmt.Sprintf("ro.%d", len(db.roReplicas))
 			db.roReplicas = append(db.roReplicas, r)
 		}
 	}
@@ -46,3 +46,8 @@ func (e *Entity) GetUpdatedAt() time.Time {
 func (e *Entity) GetDeletedAt() time.Time {
 	return e.DeletedAt.Time
 }
+
+// IsUUID checks whether a string is a valid UUID.
+func IsUUID(s string) bool {
+	return (&pgtype.UUID{}).Set(s) == nil
+}
@@ -0,0 +1,77 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package database
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// collector exports connection pool stats of database replicas.
+type collector struct {
+	db *Database
+
+	acquired     *prometheus.Desc
+	idle         *prometheus.Desc
+	total        *prometheus.Desc
+	max          *prometheus.Desc
+	acquireCount *prometheus.Desc
+	acquireTime  *prometheus.Desc
+	emptyCount   *prometheus.Desc
+	canceled     *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquireCount
+	ch <- c.acquireTime
+	ch <- c.emptyCount
+	ch <- c.canceled
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range c.db.Replicas() {
+		if r.pool == nil {
+			continue
+		}
+		st, name := r.pool.Stat(), r.Name()
+
+		ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(st.AcquiredConns()), name)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(st.IdleConns()), name)
+		ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(st.TotalConns()), name)
+		ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(st.MaxConns()), name)
+		ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(st.AcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(c.acquireTime, prometheus.CounterValue, st.AcquireDuration().Seconds(),
+			name)
+		ch <- prometheus.MustNewConstMetric(c.emptyCount, prometheus.CounterValue, float64(st.EmptyAcquireCount()),
+			name)
+		ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(st.CanceledAcquireCount()),
+			name)
+	}
+}
+
+// Collector returns a Prometheus collector of connection pool stats of all replicas, labelled by replica: rw.N and
+// ro.N. A service registers it when created with the database:
+//
+//	svc, err := service.New(cfg, service.WithDatabase(db))
+func (d *Database) Collector() prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("database_pool_"+name, help, []string{"replica"}, nil)
+	}
+
+	return &collector{
+		db:           d,
+		acquired:     desc("acquired_connections", "Number of connections acquired from the pool."),
+		idle:         desc("idle_connections", "Number of idle connections in the pool."),
+		total:        desc("connections", "Number of connections in the pool."),
+		max:          desc("max_connections", "Maximum size of the pool."),
+		acquireCount: desc("acquires_total", "Number of connections acquired from the pool."),
+		acquireTime:  desc("acquire_duration_seconds_total", "Time spent acquiring connections from the pool."),
+		emptyCount:   desc("empty_acquires_total", "Number of acquires which waited for a connection."),
+		canceled:     desc("canceled_acquires_total", "Number of acquires canceled by a context."),
+	}
+}
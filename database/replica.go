@@ -20,9 +20,16 @@ type Replica struct {
 	DSN  string
 	Type ReplicaType
 
+	name string
 	pool *pgxpool.Pool
 }
 
+// Name returns the replica name, rw.N or ro.N, where N is the replica index among replicas of the type. It is set
+// when the replica is added to a database.
+func (r *Replica) Name() string {
+	return r.name
+}
+
 // Ping verifies a connection to the database is still alive, establishing a connection if necessary.
 func (r *Replica) Ping(ctx context.Context) error {
 	return r.pool.Ping(ctx)
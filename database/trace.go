@@ -0,0 +1,258 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer of database operations. Spans are created by the global tracer provider, see
+// the tracing package.
+const TracerName = "ampho.xyz/core/database"
+
+// ReplicaKey is the span attribute of the name of a replica an operation is performed on, see Replica.Name.
+const ReplicaKey = attribute.Key("db.replica")
+
+// Operations which are not SQL statements.
+const (
+	opBatch       = "BATCH"
+	opBegin       = "BEGIN"
+	opCommit      = "COMMIT"
+	opCopy        = "COPY"
+	opRollback    = "ROLLBACK"
+	opTransaction = "TRANSACTION"
+)
+
+// startSpan starts a span of an SQL statement named by its type, e.g. SELECT.
+func startSpan(ctx context.Context, r *Replica, sql string) (context.Context, trace.Span) {
+	return startOpSpan(ctx, r, statementType(sql), semconv.DBStatementKey.String(sql))
+}
+
+// startOpSpan starts a span of an operation performed on a replica.
+func startOpSpan(ctx context.Context, r *Replica, op string, attrs ...attribute.KeyValue) (context.Context,
+	trace.Span) {
+	attrs = append(attrs, semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(op), ReplicaKey.String(r.Name()))
+
+	return otel.Tracer(TracerName).Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span recording an error. No rows and rolling back a finished transaction are not errors.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) && !errors.Is(err, pgx.ErrTxClosed) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// statementType returns the first keyword of an SQL statement in upper case, skipping comments.
+func statementType(sql string) string {
+	for {
+		sql = strings.TrimLeft(sql, " \t\r\n(")
+
+		switch {
+		case strings.HasPrefix(sql, "/*"):
+			i := strings.Index(sql, "*/")
+			if i < 0 {
+				return "SQL"
+			}
+			sql = sql[i+2:]
+		case strings.HasPrefix(sql, "--"):
+			i := strings.IndexByte(sql, '\n')
+			if i < 0 {
+				return "SQL"
+			}
+			sql = sql[i+1:]
+		default:
+			end := strings.IndexAny(sql, " \t\r\n(;")
+			if end < 0 {
+				end = len(sql)
+			}
+			if end == 0 {
+				return "SQL"
+			}
+			return strings.ToUpper(sql[:end])
+		}
+	}
+}
+
+// traceRows ends the span of a query when its rows are closed, or immediately if the query fails.
+func traceRows(span trace.Span, rows pgx.Rows, err error) (pgx.Rows, error) {
+	if err != nil {
+		endSpan(span, err)
+		return rows, err
+	}
+
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+// Close closes the rows and ends the span.
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+
+	if !r.ended {
+		r.ended = true
+		endSpan(r.span, r.Rows.Err())
+	}
+}
+
+// Next ends the span when the rows are read, since callers may not close them then.
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+
+	r.Close()
+
+	return false
+}
+
+type tracedRow struct {
+	pgx.Row
+	span trace.Span
+}
+
+// Scan scans the row and ends the span.
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	endSpan(r.span, err)
+
+	return err
+}
+
+type tracedBatch struct {
+	pgx.BatchResults
+	span trace.Span
+}
+
+// Close closes the batch results and ends the span.
+func (b *tracedBatch) Close() error {
+	err := b.BatchResults.Close()
+	endSpan(b.span, err)
+
+	return err
+}
+
+// wrapTx makes a transaction traced and commented, see CommentQueries.
+func (d *Database) wrapTx(r *Replica, t pgx.Tx) pgx.Tx {
+	return &tracedTx{Tx: t, db: d, replica: r}
+}
+
+// tracedTx is a transaction tracing and commenting its queries.
+type tracedTx struct {
+	pgx.Tx
+	db      *Database
+	replica *Replica
+}
+
+// Begin starts a pseudo nested transaction.
+func (t *tracedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	ctx, span := startOpSpan(ctx, t.replica, opBegin)
+	nested, err := t.Tx.Begin(ctx)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.db.wrapTx(t.replica, nested), nil
+}
+
+// BeginFunc starts a pseudo nested transaction and executes f.
+func (t *tracedTx) BeginFunc(ctx context.Context, f func(pgx.Tx) error) error {
+	ctx, span := startOpSpan(ctx, t.replica, opTransaction)
+	err := t.Tx.BeginFunc(ctx, func(nested pgx.Tx) error {
+		return f(t.db.wrapTx(t.replica, nested))
+	})
+	endSpan(span, err)
+
+	return err
+}
+
+// Commit commits the transaction.
+func (t *tracedTx) Commit(ctx context.Context) error {
+	ctx, span := startOpSpan(ctx, t.replica, opCommit)
+	err := t.Tx.Commit(ctx)
+	endSpan(span, err)
+
+	return err
+}
+
+// Rollback rolls the transaction back.
+func (t *tracedTx) Rollback(ctx context.Context) error {
+	ctx, span := startOpSpan(ctx, t.replica, opRollback)
+	err := t.Tx.Rollback(ctx)
+	endSpan(span, err)
+
+	return err
+}
+
+// CopyFrom copies rows into a table.
+func (t *tracedTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string,
+	rowSrc pgx.CopyFromSource) (int64, error) {
+	ctx, span := startOpSpan(ctx, t.replica, opCopy, semconv.DBSQLTableKey.String(tableName.Sanitize()))
+	n, err := t.Tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	endSpan(span, err)
+
+	return n, err
+}
+
+// SendBatch sends queued queries.
+func (t *tracedTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	ctx, span := startOpSpan(ctx, t.replica, opBatch)
+
+	return &tracedBatch{t.Tx.SendBatch(ctx, b), span}
+}
+
+// Exec executes a query.
+func (t *tracedTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startSpan(ctx, t.replica, sql)
+	tag, err := t.Tx.Exec(ctx, t.db.comment(ctx, sql), args...)
+	endSpan(span, err)
+
+	return tag, err
+}
+
+// Query executes a query returning rows.
+func (t *tracedTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startSpan(ctx, t.replica, sql)
+	rows, err := t.Tx.Query(ctx, t.db.comment(ctx, sql), args...)
+
+	return traceRows(span, rows, err)
+}
+
+// QueryRow executes a query returning at most one row.
+func (t *tracedTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startSpan(ctx, t.replica, sql)
+
+	return &tracedRow{t.Tx.QueryRow(ctx, t.db.comment(ctx, sql), args...), span}
+}
+
+// QueryFunc executes a query and calls f for each row.
+func (t *tracedTx) QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{},
+	f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	ctx, span := startSpan(ctx, t.replica, sql)
+	tag, err := t.Tx.QueryFunc(ctx, t.db.comment(ctx, sql), args, scans, f)
+	endSpan(span, err)
+
+	return tag, err
+}
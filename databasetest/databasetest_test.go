@@ -0,0 +1,24 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package databasetest_test
+
+import (
+	"context"
+	"testing"
+
+	"ampho.xyz/core/databasetest"
+)
+
+func TestNew(t *testing.T) {
+	db := databasetest.New(t)
+
+	var n int
+	if err := db.QueryRow(context.Background(), "SELECT count(*) FROM content_items").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("test schema is not empty: %d items", n)
+	}
+}
@@ -0,0 +1,376 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/relation"
+	"ampho.xyz/core/richtext"
+)
+
+// request is a GraphQL request. An id refers to a query persisted in advance.
+type request struct {
+	ID            string                 `json:"id"`
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery struct {
+			Sha256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// API serves the GraphQL delivery endpoint.
+type API struct {
+	store         *content.Store
+	types         *content.Registry
+	relations     *relation.Relations
+	renderer      *richtext.Renderer
+	locales       *i18n.Resolver
+	path          string
+	limit         int
+	maxLimit      int
+	refresh       time.Duration
+	persistedOnly bool
+	queries       *persistedQueries
+
+	mu       sync.Mutex
+	structs  []*object
+	schema   *schema
+	version  string        // content types version the schema is built from
+	checked  time.Time     // last time content types version was checked
+	building chan struct{} // closed when the schema being built is ready, nil if no schema is being built
+}
+
+// Option configures an API.
+type Option func(a *API)
+
+// WithTypes serves content types from the registry. Without it only registered structs are served.
+func WithTypes(types *content.Registry) Option {
+	return func(a *API) {
+		a.types = types
+	}
+}
+
+// WithRenderer makes rich-text fields take a format argument to request HTML rendered by the renderer. Without it
+// rich-text fields are served as stored.
+func WithRenderer(renderer *richtext.Renderer) Option {
+	return func(a *API) {
+		a.renderer = renderer
+	}
+}
+
+// WithLocales serves content items in the request locale resolved by the resolver.
+func WithLocales(locales *i18n.Resolver) Option {
+	return func(a *API) {
+		a.locales = locales
+	}
+}
+
+// RegisterStruct registers a Go entity struct stored in a database table as a GraphQL object with the name.
+//
+// The struct must embed database.Entity. Its exported fields are mapped to table columns the same way scany does and
+// to GraphQL fields named by the json tag or after the Go field name. A UUID field with the ref tag is a reference to
+// an entity of the object named by the tag, "Content" refers to a content item of any type:
+//
+//	type Author struct {
+//		database.Entity
+//		Name  string
+//		Photo pgtype.UUID `ref:"Content"`
+//	}
+func (a *API) RegisterStruct(name, table string, v interface{}) error {
+	o, err := newStructObject(a.store.DB(), name, table, v)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, s := range a.structs {
+		if s.name == name {
+			return fmt.Errorf("%s is already registered", name)
+		}
+	}
+
+	a.structs = append(a.structs, o)
+	a.schema = nil
+
+	return nil
+}
+
+// Persist stores a query, so clients can execute it by the id or by the query SHA-256 hash.
+func (a *API) Persist(id, query string) {
+	a.queries.add(id, query)
+}
+
+// Mount registers the endpoint handler on a router.
+func (a *API) Mount(r *mux.Router) {
+	r.Handle(a.path, a).Methods(http.MethodGet, http.MethodPost)
+}
+
+// currentSchema returns the schema, building it again if content types have changed. The schema is built outside
+// the lock by a single request at a time: other requests are served the previous schema meanwhile or, if there is
+// none yet, wait for it. If building fails, the previous schema is served until the next check.
+func (a *API) currentSchema(ctx context.Context) (*schema, error) {
+	for {
+		a.mu.Lock()
+		if a.schema != nil && (a.building != nil || time.Since(a.checked) < a.refresh) {
+			s := a.schema
+			a.mu.Unlock()
+			return s, nil
+		}
+
+		if building := a.building; building != nil {
+			a.mu.Unlock()
+			select {
+			case <-building:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		building := make(chan struct{})
+		a.building = building
+		current, version, structs := a.schema, a.version, a.structs
+		a.mu.Unlock()
+
+		s, version, err := a.buildSchema(ctx, current, version, structs)
+
+		a.mu.Lock()
+		a.building = nil
+		close(building)
+		if err != nil && current != nil {
+			// The current schema is served until the next attempt rather than failing every request
+			logger.FromContext(ctx).Error("failed to rebuild delivery schema", "error", err)
+			s, err, a.checked = current, nil, time.Now()
+		} else if err == nil && len(a.structs) == len(structs) {
+			// Structs registered meanwhile are not in the schema, so otherwise it is served to this request only
+			a.schema, a.version, a.checked = s, version, time.Now()
+		}
+		a.mu.Unlock()
+
+		return s, err
+	}
+}
+
+// buildSchema builds a schema serving the structs and current content types. The current schema built from the
+// version of content types is returned as is if content types have not changed since.
+func (a *API) buildSchema(ctx context.Context, current *schema, version string, structs []*object) (*schema, string,
+	error) {
+	var (
+		latest string
+		types  []*content.Type
+		err    error
+	)
+
+	if a.types != nil {
+		if latest, err = a.types.Version(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+	if current != nil && latest == version {
+		return current, version, nil
+	}
+
+	if a.types != nil {
+		if types, err = a.types.List(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	s, err := newSchema(logger.FromContext(ctx), a.store, a.relations, types, structs, a.renderer, a.locales, a.limit,
+		a.maxLimit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s, latest, nil
+}
+
+// queryText returns the text of a requested query, looking up and storing persisted queries.
+func (a *API) queryText(req *request) (string, error) {
+	if req.ID != "" {
+		q, ok := a.queries.get(req.ID)
+		if !ok {
+			return "", ErrPersistedQueryNotFound
+		}
+		return q, nil
+	}
+
+	if h := req.Extensions.PersistedQuery.Sha256Hash; h != "" {
+		if req.Query == "" {
+			q, ok := a.queries.get(h)
+			if !ok {
+				return "", ErrPersistedQueryNotFound
+			}
+			return q, nil
+		}
+
+		if hash(req.Query) != h {
+			return "", ErrPersistedQueryMismatch
+		}
+		if !a.persistedOnly {
+			return req.Query, a.queries.persist(h, req.Query)
+		}
+	}
+
+	if a.persistedOnly && !a.queries.isKnown(req.Query) {
+		return "", ErrNotPersisted
+	}
+
+	return req.Query, nil
+}
+
+// readRequest reads a GraphQL request from a query string of a GET request or a JSON body of a POST request.
+func readRequest(w http.ResponseWriter, r *http.Request) (*request, error) {
+	req := &request{}
+
+	if r.Method == http.MethodPost {
+		if err := httputil.ReadJSON(w, r, req); err != nil {
+			return nil, err
+		}
+		return req, nil
+	}
+
+	q := r.URL.Query()
+	req.ID = q.Get("id")
+	req.Query = q.Get("query")
+	req.OperationName = q.Get("operationName")
+
+	if v := q.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// previewToken returns the preview token of a request from the PreviewHeader header or the preview query parameter.
+func previewToken(r *http.Request) string {
+	if token := r.Header.Get(PreviewHeader); token != "" {
+		return token
+	}
+
+	return r.URL.Query().Get("preview")
+}
+
+func writeErrors(w http.ResponseWriter, code int, err error) {
+	_, _ = httputil.WriteJSONStatus(w, code, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())},
+	})
+}
+
+// ServeHTTP implements http.Handler.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := readRequest(w, r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query, err := a.queryText(req)
+	if err == ErrPersistedQueryNotFound {
+		writeErrors(w, http.StatusOK, err)
+		return
+	} else if err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	} else if query == "" {
+		writeErrors(w, http.StatusBadRequest, errors.New("query is empty"))
+		return
+	}
+
+	s, err := a.currentSchema(r.Context())
+	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to build delivery schema", "error", err)
+		writeErrors(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+
+	ctx := r.Context()
+	if token := previewToken(r); token != "" {
+		claims, err := content.ParsePreviewToken(token)
+		if err != nil {
+			writeErrors(w, http.StatusUnauthorized, err)
+			return
+		}
+		ctx = context.WithValue(ctx, previewKey{}, claims)
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	if a.locales != nil && i18n.LocaleFrom(ctx) == "" {
+		ctx = i18n.WithLocale(ctx, a.locales.Resolve(r))
+		w.Header().Add("Vary", "Accept-Language")
+	}
+	l := newLoader(ctx, s)
+	ctx = context.WithValue(ctx, loaderKey{}, l)
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.gql,
+		RequestString:  query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	if locale := l.locale(); locale != "" {
+		w.Header().Set("Content-Language", locale)
+	}
+
+	_, _ = httputil.WriteJSON(w, result)
+}
+
+// New creates a new GraphQL delivery API serving registered structs and, with the options, content types.
+func New(cfg config.Config, db *database.Database, opts ...Option) *API {
+	cfg.SetDefault("delivery.path", DftPath)
+	cfg.SetDefault("delivery.limit", DftLimit)
+	cfg.SetDefault("delivery.maxLimit", DftMaxLimit)
+	cfg.SetDefault("delivery.schemaRefresh", DftSchemaRefresh)
+
+	a := &API{
+		store:         content.NewStore(db),
+		relations:     relation.New(db),
+		path:          cfg.GetString("delivery.path"),
+		limit:         cfg.GetInt("delivery.limit"),
+		maxLimit:      cfg.GetInt("delivery.maxLimit"),
+		refresh:       cfg.GetDuration("delivery.schemaRefresh"),
+		persistedOnly: cfg.GetBool("delivery.persistedOnly"),
+		queries:       newPersistedQueries(),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	for id, query := range cfg.GetStringMapString("delivery.persistedQueries") {
+		a.Persist(id, query)
+	}
+
+	return a
+}
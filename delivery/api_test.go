@@ -0,0 +1,239 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/delivery"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/security"
+)
+
+type author struct {
+	database.Entity
+	Name   string
+	Rating pgtype.Int4
+	Tags   []string
+	Photo  pgtype.UUID `ref:"Content"`
+}
+
+type result struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func post(t *testing.T, api *delivery.API, body interface{}) (int, result) {
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(b)))
+
+	var r result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+
+	return w.Code, r
+}
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRegisterStruct(t *testing.T) {
+	api := delivery.New(config.NewTesting("delivery"), nil)
+
+	require.Error(t, api.RegisterStruct("author", "authors", author{}))
+	require.Error(t, api.RegisterStruct("Author", "authors", "author"))
+	require.Error(t, api.RegisterStruct("Author", "authors", struct{ Name string }{}))
+	require.Error(t, api.RegisterStruct("Author", "authors", struct {
+		database.Entity
+		Name string `ref:"Author"`
+	}{}))
+	require.NoError(t, api.RegisterStruct("Author", "authors", &author{}))
+	require.Error(t, api.RegisterStruct("Author", "authors", &author{}))
+
+	code, r := post(t, api, map[string]string{"query": `{
+		query: __type(name: "Query") { fields { name } }
+		author: __type(name: "Author") { fields { name type { kind name ofType { name } } } }
+		filter: __type(name: "AuthorFilter") { inputFields { name type { name } } }
+	}`})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, r.Errors)
+
+	names := func(key, list string) map[string]interface{} {
+		m := make(map[string]interface{})
+		for _, f := range r.Data[key].(map[string]interface{})[list].([]interface{}) {
+			f := f.(map[string]interface{})
+			m[f["name"].(string)] = f["type"]
+		}
+		return m
+	}
+
+	require.Contains(t, names("query", "fields"), "content")
+	require.Contains(t, names("query", "fields"), "author")
+	require.Contains(t, names("query", "fields"), "authorList")
+
+	fields := names("author", "fields")
+	require.Len(t, fields, 7)
+	require.Equal(t, "NON_NULL", fields["uuid"].(map[string]interface{})["kind"])
+	require.Equal(t, "Int", fields["rating"].(map[string]interface{})["name"])
+	require.Equal(t, "LIST", fields["tags"].(map[string]interface{})["kind"])
+	require.Equal(t, "Content", fields["photo"].(map[string]interface{})["name"])
+
+	filter := names("filter", "inputFields")
+	require.Equal(t, "StringFilter", filter["name"].(map[string]interface{})["name"])
+	require.Equal(t, "StringListFilter", filter["tags"].(map[string]interface{})["name"])
+	require.Equal(t, "IDFilter", filter["photo"].(map[string]interface{})["name"])
+}
+
+func TestPersistedQueries(t *testing.T) {
+	api := delivery.New(config.NewTesting("delivery"), nil)
+	query := "{ __typename }"
+	apq := map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": sha(query)}}
+
+	code, r := post(t, api, map[string]interface{}{"extensions": apq})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, delivery.ErrPersistedQueryNotFound.Error(), r.Errors[0].Message)
+
+	code, _ = post(t, api, map[string]interface{}{"query": "{ content }", "extensions": apq})
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, r = post(t, api, map[string]interface{}{"query": query, "extensions": apq})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Query", r.Data["__typename"])
+
+	code, r = post(t, api, map[string]interface{}{"extensions": apq})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Query", r.Data["__typename"])
+
+	ext, err := json.Marshal(apq)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?extensions="+url.QueryEscape(string(ext)), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"__typename":"Query"`)
+}
+
+func TestPersistedOnly(t *testing.T) {
+	cfg := config.NewTesting("delivery")
+	cfg.Set("delivery.persistedOnly", true)
+	cfg.Set("delivery.persistedQueries", map[string]string{"typename": "{ __typename }"})
+	api := delivery.New(cfg, nil)
+
+	code, r := post(t, api, map[string]string{"query": "{ __schema { types { name } } }"})
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, delivery.ErrNotPersisted.Error(), r.Errors[0].Message)
+
+	code, r = post(t, api, map[string]string{"id": "typename"})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Query", r.Data["__typename"])
+
+	code, r = post(t, api, map[string]string{"query": "{ __typename }"})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Query", r.Data["__typename"])
+
+	code, r = post(t, api, map[string]string{"id": "unknown"})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, delivery.ErrPersistedQueryNotFound.Error(), r.Errors[0].Message)
+}
+
+func TestPreviewToken(t *testing.T) {
+	security.SetHMACKey([]byte("secret"))
+	api := delivery.New(config.NewTesting("delivery"), nil)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?query=%7B__typename%7D&preview=invalid", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token, _, err := content.NewPreviewToken("6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f", 1, time.Hour)
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query=%7B__typename%7D", nil)
+	req.Header.Set(delivery.PreviewHeader, token)
+	api.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+}
+
+func TestLocale(t *testing.T) {
+	cfg := config.NewTesting("delivery")
+	cfg.Set("i18n.locales", []string{"de", "fr"})
+	locales, err := i18n.New(cfg)
+	require.NoError(t, err)
+	api := delivery.New(cfg, nil, delivery.WithLocales(locales))
+
+	query := "/graphql?query=" + url.QueryEscape(`{ __type(name: "Content") { fields { name } } }`)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, query, nil)
+	req.Header.Set("Accept-Language", "de-CH, fr;q=0.5")
+	api.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+	require.Contains(t, w.Body.String(), `{"name":"locale"}`)
+
+	// Resolved by i18n.Resolver.Middleware
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, query, nil).WithContext(
+		i18n.WithLocale(context.Background(), "fr")))
+	require.Empty(t, w.Header().Get("Vary"))
+
+	// Not localized
+	w = httptest.NewRecorder()
+	delivery.New(config.NewTesting("delivery"), nil).ServeHTTP(w,
+		httptest.NewRequest(http.MethodGet, query, nil))
+	require.Empty(t, w.Header().Get("Content-Language"))
+	require.NotContains(t, w.Body.String(), `{"name":"locale"}`)
+}
+
+func TestSchemaRebuildFailure(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+	types := content.NewRegistry(db)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "article", Fields: []content.Field{}}))
+
+	cfg := config.NewTesting("delivery")
+	cfg.Set("delivery.schemaRefresh", 0)
+	api := delivery.New(cfg, db, delivery.WithTypes(types))
+
+	query := func(q string) result {
+		code, r := post(t, api, map[string]string{"query": q})
+		require.Equal(t, http.StatusOK, code)
+		return r
+	}
+	require.Empty(t, query("{ articleList { totalCount } }").Errors)
+
+	// Content types cannot be read, but the built schema is still served
+	_, err := db.Exec(ctx, "ALTER TABLE content_types RENAME TO content_types_moved")
+	require.NoError(t, err)
+	require.Empty(t, query("{ articleList { totalCount } }").Errors)
+
+	// Rebuilt once they can
+	_, err = db.Exec(ctx, "ALTER TABLE content_types_moved RENAME TO content_types")
+	require.NoError(t, err)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "page", Fields: []content.Field{}}))
+	require.Empty(t, query("{ pageList { totalCount } }").Errors)
+}
@@ -0,0 +1,16 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import "time"
+
+const (
+	DftPath             = "/graphql"        // path the endpoint is mounted on
+	DftLimit            = 20                // number of items in a list if no limit is requested
+	DftMaxLimit         = 100               // maximum number of items in a list
+	DftSchemaRefresh    = time.Second * 30  // how often content types are checked for changes
+	MaxPersistedQueries = 1000              // maximum number of automatically persisted queries kept in memory
+	PreviewHeader       = "X-Preview-Token" // request header with a preview token, see content.NewPreviewToken
+)
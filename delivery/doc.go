@@ -0,0 +1,35 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package delivery provides a read-only GraphQL API serving live content.
+//
+// The schema is generated from the content types registry and from Go entity structs registered with
+// API.RegisterStruct. For every type there is a query by UUID and a list query with filtering, sorting and
+// pagination:
+//
+//	{
+//		articleList(filter: {rating: {gte: 4}}, sort: publishedAt_DESC, limit: 10) {
+//			totalCount
+//			items { uuid title author { ... on Author { name } } }
+//		}
+//	}
+//
+// References between entities are resolved in batches, so a list of items with references costs one query per
+// referenced type rather than one query per item. Reverse references are available as the referencedBy field of
+// content items, e.g. articles of an author: author(uuid: "…") { referencedBy(relation: "author") { uuid } }.
+// Persisted queries are supported both as a list of queries known in advance and with the automatic persisted
+// queries protocol.
+//
+// Rich-text fields return stored Markdown by default, and sanitised HTML with entity links replaced by permalinks
+// when queried as body(format: HTML).
+//
+// A request with a preview token in the X-Preview-Token header or the preview query parameter is served the item
+// revision granted by the token instead of the live one, even if the item is not published. The revision is served
+// in the default locale its data is in. Lists are not affected.
+//
+// If locales are configured, see package i18n, content items are served in the request locale or the closest locale
+// of its fallback chain they have a variant in. The locale field of an item reports the locale it is served in and is
+// authoritative: items of a response may fall back to different locales. The Content-Language header is set only if
+// all items of a response are served in the same locale.
+package delivery
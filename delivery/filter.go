@@ -0,0 +1,229 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+)
+
+var (
+	// scalars maps value kinds to GraphQL scalars.
+	scalars = map[kind]*graphql.Scalar{
+		kindString:   graphql.String,
+		kindInt:      graphql.Int,
+		kindFloat:    graphql.Float,
+		kindBoolean:  graphql.Boolean,
+		kindDateTime: graphql.DateTime,
+		kindID:       graphql.ID,
+	}
+
+	// filters maps value kinds to filter input types of single value fields.
+	filters = map[kind]*graphql.InputObject{
+		kindString:   newFilter("StringFilter", graphql.String, "eq", "ne", "in", "contains"),
+		kindInt:      newFilter("IntFilter", graphql.Int, "eq", "ne", "in", "gt", "gte", "lt", "lte"),
+		kindFloat:    newFilter("FloatFilter", graphql.Float, "eq", "ne", "in", "gt", "gte", "lt", "lte"),
+		kindBoolean:  newFilter("BooleanFilter", graphql.Boolean, "eq", "ne"),
+		kindDateTime: newFilter("DateTimeFilter", graphql.DateTime, "eq", "ne", "in", "gt", "gte", "lt", "lte"),
+		kindID:       newFilter("IDFilter", graphql.ID, "eq", "ne", "in"),
+	}
+
+	// listFilters maps value kinds to filter input types of multiple value fields.
+	listFilters = map[kind]*graphql.InputObject{
+		kindString:   newFilter("StringListFilter", graphql.String, "contains"),
+		kindInt:      newFilter("IntListFilter", graphql.Int, "contains"),
+		kindFloat:    newFilter("FloatListFilter", graphql.Float, "contains"),
+		kindBoolean:  newFilter("BooleanListFilter", graphql.Boolean, "contains"),
+		kindDateTime: newFilter("DateTimeListFilter", graphql.DateTime, "contains"),
+		kindID:       newFilter("IDListFilter", graphql.ID, "contains"),
+	}
+
+	// operators maps filter operations to SQL comparison operators.
+	operators = map[string]string{
+		"eq":  "=",
+		"ne":  "IS DISTINCT FROM",
+		"gt":  ">",
+		"gte": ">=",
+		"lt":  "<",
+		"lte": "<=",
+	}
+)
+
+// newFilter creates a filter input type with the operations on values of the type.
+func newFilter(name string, t graphql.Input, ops ...string) *graphql.InputObject {
+	fields := make(graphql.InputObjectConfigFieldMap, len(ops))
+	for _, op := range ops {
+		fields[op] = &graphql.InputObjectFieldConfig{Type: t}
+	}
+	if _, ok := fields["in"]; ok {
+		fields["in"].Type = graphql.NewList(graphql.NewNonNull(t))
+	}
+
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Fields: fields})
+}
+
+// filterInput creates a filter input type of the object.
+func filterInput(o *object) *graphql.InputObject {
+	fields := make(graphql.InputObjectConfigFieldMap)
+
+	for _, f := range o.fields {
+		if f.expr == "" {
+			continue
+		}
+
+		if f.multiple {
+			fields[f.name] = &graphql.InputObjectFieldConfig{Type: listFilters[f.kind]}
+		} else {
+			fields[f.name] = &graphql.InputObjectFieldConfig{Type: filters[f.kind]}
+		}
+	}
+
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: o.name + "Filter", Fields: fields})
+}
+
+// sortEnum creates an enum of the object sort orders. Enum values are SQL ORDER BY expressions.
+func sortEnum(o *object) *graphql.Enum {
+	values := make(graphql.EnumValueConfigMap)
+
+	for _, f := range o.fields {
+		if f.expr == "" || f.multiple {
+			continue
+		}
+
+		values[f.name+"_ASC"] = &graphql.EnumValueConfig{Value: f.expr + " ASC NULLS LAST"}
+		values[f.name+"_DESC"] = &graphql.EnumValueConfig{Value: f.expr + " DESC NULLS LAST"}
+	}
+
+	return graphql.NewEnum(graphql.EnumConfig{Name: o.name + "Sort", Values: values})
+}
+
+// orderBy converts a sort argument to an SQL ORDER BY expression.
+func orderBy(v interface{}) string {
+	list, _ := v.([]interface{})
+	exprs := make([]string, 0, len(list))
+
+	for _, e := range list {
+		if s, ok := e.(string); ok {
+			exprs = append(exprs, s)
+		}
+	}
+
+	return strings.Join(exprs, ", ")
+}
+
+// filterConds converts a filter argument to SQL conditions on the object fields.
+func filterConds(o *object, filter map[string]interface{}) []content.Cond {
+	var conds []content.Cond
+
+	for _, f := range o.fields {
+		args, ok := filter[f.name].(map[string]interface{})
+		if !ok || f.expr == "" {
+			continue
+		}
+
+		ops := make([]string, 0, len(args))
+		for op := range args {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+
+		for _, op := range ops {
+			if v := args[op]; v != nil {
+				if c, ok := fieldCond(f, op, v); ok {
+					conds = append(conds, c)
+				}
+			}
+		}
+	}
+
+	return conds
+}
+
+// fieldCond converts a filter operation on a field to an SQL condition. It returns false if the operation does not
+// restrict the result.
+func fieldCond(f *field, op string, v interface{}) (content.Cond, bool) {
+	if f.kind == kindID {
+		if list, ok := v.([]interface{}); ok {
+			v = validUUIDs(list)
+		} else if s, _ := v.(string); !database.IsUUID(s) {
+			// UUID columns cannot be compared with arbitrary strings, but nothing can be equal to such a string
+			return content.Cond{SQL: "false"}, op != "ne"
+		}
+	}
+
+	switch op {
+	case "contains":
+		if !f.multiple {
+			return content.Cond{SQL: "strpos(lower(" + f.expr + "), lower($1)) > 0", Args: []interface{}{v}}, true
+		}
+		if f.jsonb {
+			b, _ := json.Marshal([]interface{}{v})
+			return content.Cond{SQL: f.expr + " @> $1::jsonb", Args: []interface{}{string(b)}}, true
+		}
+		return content.Cond{SQL: "$1 = ANY(" + f.expr + ")", Args: []interface{}{v}}, true
+	case "in":
+		list, _ := v.([]interface{})
+		return content.Cond{SQL: f.expr + " = ANY($1)", Args: []interface{}{typedList(f.kind, list)}}, true
+	default:
+		return content.Cond{SQL: f.expr + " " + operators[op] + " $1", Args: []interface{}{v}}, true
+	}
+}
+
+// validUUIDs returns the valid UUIDs from a list.
+func validUUIDs(list []interface{}) []interface{} {
+	r := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		if s, _ := v.(string); database.IsUUID(s) {
+			r = append(r, s)
+		}
+	}
+
+	return r
+}
+
+// typedList converts a list of values of the kind to a slice which can be encoded as an SQL array.
+func typedList(k kind, list []interface{}) interface{} {
+	switch k {
+	case kindInt:
+		r := make([]int64, 0, len(list))
+		for _, v := range list {
+			if i, ok := v.(int); ok {
+				r = append(r, int64(i))
+			}
+		}
+		return r
+	case kindFloat:
+		r := make([]float64, 0, len(list))
+		for _, v := range list {
+			if f, ok := v.(float64); ok {
+				r = append(r, f)
+			}
+		}
+		return r
+	case kindDateTime:
+		r := make([]time.Time, 0, len(list))
+		for _, v := range list {
+			if t, ok := v.(time.Time); ok {
+				r = append(r, t)
+			}
+		}
+		return r
+	default:
+		r := make([]string, 0, len(list))
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				r = append(r, s)
+			}
+		}
+		return r
+	}
+}
@@ -0,0 +1,242 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"context"
+	"sync"
+
+	"ampho.xyz/core/relation"
+)
+
+type loaderKey struct{}
+
+// batch holds entities of one object queued and loaded during a request.
+type batch struct {
+	queue []string
+	nodes map[string]*node // entities by UUIDs, nil for queued and missing ones
+}
+
+// referrers holds relations referring to entities queued and loaded during a request.
+type referrers struct {
+	queue []string
+	rels  map[string][]*relation.Relation // by target UUIDs, present for queued and loaded ones
+}
+
+// loader loads entities by UUIDs in batches. It lives for a single request: resolving a reference only queues the
+// entity and returns a thunk. GraphQL executes thunks after all fields of a level are resolved, so the first thunk
+// loads all entities queued at the level with a single query per object.
+type loader struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	schema    *schema
+	batches   map[string]*batch
+	referrers referrers
+}
+
+// loaderFrom returns the loader of a request context.
+func loaderFrom(ctx context.Context) *loader {
+	return ctx.Value(loaderKey{}).(*loader)
+}
+
+func (l *loader) batch(object string) *batch {
+	b := l.batches[object]
+	if b == nil {
+		b = &batch{nodes: make(map[string]*node)}
+		l.batches[object] = b
+	}
+
+	return b
+}
+
+// enqueue queues entities of an object to load.
+func (l *loader) enqueue(object string, uuids []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.batch(object)
+	for _, uuid := range uuids {
+		if _, ok := b.nodes[uuid]; !ok {
+			b.queue = append(b.queue, uuid)
+			b.nodes[uuid] = nil
+		}
+	}
+}
+
+// prime stores already loaded entities, so they are not loaded again.
+func (l *loader) prime(nodes []*node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.store(nodes)
+}
+
+func (l *loader) store(nodes []*node) {
+	for _, n := range nodes {
+		l.batch(n.object).nodes[n.uuid] = n
+		if o := l.schema.objects[n.object]; o != nil && o.item {
+			l.batch(contentInterface).nodes[n.uuid] = n
+		}
+	}
+}
+
+// get returns entities of an object, loading all queued entities of the object if needed.
+func (l *loader) get(object string, uuids []string) ([]*node, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.batch(object)
+	if len(b.queue) > 0 {
+		queue := b.queue
+		b.queue = nil
+
+		nodes, err := l.schema.source(object).load(l.ctx, queue)
+		if err != nil {
+			return nil, err
+		}
+		l.store(nodes)
+	}
+
+	r := make([]*node, 0, len(uuids))
+	for _, uuid := range uuids {
+		if n := b.nodes[uuid]; n != nil {
+			r = append(r, n)
+		}
+	}
+
+	return r, nil
+}
+
+// load queues an entity of an object and returns a thunk resolving it.
+func (l *loader) load(object, uuid string) func() (interface{}, error) {
+	l.enqueue(object, []string{uuid})
+
+	return func() (interface{}, error) {
+		nodes, err := l.get(object, []string{uuid})
+		if err != nil || len(nodes) == 0 {
+			return nil, err
+		}
+
+		return nodes[0], nil
+	}
+}
+
+// loadList queues entities of an object and returns a thunk resolving them. Missing entities are omitted.
+func (l *loader) loadList(object string, uuids []string) func() (interface{}, error) {
+	l.enqueue(object, uuids)
+
+	return func() (interface{}, error) {
+		nodes, err := l.get(object, uuids)
+		if err != nil {
+			return nil, err
+		}
+
+		r := make([]interface{}, len(nodes))
+		for i := range nodes {
+			r[i] = nodes[i]
+		}
+
+		return r, nil
+	}
+}
+
+// loadReferrers queues an entity and returns a thunk resolving live content items referring to it by relations with
+// the name or with any name if it is empty. Relations of all queued entities are loaded at once, and so are the
+// referring items.
+func (l *loader) loadReferrers(uuid, name string) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.referrers.rels[uuid]; !ok {
+		l.referrers.queue = append(l.referrers.queue, uuid)
+		l.referrers.rels[uuid] = nil
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		rels, err := l.relations(uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		var uuids []string
+		seen := make(map[string]bool)
+		for _, rel := range rels {
+			if (name == "" || rel.Name == name) && !seen[rel.Source] {
+				seen[rel.Source] = true
+				uuids = append(uuids, rel.Source)
+			}
+		}
+
+		nodes, err := l.get(contentInterface, uuids)
+		if err != nil {
+			return nil, err
+		}
+
+		r := make([]interface{}, len(nodes))
+		for i := range nodes {
+			r[i] = nodes[i]
+		}
+
+		return r, nil
+	}
+}
+
+// relations returns relations referring to an entity, loading relations of all queued entities if needed. Sources of
+// the loaded relations are queued as content items.
+func (l *loader) relations(uuid string) ([]*relation.Relation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if queue := l.referrers.queue; len(queue) > 0 {
+		l.referrers.queue = nil
+
+		rels, err := l.schema.relations.SourcesOf(l.ctx, queue)
+		if err != nil {
+			return nil, err
+		}
+
+		b := l.batch(contentInterface)
+		for _, rel := range rels {
+			l.referrers.rels[rel.Target] = append(l.referrers.rels[rel.Target], rel)
+			if _, ok := b.nodes[rel.Source]; !ok {
+				b.queue = append(b.queue, rel.Source)
+				b.nodes[rel.Source] = nil
+			}
+		}
+	}
+
+	return l.referrers.rels[uuid], nil
+}
+
+// locale returns the locale all content items loaded during the request are served in. It is empty if no items are
+// loaded, items are not localized or they are served in different locales.
+func (l *loader) locale() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	locale := ""
+	for _, n := range l.batch(contentInterface).nodes {
+		if n == nil {
+			continue
+		}
+
+		v, _ := n.values[localeField].(string)
+		if v == "" || (locale != "" && v != locale) {
+			return ""
+		}
+		locale = v
+	}
+
+	return locale
+}
+
+// newLoader creates a new loader of a request.
+func newLoader(ctx context.Context, s *schema) *loader {
+	return &loader{
+		ctx:       ctx,
+		schema:    s,
+		batches:   make(map[string]*batch),
+		referrers: referrers{rels: make(map[string][]*relation.Relation)},
+	}
+}
@@ -0,0 +1,414 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgtype"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/i18n"
+)
+
+type previewKey struct{}
+
+// kind is a kind of field values.
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindFloat
+	kindBoolean
+	kindDateTime
+	kindID
+)
+
+const (
+	entityInterface  = "Entity"  // GraphQL interface implemented by all objects
+	contentInterface = "Content" // GraphQL interface implemented by content items of all types
+	localeField      = "locale"  // field of localized content items holding the locale an item is served in
+)
+
+// field describes a field of a GraphQL object.
+type field struct {
+	name     string // GraphQL field name
+	kind     kind   // kind of values
+	multiple bool   // whether the field holds a list of values
+	ref      string // name of a referenced object or interface, empty if the field is not a reference
+	expr     string // SQL expression selecting the value, empty if the field cannot be filtered and sorted
+	jsonb    bool   // whether the expression of a multiple field is a jsonb array rather than an SQL array
+	required bool   // whether the field always has a value
+	richText bool   // whether the field holds Markdown which may be rendered to HTML
+}
+
+// node is an entity resolved by the API.
+type node struct {
+	object string                 // GraphQL object name
+	uuid   string                 // entity UUID
+	values map[string]interface{} // field values by field names
+}
+
+// source is a storage of entities of a single object.
+type source interface {
+	// load returns entities with the UUIDs. Missing entities are omitted.
+	load(ctx context.Context, uuids []string) ([]*node, error)
+
+	// list returns entities matching the conditions.
+	list(ctx context.Context, where []content.Cond, orderBy string, limit, offset int) ([]*node, error)
+
+	// count returns the number of entities matching the conditions.
+	count(ctx context.Context, where []content.Cond) (int, error)
+}
+
+// object describes a GraphQL object served by the API.
+type object struct {
+	name   string   // GraphQL object name
+	fields []*field // fields including the Entity interface ones
+	source source
+	item   bool // whether the object is a content type
+}
+
+// field returns a field by its name or nil.
+func (o *object) field(name string) *field {
+	for _, f := range o.fields {
+		if f.name == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// queryName returns the name of the root query field returning a single entity.
+func (o *object) queryName() string {
+	r := []rune(o.name)
+	r[0] = unicode.ToLower(r[0])
+
+	return string(r)
+}
+
+// entityFields returns the fields of the Entity interface.
+func entityFields() []*field {
+	return []*field{
+		{name: "uuid", kind: kindID, expr: "uuid", required: true},
+		{name: "createdAt", kind: kindDateTime, expr: "created_at", required: true},
+		{name: "updatedAt", kind: kindDateTime, expr: "updated_at", required: true},
+	}
+}
+
+// contentFields returns the fields of the Content interface. Localized items report the locale they are served in.
+func contentFields(localized bool) []*field {
+	fields := append(entityFields(),
+		&field{name: "publishedAt", kind: kindDateTime, expr: "published_at"},
+		&field{name: "revision", kind: kindInt, expr: "revision", required: true},
+	)
+	if localized {
+		fields = append(fields, &field{name: localeField, kind: kindString, required: true})
+	}
+
+	return fields
+}
+
+// typeName converts a content type name to a GraphQL object name, e.g. blog_post to BlogPost.
+func typeName(name string) string {
+	var b strings.Builder
+
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+
+	return b.String()
+}
+
+// itemObject describes a content type as a GraphQL object. The fields which names clash with the Content interface
+// fields are omitted.
+func itemObject(t *content.Type, objects map[string]bool, localized bool) *object {
+	o := &object{
+		name:   typeName(t.Name),
+		fields: contentFields(localized),
+		item:   true,
+	}
+
+	for _, tf := range t.Fields {
+		if o.field(tf.Name) != nil {
+			continue
+		}
+
+		f := &field{name: tf.Name, multiple: tf.Multiple}
+		cast := ""
+
+		switch tf.Type {
+		case content.FieldInteger:
+			f.kind, cast = kindInt, "numeric"
+		case content.FieldNumber:
+			f.kind, cast = kindFloat, "numeric"
+		case content.FieldBoolean:
+			f.kind, cast = kindBoolean, "boolean"
+		case content.FieldDateTime:
+			f.kind, cast = kindDateTime, "timestamptz"
+		case content.FieldReference:
+			f.kind, f.ref = kindID, contentInterface
+			if name := typeName(tf.RefType); tf.RefType != "" && objects[name] {
+				f.ref = name
+			}
+		case content.FieldMedia:
+			f.kind = kindID
+		case content.FieldRichText:
+			f.kind, f.richText = kindString, true
+		default:
+			f.kind = kindString
+		}
+
+		if f.multiple {
+			f.expr, f.jsonb = "data->'"+tf.Name+"'", true
+		} else if cast != "" {
+			f.expr = "(data->>'" + tf.Name + "')::" + cast
+		} else {
+			f.expr = "data->>'" + tf.Name + "'"
+		}
+
+		o.fields = append(o.fields, f)
+	}
+
+	return o
+}
+
+// itemNode converts a content item served in the locale to a node of the object. The locale is empty if items are
+// not localized.
+func itemNode(o *object, item *content.Item, locale string) *node {
+	n := &node{object: o.name, uuid: item.GetUUID(), values: map[string]interface{}{
+		"uuid":        item.GetUUID(),
+		"createdAt":   item.GetCreatedAt(),
+		"updatedAt":   item.GetUpdatedAt(),
+		"publishedAt": nil,
+		"revision":    item.Revision,
+	}}
+	if locale != "" {
+		n.values[localeField] = locale
+	}
+	if item.PublishedAt.Status == pgtype.Present {
+		n.values["publishedAt"] = item.PublishedAt.Time
+	}
+
+	for _, f := range o.fields {
+		if _, meta := n.values[f.name]; meta {
+			continue
+		}
+
+		v, ok := item.Data[f.name]
+		if !ok {
+			continue
+		}
+
+		if f.kind == kindDateTime {
+			v = parseTimes(v)
+		}
+		n.values[f.name] = v
+	}
+
+	return n
+}
+
+// parseTimes converts RFC 3339 strings, single or in a list, to time values.
+func parseTimes(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+		return nil
+	case []interface{}:
+		r := make([]interface{}, len(v))
+		for i := range v {
+			r[i] = parseTimes(v[i])
+		}
+		return r
+	}
+
+	return v
+}
+
+// itemSource is a source of live content items. It serves items of a single type or, if the type is empty, items of
+// all types known to the schema.
+//
+// If locales are set, items are served in the request locale, see i18n.LocaleFrom: data of an item is taken from its
+// variant in the first locale of the locale fallback chain the item has data in. Filters and sorting apply to the
+// data in the default locale.
+type itemSource struct {
+	store   *content.Store
+	typ     string
+	objects map[string]*object // by content type names
+	locales *i18n.Resolver
+}
+
+func (s *itemSource) query(where []content.Cond) content.Query {
+	return content.Query{
+		Type:  s.typ,
+		Where: append([]content.Cond{{SQL: content.LiveSQL("")}}, where...),
+	}
+}
+
+func (s *itemSource) nodes(ctx context.Context, items []*content.Item) ([]*node, error) {
+	locales, err := s.localize(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*node, 0, len(items))
+	for i, item := range items {
+		if o := s.objects[item.Type]; o != nil {
+			nodes = append(nodes, itemNode(o, item, locales[i]))
+		}
+	}
+
+	return nodes, nil
+}
+
+// localize replaces data of the items with their variants in the request locale or its fallbacks and returns the
+// locales the items are served in. The locales are empty if items are not localized.
+func (s *itemSource) localize(ctx context.Context, items []*content.Item) ([]string, error) {
+	locales := make([]string, len(items))
+	if s.locales == nil || len(items) == 0 {
+		return locales, nil
+	}
+
+	dft := s.locales.Default()
+	chain := s.locales.Chain(i18n.LocaleFrom(ctx))
+
+	var (
+		uuids   = make([]string, len(items))
+		lookup  = make([]string, 0, len(chain))
+		variant = make(map[string]*content.Variant) // by item UUIDs and locales
+	)
+	for i, item := range items {
+		uuids[i] = item.GetUUID()
+	}
+	for _, l := range chain {
+		if l == dft {
+			break
+		}
+		lookup = append(lookup, l)
+	}
+
+	variants, err := s.store.VariantsOf(ctx, uuids, lookup)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range variants {
+		variant[v.ItemUUID+" "+v.Locale] = v
+	}
+
+	for i, item := range items {
+		locales[i] = dft
+		for _, l := range lookup {
+			if v := variant[item.GetUUID()+" "+l]; v != nil {
+				item.Data, locales[i] = v.Data, l
+				break
+			}
+		}
+	}
+
+	return locales, nil
+}
+
+func (s *itemSource) load(ctx context.Context, uuids []string) ([]*node, error) {
+	q := s.query([]content.Cond{{SQL: "uuid = ANY($1)", Args: []interface{}{uuids}}})
+
+	items, err := s.store.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	preview, err := s.preview(ctx, uuids)
+	if err != nil {
+		return nil, err
+	}
+	if preview != nil {
+		for i := 0; i < len(items); i++ {
+			if items[i].GetUUID() == preview.GetUUID() {
+				items = append(items[:i], items[i+1:]...)
+				i--
+			}
+		}
+	}
+
+	nodes, err := s.nodes(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	// The previewed revision holds data in the default locale, variants would replace it with published data
+	if preview != nil && s.objects[preview.Type] != nil {
+		locale := ""
+		if s.locales != nil {
+			locale = s.locales.Default()
+		}
+		nodes = append(nodes, itemNode(s.objects[preview.Type], preview, locale))
+	}
+
+	return nodes, nil
+}
+
+// preview returns the item revision granted by the preview token of the request if the item is one of the UUIDs and
+// is served by the source. Otherwise it returns nil.
+func (s *itemSource) preview(ctx context.Context, uuids []string) (*content.Item, error) {
+	claims, _ := ctx.Value(previewKey{}).(*content.PreviewClaims)
+	if claims == nil {
+		return nil, nil
+	}
+
+	found := false
+	for _, uuid := range uuids {
+		found = found || uuid == claims.Subject
+	}
+	if !found {
+		return nil, nil
+	}
+
+	item, err := s.store.Get(ctx, claims.Subject)
+	if errors.Is(err, content.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if s.typ != "" && item.Type != s.typ {
+		return nil, nil
+	}
+
+	r, err := s.store.Revision(ctx, claims.Subject, claims.Revision)
+	if errors.Is(err, content.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	item.Data, item.Revision = r.Data, r.Number
+
+	return item, nil
+}
+
+func (s *itemSource) list(ctx context.Context, where []content.Cond, orderBy string, limit, offset int) ([]*node,
+	error) {
+	q := s.query(where)
+	q.OrderBy, q.Limit, q.Offset = orderBy, limit, offset
+
+	items, err := s.store.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.nodes(ctx, items)
+}
+
+func (s *itemSource) count(ctx context.Context, where []content.Cond) (int, error) {
+	return s.store.Count(ctx, s.query(where))
+}
@@ -0,0 +1,129 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/delivery"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/security"
+)
+
+// newLocalizedAPI creates an API serving articles in English, German and French, and returns it with a published
+// article which has a German variant.
+func newLocalizedAPI(t *testing.T) (*delivery.API, *content.Store, *content.Item) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+
+	types := content.NewRegistry(db)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "article", Fields: []content.Field{
+		{Name: "title", Type: content.FieldString},
+	}}))
+
+	store := content.NewStore(db)
+	item := &content.Item{Type: "article", Data: map[string]interface{}{"title": "Hello"}}
+	require.NoError(t, store.Create(ctx, item))
+	_, err := db.Exec(ctx, "UPDATE content_items SET status = 'published', published_at = now() WHERE uuid = $1",
+		item.GetUUID())
+	require.NoError(t, err)
+	require.NoError(t, store.SaveVariant(ctx, &content.Variant{
+		ItemUUID: item.GetUUID(), Locale: "de", Data: map[string]interface{}{"title": "Hallo"},
+	}))
+
+	cfg := config.NewTesting("delivery")
+	cfg.Set("i18n.locales", []string{"de", "fr"})
+	locales, err := i18n.New(cfg)
+	require.NoError(t, err)
+
+	return delivery.New(cfg, db, delivery.WithTypes(types), delivery.WithLocales(locales)), store, item
+}
+
+// getArticle requests an article in the language with the preview token, which may be empty.
+func getArticle(t *testing.T, api *delivery.API, uuid, lang, token string) (*httptest.ResponseRecorder,
+	map[string]interface{}) {
+	query := `{ article(uuid: "` + uuid + `") { title locale } }`
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil)
+	req.Header.Set("Accept-Language", lang)
+	if token != "" {
+		req.Header.Set(delivery.PreviewHeader, token)
+	}
+	api.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var r result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+	require.Empty(t, r.Errors)
+	article, _ := r.Data["article"].(map[string]interface{})
+
+	return w, article
+}
+
+func TestLocalizedPreview(t *testing.T) {
+	api, store, item := newLocalizedAPI(t)
+
+	w, article := getArticle(t, api, item.GetUUID(), "de", "")
+	require.Equal(t, "Hallo", article["title"])
+	require.Equal(t, "de", article["locale"])
+	require.Equal(t, "de", w.Header().Get("Content-Language"))
+
+	// A previewed revision is served as is rather than replaced with a variant
+	item.Data = map[string]interface{}{"title": "Hello again"}
+	require.NoError(t, store.Update(context.Background(), item))
+
+	security.SetHMACKey([]byte("secret"))
+	token, _, err := content.NewPreviewToken(item.GetUUID(), item.Revision, time.Hour)
+	require.NoError(t, err)
+
+	w, article = getArticle(t, api, item.GetUUID(), "de", token)
+	require.Equal(t, "Hello again", article["title"])
+	require.Equal(t, "en", article["locale"])
+	require.Equal(t, "en", w.Header().Get("Content-Language"))
+}
+
+func TestContentLanguage(t *testing.T) {
+	api, store, item := newLocalizedAPI(t)
+
+	// Without a French variant the item falls back to the default locale
+	w, article := getArticle(t, api, item.GetUUID(), "fr", "")
+	require.Equal(t, "Hello", article["title"])
+	require.Equal(t, "en", article["locale"])
+	require.Equal(t, "en", w.Header().Get("Content-Language"))
+
+	// Nothing is served
+	w, article = getArticle(t, api, "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f", "de", "")
+	require.Nil(t, article)
+	require.Empty(t, w.Header().Get("Content-Language"))
+
+	// Items of a response are served in different locales
+	other := &content.Item{Type: "article", Data: map[string]interface{}{"title": "World"}}
+	require.NoError(t, store.Create(context.Background(), other))
+	_, err := store.DB().Exec(context.Background(), "UPDATE content_items SET status = 'published', "+
+		"published_at = now() WHERE uuid = $1", other.GetUUID())
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(
+		"{ articleList { items { title locale } } }"), nil)
+	req.Header.Set("Accept-Language", "de")
+	api.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `{"locale":"de","title":"Hallo"}`)
+	require.Contains(t, w.Body.String(), `{"locale":"en","title":"World"}`)
+	require.Empty(t, w.Header().Get("Content-Language"))
+}
@@ -0,0 +1,92 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrPersistedQueryNotFound is returned when a request refers to an unknown persisted query. The message is the
+	// one automatic persisted queries clients expect.
+	ErrPersistedQueryNotFound = errors.New("PersistedQueryNotFound")
+
+	// ErrPersistedQueryMismatch is returned when a query does not match its hash.
+	ErrPersistedQueryMismatch = errors.New("provided sha256Hash does not match query")
+
+	// ErrNotPersisted is returned on attempt to execute an arbitrary query when only persisted queries are allowed.
+	ErrNotPersisted = errors.New("only persisted queries are allowed")
+)
+
+// persistedQueries is a storage of queries known in advance and queries persisted automatically by clients.
+type persistedQueries struct {
+	mu    sync.RWMutex
+	known map[string]string // by ids and hashes
+	auto  map[string]string // by hashes
+}
+
+func hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// add stores a query known in advance. The query can be referred to by the id or by its hash.
+func (p *persistedQueries) add(id, query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.known[id] = query
+	p.known[hash(query)] = query
+}
+
+// get returns a query by its id or hash.
+func (p *persistedQueries) get(id string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if q, ok := p.known[id]; ok {
+		return q, true
+	}
+	q, ok := p.auto[id]
+
+	return q, ok
+}
+
+// isKnown checks whether a query is known in advance.
+func (p *persistedQueries) isKnown(query string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.known[hash(query)]
+
+	return ok
+}
+
+// persist stores a query sent by a client with its hash. If the storage is full, an arbitrary query is evicted.
+func (p *persistedQueries) persist(sha256Hash, query string) error {
+	if hash(query) != sha256Hash {
+		return ErrPersistedQueryMismatch
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.auto[sha256Hash]; !ok && len(p.auto) >= MaxPersistedQueries {
+		for h := range p.auto {
+			delete(p.auto, h)
+			break
+		}
+	}
+	p.auto[sha256Hash] = query
+
+	return nil
+}
+
+func newPersistedQueries() *persistedQueries {
+	return &persistedQueries{known: make(map[string]string), auto: make(map[string]string)}
+}
@@ -0,0 +1,401 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/relation"
+	"ampho.xyz/core/richtext"
+)
+
+// referrersField is the field of content objects listing items referring to an item.
+const referrersField = "referencedBy"
+
+// Values of the format argument of rich-text fields.
+const (
+	formatRaw  = "RAW"
+	formatHTML = "HTML"
+)
+
+// formatEnum is the type of the format argument of rich-text fields.
+var formatEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "RichTextFormat",
+	Description: "A form of a rich-text field value.",
+	Values: graphql.EnumValueConfigMap{
+		formatRaw:  &graphql.EnumValueConfig{Value: formatRaw, Description: "Markdown as stored."},
+		formatHTML: &graphql.EnumValueConfig{Value: formatHTML, Description: "Rendered and sanitised HTML."},
+	},
+})
+
+// schema is a GraphQL schema with the objects it serves.
+type schema struct {
+	gql       graphql.Schema
+	objects   map[string]*object // by GraphQL names
+	content   *itemSource        // content items of all types
+	relations *relation.Relations
+	maxLimit  int
+}
+
+// page is a result of a list query.
+type page struct {
+	object  *object
+	where   []content.Cond
+	orderBy string
+	limit   int
+	offset  int
+}
+
+// source returns the source of an object or interface.
+func (s *schema) source(object string) source {
+	if object == contentInterface {
+		return s.content
+	}
+
+	return s.objects[object].source
+}
+
+// newSchema builds a schema serving registered structs and content types. Content types which names clash with
+// other objects are skipped. Rich-text fields may be rendered to HTML if the renderer is not nil, content items are
+// localized if locales are not nil. Skipped types and fields are logged with lg.
+func newSchema(lg *logger.Logger, store *content.Store, relations *relation.Relations, types []*content.Type,
+	structs []*object, renderer *richtext.Renderer, locales *i18n.Resolver, limit, maxLimit int) (*schema, error) {
+	s := &schema{
+		objects:   make(map[string]*object),
+		content:   &itemSource{store: store, objects: make(map[string]*object), locales: locales},
+		relations: relations,
+		maxLimit:  maxLimit,
+	}
+	queries := map[string]bool{"content": true}
+	var ordered []*object
+
+	add := func(o *object) bool {
+		q := o.queryName()
+		if s.objects[o.name] != nil || o.name == entityInterface || o.name == contentInterface || queries[q] ||
+			queries[q+"List"] {
+			return false
+		}
+
+		s.objects[o.name] = o
+		queries[q], queries[q+"List"] = true, true
+		ordered = append(ordered, o)
+
+		return true
+	}
+
+	for _, o := range structs {
+		add(o)
+	}
+
+	names := make(map[string]bool)
+	for _, t := range types {
+		names[typeName(t.Name)] = true
+	}
+	for _, t := range types {
+		o := itemObject(t, names, locales != nil)
+		o.source = &itemSource{store: store, typ: t.Name, objects: s.content.objects, locales: locales}
+		if !add(o) {
+			lg.Warn("content type clashes with another object and is not served", "type", t.Name)
+			continue
+		}
+		s.content.objects[t.Name] = o
+	}
+
+	gqlObjects := make(map[string]*graphql.Object)
+	resolveType := func(p graphql.ResolveTypeParams) *graphql.Object {
+		if n, ok := p.Value.(*node); ok {
+			return gqlObjects[n.object]
+		}
+		return nil
+	}
+
+	entityIface := graphql.NewInterface(graphql.InterfaceConfig{
+		Name:        entityInterface,
+		Description: "An entity identified by UUID.",
+		Fields:      interfaceFields(entityFields()),
+		ResolveType: resolveType,
+	})
+	contentIface := graphql.NewInterface(graphql.InterfaceConfig{
+		Name:        contentInterface,
+		Description: "A live content item of any type.",
+		Fields:      interfaceFields(contentFields(locales != nil)),
+		ResolveType: resolveType,
+	})
+
+	outputType := func(f *field, ref string) graphql.Output {
+		var t graphql.Output = scalars[f.kind]
+		if ref == contentInterface {
+			t = contentIface
+		} else if ref != "" {
+			t = gqlObjects[ref]
+		}
+
+		if f.multiple {
+			return graphql.NewList(graphql.NewNonNull(t))
+		} else if f.required {
+			return graphql.NewNonNull(t)
+		}
+
+		return t
+	}
+
+	objectTypes := []graphql.Type{entityIface}
+	for _, o := range ordered {
+		o := o
+		refs := make(map[string]string)
+		for _, f := range o.fields {
+			if f.ref == contentInterface || s.objects[f.ref] != nil {
+				refs[f.name] = f.ref
+			} else if f.ref != "" {
+				lg.Warn("field references unknown object", "object", o.name, "field", f.name, "ref", f.ref)
+			}
+		}
+
+		interfaces := []*graphql.Interface{entityIface}
+		if o.item {
+			interfaces = append(interfaces, contentIface)
+		}
+
+		gqlObjects[o.name] = graphql.NewObject(graphql.ObjectConfig{
+			Name:       o.name,
+			Interfaces: interfaces,
+			Fields: graphql.FieldsThunk(func() graphql.Fields {
+				fields := make(graphql.Fields, len(o.fields))
+				for _, f := range o.fields {
+					fields[f.name] = &graphql.Field{
+						Type:    outputType(f, refs[f.name]),
+						Resolve: resolveField(f, refs[f.name]),
+					}
+					if f.richText && renderer != nil {
+						fields[f.name].Args = graphql.FieldConfigArgument{
+							"format": &graphql.ArgumentConfig{Type: formatEnum, DefaultValue: formatRaw},
+						}
+						fields[f.name].Resolve = resolveRichText(f, renderer)
+					}
+				}
+				if o.item && o.field(referrersField) == nil {
+					fields[referrersField] = &graphql.Field{
+						Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(contentIface))),
+						Description: "Live content items referring to the item, by any or the given relation.",
+						Args: graphql.FieldConfigArgument{
+							"relation": &graphql.ArgumentConfig{Type: graphql.String},
+						},
+						Resolve: resolveReferrers,
+					}
+				}
+				return fields
+			}),
+		})
+		objectTypes = append(objectTypes, gqlObjects[o.name])
+	}
+
+	root := graphql.Fields{
+		"content": &graphql.Field{
+			Type:        contentIface,
+			Description: "A live content item of any type.",
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveOne(contentInterface),
+		},
+	}
+
+	for _, o := range ordered {
+		obj := gqlObjects[o.name]
+		q := o.queryName()
+
+		root[q] = &graphql.Field{
+			Type: obj,
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveOne(o.name),
+		}
+
+		root[q+"List"] = &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+				Name: o.name + "Page",
+				Fields: graphql.Fields{
+					"items": &graphql.Field{
+						Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(obj))),
+						Resolve: resolveItems,
+					},
+					"totalCount": &graphql.Field{
+						Type:    graphql.NewNonNull(graphql.Int),
+						Resolve: resolveTotalCount,
+					},
+				},
+			})),
+			Args: graphql.FieldConfigArgument{
+				"filter": &graphql.ArgumentConfig{Type: filterInput(o)},
+				"sort":   &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(sortEnum(o)))},
+				"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: limit},
+				"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+			},
+			Resolve: s.resolveList(o),
+		}
+	}
+
+	var err error
+	s.gql, err = graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: root}),
+		Types: objectTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// interfaceFields converts fields to GraphQL interface fields.
+func interfaceFields(fields []*field) graphql.Fields {
+	r := make(graphql.Fields, len(fields))
+	for _, f := range fields {
+		var t graphql.Output = scalars[f.kind]
+		if f.required {
+			t = graphql.NewNonNull(t)
+		}
+		r[f.name] = &graphql.Field{Type: t}
+	}
+
+	return r
+}
+
+// resolveField returns a resolver of an object field. References to the ref object are resolved by the request
+// loader, the field value is returned as is if ref is empty.
+func resolveField(f *field, ref string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		v := p.Source.(*node).values[f.name]
+		if ref == "" || v == nil {
+			return v, nil
+		}
+
+		if f.multiple {
+			list, _ := v.([]interface{})
+			uuids := make([]string, 0, len(list))
+			for _, v := range validUUIDs(list) {
+				uuids = append(uuids, v.(string))
+			}
+			return loaderFrom(p.Context).loadList(ref, uuids), nil
+		}
+
+		if uuid, _ := v.(string); database.IsUUID(uuid) {
+			return loaderFrom(p.Context).load(ref, uuid), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// resolveRichText returns a resolver of a rich-text field rendering its value to HTML if requested.
+func resolveRichText(f *field, renderer *richtext.Renderer) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		v := p.Source.(*node).values[f.name]
+		if p.Args["format"] != formatHTML || v == nil {
+			return v, nil
+		}
+
+		if !f.multiple {
+			return render(p.Context, renderer, v)
+		}
+
+		list, _ := v.([]interface{})
+		r := make([]interface{}, len(list))
+		for i := range list {
+			s, err := render(p.Context, renderer, list[i])
+			if err != nil {
+				return nil, err
+			}
+			r[i] = s
+		}
+
+		return r, nil
+	}
+}
+
+// render renders a rich-text value, non-string values are returned as is.
+func render(ctx context.Context, renderer *richtext.Renderer, v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+
+	return renderer.Render(ctx, s)
+}
+
+func resolveReferrers(p graphql.ResolveParams) (interface{}, error) {
+	name, _ := p.Args["relation"].(string)
+
+	return loaderFrom(p.Context).loadReferrers(p.Source.(*node).uuid, name), nil
+}
+
+// resolveOne returns a resolver of a root query field returning an entity by UUID.
+func resolveOne(object string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if uuid, _ := p.Args["uuid"].(string); database.IsUUID(uuid) {
+			return loaderFrom(p.Context).load(object, uuid), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// resolveList returns a resolver of a root query field returning a page of entities.
+func (s *schema) resolveList(o *object) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		limit, _ := p.Args["limit"].(int)
+		offset, _ := p.Args["offset"].(int)
+		if limit < 0 || offset < 0 {
+			return nil, errors.New("limit and offset must not be negative")
+		}
+		if limit > s.maxLimit {
+			limit = s.maxLimit
+		}
+
+		filter, _ := p.Args["filter"].(map[string]interface{})
+
+		return &page{
+			object:  o,
+			where:   filterConds(o, filter),
+			orderBy: orderBy(p.Args["sort"]),
+			limit:   limit,
+			offset:  offset,
+		}, nil
+	}
+}
+
+func resolveItems(p graphql.ResolveParams) (interface{}, error) {
+	pg := p.Source.(*page)
+	if pg.limit == 0 {
+		return []interface{}{}, nil
+	}
+
+	nodes, err := pg.object.source.list(p.Context, pg.where, pg.orderBy, pg.limit, pg.offset)
+	if err != nil {
+		return nil, err
+	}
+	loaderFrom(p.Context).prime(nodes)
+
+	r := make([]interface{}, len(nodes))
+	for i := range nodes {
+		r[i] = nodes[i]
+	}
+
+	return r, nil
+}
+
+func resolveTotalCount(p graphql.ResolveParams) (interface{}, error) {
+	pg := p.Source.(*page)
+
+	return pg.object.source.count(p.Context, pg.where)
+}
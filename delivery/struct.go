@@ -0,0 +1,300 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+)
+
+var (
+	objectNameRe    = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	matchFirstCapRe = regexp.MustCompile("(.)([A-Z][a-z]+)")
+	matchAllCapRe   = regexp.MustCompile("([a-z0-9])([A-Z])")
+
+	entityType = reflect.TypeOf(database.Entity{})
+	timeType   = reflect.TypeOf(time.Time{})
+	uuidType   = reflect.TypeOf(pgtype.UUID{})
+)
+
+// structField maps a struct field to a GraphQL field.
+type structField struct {
+	*field
+	index int // struct field index
+}
+
+// structSource is a source of entities stored in a table and scanned into structs.
+type structSource struct {
+	db      *database.Database
+	object  string
+	table   string // quoted table name
+	columns string // selected columns
+	typ     reflect.Type
+	fields  []structField
+}
+
+// newStructObject describes a struct type as a GraphQL object stored in a table.
+func newStructObject(db *database.Database, name, table string, v interface{}) (*object, error) {
+	if !objectNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid object name %q", name)
+	}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", name)
+	}
+
+	s := &structSource{
+		db:     db,
+		object: name,
+		table:  pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+		typ:    t,
+	}
+	o := &object{name: name, fields: entityFields(), source: s}
+	columns := []string{"id", "uuid", "created_at", "updated_at", "deleted_at"}
+	hasEntity := false
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type == entityType {
+			hasEntity = true
+			continue
+		}
+		if sf.PkgPath != "" || sf.Anonymous {
+			continue
+		}
+
+		column, ok := sf.Tag.Lookup("db")
+		if ok {
+			column = strings.Split(column, ",")[0]
+		} else {
+			column = toSnakeCase(sf.Name)
+		}
+		if column == "-" {
+			continue
+		}
+
+		k, multiple, ok := kindOf(sf.Type)
+		if !ok {
+			continue
+		}
+
+		fieldName := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if fieldName == "" || fieldName == "-" {
+			fieldName = strings.ToLower(sf.Name[:1]) + sf.Name[1:]
+		}
+		if o.field(fieldName) != nil {
+			return nil, fmt.Errorf("%s has duplicate field %q", name, fieldName)
+		}
+
+		f := &field{
+			name:     fieldName,
+			kind:     k,
+			multiple: multiple,
+			ref:      sf.Tag.Get("ref"),
+			expr:     pgx.Identifier{column}.Sanitize(),
+		}
+		if f.ref != "" && k != kindID {
+			return nil, fmt.Errorf("%s field %q references %s, but is not a UUID", name, fieldName, f.ref)
+		}
+
+		o.fields = append(o.fields, f)
+		s.fields = append(s.fields, structField{f, i})
+		columns = append(columns, f.expr)
+	}
+
+	if !hasEntity {
+		return nil, fmt.Errorf("%s does not embed database.Entity", name)
+	}
+
+	s.columns = strings.Join(columns, ", ")
+
+	return o, nil
+}
+
+// kindOf returns a kind of values of a Go type and whether the type is a slice.
+func kindOf(t reflect.Type) (k kind, multiple, ok bool) {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		k, _, ok = kindOf(t.Elem())
+		return k, true, ok
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, reflect.TypeOf(pgtype.Timestamp{}), reflect.TypeOf(pgtype.Timestamptz{}),
+		reflect.TypeOf(pgtype.Date{}):
+		return kindDateTime, false, true
+	case uuidType:
+		return kindID, false, true
+	case reflect.TypeOf(pgtype.Text{}), reflect.TypeOf(pgtype.Varchar{}):
+		return kindString, false, true
+	case reflect.TypeOf(pgtype.Int2{}), reflect.TypeOf(pgtype.Int4{}), reflect.TypeOf(pgtype.Int8{}):
+		return kindInt, false, true
+	case reflect.TypeOf(pgtype.Float4{}), reflect.TypeOf(pgtype.Float8{}), reflect.TypeOf(pgtype.Numeric{}):
+		return kindFloat, false, true
+	case reflect.TypeOf(pgtype.Bool{}):
+		return kindBoolean, false, true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return kindString, false, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return kindInt, false, true
+	case reflect.Float32, reflect.Float64:
+		return kindFloat, false, true
+	case reflect.Bool:
+		return kindBoolean, false, true
+	}
+
+	return 0, false, false
+}
+
+// plain converts a struct field value to a value understood by GraphQL scalars.
+func plain(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch x := v.Interface().(type) {
+	case pgtype.UUID:
+		if x.Status != pgtype.Present {
+			return nil
+		}
+		b, _ := x.EncodeText(nil, nil)
+		return string(b)
+	case pgtype.Numeric:
+		var f float64
+		if x.Status != pgtype.Present || x.AssignTo(&f) != nil {
+			return nil
+		}
+		return f
+	case interface{ Get() interface{} }:
+		r := x.Get()
+		if _, ok := r.(pgtype.Status); ok {
+			return nil
+		}
+		return r
+	}
+
+	if v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return nil
+		}
+		r := make([]interface{}, v.Len())
+		for i := range r {
+			r[i] = plain(v.Index(i))
+		}
+		return r
+	}
+
+	return v.Interface()
+}
+
+func (s *structSource) nodes(rows reflect.Value) []*node {
+	nodes := make([]*node, rows.Len())
+
+	for i := range nodes {
+		row := rows.Index(i)
+		e := row.Interface().(interface {
+			GetUUID() string
+			GetCreatedAt() time.Time
+			GetUpdatedAt() time.Time
+		})
+
+		n := &node{object: s.object, uuid: e.GetUUID(), values: map[string]interface{}{
+			"uuid":      e.GetUUID(),
+			"createdAt": e.GetCreatedAt(),
+			"updatedAt": e.GetUpdatedAt(),
+		}}
+		for _, f := range s.fields {
+			n.values[f.name] = plain(row.Elem().Field(f.index))
+		}
+
+		nodes[i] = n
+	}
+
+	return nodes
+}
+
+func (s *structSource) selectNodes(ctx context.Context, sql string, args []interface{}) ([]*node, error) {
+	rows := reflect.New(reflect.SliceOf(reflect.PtrTo(s.typ)))
+
+	if err := s.db.SelectAll(ctx, rows.Interface(), sql, args...); err != nil {
+		return nil, err
+	}
+
+	return s.nodes(rows.Elem()), nil
+}
+
+// where returns the WHERE clause, including the keyword, selecting entities which are not deleted and match the
+// conditions.
+func (s *structSource) where(where []content.Cond) (string, []interface{}) {
+	sql, args := content.Where(nil, append([]content.Cond{{SQL: "deleted_at IS NULL"}}, where...)...)
+
+	return " WHERE " + sql, args
+}
+
+func (s *structSource) load(ctx context.Context, uuids []string) ([]*node, error) {
+	return s.selectNodes(ctx, "SELECT "+s.columns+" FROM "+s.table+" WHERE uuid = ANY($1) AND deleted_at IS NULL",
+		[]interface{}{uuids})
+}
+
+func (s *structSource) list(ctx context.Context, where []content.Cond, orderBy string, limit, offset int) ([]*node,
+	error) {
+	if orderBy == "" {
+		orderBy = "updated_at DESC"
+	}
+
+	sql, args := s.where(where)
+	sql = fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s, id DESC", s.columns, s.table, sql, orderBy)
+	if limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+
+	return s.selectNodes(ctx, sql, args)
+}
+
+func (s *structSource) count(ctx context.Context, where []content.Cond) (int, error) {
+	var n int
+
+	sql, args := s.where(where)
+	if err := s.db.QueryRow(ctx, "SELECT count(*) FROM "+s.table+sql, args...).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// toSnakeCase converts a struct field name to a column name the same way scany does.
+func toSnakeCase(s string) string {
+	s = matchFirstCapRe.ReplaceAllString(s, "${1}_${2}")
+	s = matchAllCapRe.ReplaceAllString(s, "${1}_${2}")
+
+	return strings.ToLower(s)
+}
@@ -0,0 +1,170 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package delivery_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/delivery"
+	"ampho.xyz/core/tracing"
+)
+
+type book struct {
+	database.Entity
+	Title  string
+	Author pgtype.UUID `ref:"Author"`
+}
+
+const structTables = `
+CREATE TABLE authors (
+	id         serial PRIMARY KEY,
+	uuid       uuid NOT NULL DEFAULT gen_random_uuid(),
+	created_at timestamp NOT NULL DEFAULT now(),
+	updated_at timestamp NOT NULL DEFAULT now(),
+	deleted_at timestamp,
+	name       text NOT NULL,
+	rating     integer,
+	tags       text[],
+	photo      uuid
+);
+CREATE TABLE books (
+	id         serial PRIMARY KEY,
+	uuid       uuid NOT NULL DEFAULT gen_random_uuid(),
+	created_at timestamp NOT NULL DEFAULT now(),
+	updated_at timestamp NOT NULL DEFAULT now(),
+	deleted_at timestamp,
+	title      text NOT NULL,
+	author     uuid
+)`
+
+// newStructAPI creates an API serving authors and books stored in a test database.
+func newStructAPI(t *testing.T) (*delivery.API, *database.Database) {
+	db := databasetest.New(t)
+	_, err := db.Exec(context.Background(), structTables)
+	require.NoError(t, err)
+
+	api := delivery.New(config.NewTesting("delivery"), db)
+	require.NoError(t, api.RegisterStruct("Author", "authors", &author{}))
+	require.NoError(t, api.RegisterStruct("Book", "books", &book{}))
+
+	return api, db
+}
+
+// addAuthor inserts an author and returns its UUID.
+func addAuthor(t *testing.T, db *database.Database, name string, rating interface{}, tags []string) string {
+	var uuid string
+	err := db.QueryRow(context.Background(), "INSERT INTO authors (name, rating, tags) VALUES ($1, $2, $3) "+
+		"RETURNING uuid::text", name, rating, tags).Scan(&uuid)
+	require.NoError(t, err)
+
+	return uuid
+}
+
+// listNames runs an authorList query with the arguments and returns the names of the authors and the total count.
+func listNames(t *testing.T, api *delivery.API, args string) ([]string, float64) {
+	code, r := post(t, api, map[string]string{
+		"query": "{ authorList(" + args + ") { totalCount items { name } } }",
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, r.Errors, args)
+
+	list := r.Data["authorList"].(map[string]interface{})
+	var names []string
+	for _, item := range list["items"].([]interface{}) {
+		names = append(names, item.(map[string]interface{})["name"].(string))
+	}
+
+	return names, list["totalCount"].(float64)
+}
+
+func TestStructFilterSort(t *testing.T) {
+	api, db := newStructAPI(t)
+
+	addAuthor(t, db, "Ann", 5, []string{"go", "sql"})
+	addAuthor(t, db, "Bob", 3, []string{"rust"})
+	addAuthor(t, db, "Cid", nil, nil)
+	deleted := addAuthor(t, db, "Dan", 5, []string{"go"})
+	_, err := db.Exec(context.Background(), "UPDATE authors SET deleted_at = now() WHERE uuid = $1", deleted)
+	require.NoError(t, err)
+
+	for args, want := range map[string][]string{
+		`filter: {rating: {gte: 4}}, sort: name_ASC`:       {"Ann"},
+		`filter: {rating: {ne: 5}}, sort: name_ASC`:        {"Bob", "Cid"},
+		`filter: {rating: {in: [3, 4]}}`:                   {"Bob"},
+		`filter: {name: {contains: "B"}}`:                  {"Bob"},
+		`filter: {tags: {contains: "go"}}`:                 {"Ann"},
+		`filter: {name: {eq: "Ann"}, rating: {lt: 5}}`:     nil,
+		`filter: {uuid: {eq: "malformed"}}`:                nil,
+		`sort: rating_DESC`:                                {"Ann", "Bob", "Cid"},
+		`sort: rating_ASC`:                                 {"Bob", "Ann", "Cid"},
+		`sort: [rating_ASC, name_DESC], limit: 2`:          {"Bob", "Ann"},
+		`sort: name_DESC, limit: 2, offset: 1`:             {"Bob", "Ann"},
+		`filter: {name: {in: ["Ann", "Dan"]}}, limit: 0`:   nil,
+		`filter: {name: {in: ["Ann", "Dan"]}}, offset: 10`: nil,
+	} {
+		names, _ := listNames(t, api, args)
+		require.Equal(t, want, names, args)
+	}
+
+	// Counts ignore pagination, but not filters and deleted entities
+	_, count := listNames(t, api, `sort: name_DESC, limit: 1`)
+	require.Equal(t, float64(3), count)
+	_, count = listNames(t, api, `filter: {name: {in: ["Ann", "Dan"]}}, limit: 0`)
+	require.Equal(t, float64(1), count)
+
+	code, r := post(t, api, map[string]string{"query": `{ author(uuid: "` + deleted + `") { name } }`})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, r.Errors)
+	require.Nil(t, r.Data["author"])
+}
+
+func TestLoaderBatching(t *testing.T) {
+	api, db := newStructAPI(t)
+
+	ann := addAuthor(t, db, "Ann", 5, nil)
+	bob := addAuthor(t, db, "Bob", 3, nil)
+	for title, author := range map[string]string{"A": ann, "B": bob, "C": ann, "D": bob} {
+		_, err := db.Exec(context.Background(), "INSERT INTO books (title, author) VALUES ($1, $2)", title, author)
+		require.NoError(t, err)
+	}
+
+	exp := tracetest.NewInMemoryExporter()
+	p := tracing.NewWithExporter(config.NewTesting("delivery"), exp, true)
+	t.Cleanup(func() { _ = p.Shutdown(context.Background()) })
+
+	code, r := post(t, api, map[string]string{
+		"query": "{ bookList(sort: title_ASC) { items { title author { name } } } }",
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, r.Errors)
+
+	items := r.Data["bookList"].(map[string]interface{})["items"].([]interface{})
+	require.Len(t, items, 4)
+	for i, name := range []string{"Ann", "Bob", "Ann", "Bob"} {
+		author := items[i].(map[string]interface{})["author"].(map[string]interface{})
+		require.Equal(t, name, author["name"])
+	}
+
+	// Authors of all books are loaded at once
+	queries := 0
+	for _, s := range exp.GetSpans() {
+		for _, a := range s.Attributes {
+			if a.Key == "db.statement" && strings.Contains(a.Value.AsString(), `FROM "authors"`) {
+				queries++
+			}
+		}
+	}
+	require.Equal(t, 1, queries)
+}
@@ -0,0 +1,62 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event is an event delivered to subscribers.
+type Event struct {
+	Name    string
+	Time    time.Time
+	Payload interface{}
+}
+
+// Handler is an event handler.
+type Handler func(ctx context.Context, e Event)
+
+// Bus is an in-process event bus. Handlers are called synchronously in order of subscription.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// Subscribe schedules a handler to be called when an event with the name is published. Handlers subscribed to an
+// empty name receive all events.
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handlers == nil {
+		b.handlers = make(map[string][]Handler)
+	}
+
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+// Publish publishes an event with the name and payload.
+func (b *Bus) Publish(ctx context.Context, name string, payload interface{}) {
+	var handlers []Handler
+
+	b.mu.RLock()
+	handlers = append(handlers, b.handlers[name]...)
+	if name != "" {
+		handlers = append(handlers, b.handlers[""]...)
+	}
+	b.mu.RUnlock()
+
+	e := Event{Name: name, Time: time.Now(), Payload: payload}
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
+
+// NewBus creates a new event bus.
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
@@ -0,0 +1,31 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package event_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/event"
+)
+
+func TestBus(t *testing.T) {
+	bus := event.NewBus()
+
+	var got []string
+	bus.Subscribe("foo", func(ctx context.Context, e event.Event) {
+		got = append(got, "foo:"+e.Payload.(string))
+	})
+	bus.Subscribe("", func(ctx context.Context, e event.Event) {
+		got = append(got, "all:"+e.Name)
+	})
+
+	bus.Publish(context.Background(), "foo", "1")
+	bus.Publish(context.Background(), "bar", "2")
+
+	require.Equal(t, []string{"foo:1", "all:foo", "all:bar"}, got)
+}
@@ -0,0 +1,6 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package event provides a simple in-process event bus.
+package event
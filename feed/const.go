@@ -0,0 +1,14 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package feed
+
+import "time"
+
+const (
+	DftLimit        = 20               // number of entries in a feed
+	DftMaxAge       = time.Minute * 15 // how long clients may cache a feed
+	DftTitleField   = "title"          // item data field used as an entry title
+	DftSummaryField = "summary"        // item data field used as an entry summary
+)
@@ -0,0 +1,18 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package feed provides RSS 2.0 and Atom 1.0 feeds of live content items.
+//
+// Besides the site-wide feed, feeds of sections are served. A section is a set of items whose permalinks share
+// a path prefix, optionally limited to a content type. Sections are configured under `feed.sections`:
+//
+//	feed:
+//	  sections:
+//	    blog:
+//	      title: Our blog
+//	      path: /blog
+//	      type: article
+//	      titleField: title
+//	      summaryField: summary
+package feed
@@ -0,0 +1,181 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is a feed to encode.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // URL of the site or section the feed belongs to
+	Self        string // URL of the feed itself
+	Updated     time.Time
+	Entries     []Entry
+}
+
+// Entry is a feed entry.
+type Entry struct {
+	ID        string // globally unique and permanent entry identifier, such as "urn:uuid:..."
+	Title     string
+	Summary   string
+	Link      string
+	Published time.Time
+	Updated   time.Time
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	XmlnsAtom string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published,omitempty"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary,omitempty"`
+}
+
+type atom struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// WriteRSS writes a feed in RSS 2.0 format.
+func WriteRSS(w io.Writer, f *Feed) error {
+	doc := rss{
+		Version:   "2.0",
+		XmlnsAtom: "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: rfc1123(f.Updated),
+			AtomLink:      rssLink{f.Self, "self", "application/rss+xml"},
+			Items:         make([]rssItem, len(f.Entries)),
+		},
+	}
+
+	for i, e := range f.Entries {
+		doc.Channel.Items[i] = rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{e.ID, false},
+			PubDate:     rfc1123(e.Published),
+			Description: e.Summary,
+		}
+	}
+
+	return write(w, doc)
+}
+
+// WriteAtom writes a feed in Atom 1.0 format. Since Atom requires update times, a feed without one is updated now
+// and entries without one are updated when published or, if that is unknown too, when the feed is.
+func WriteAtom(w io.Writer, f *Feed) error {
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	doc := atom{
+		ID:       f.Self,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  rfc3339(updated),
+		Links:    []atomLink{{f.Self, "self"}, {f.Link, "alternate"}},
+		Entries:  make([]atomEntry, len(f.Entries)),
+	}
+
+	for i, e := range f.Entries {
+		entryUpdated := e.Updated
+		if entryUpdated.IsZero() {
+			entryUpdated = e.Published
+		}
+		if entryUpdated.IsZero() {
+			entryUpdated = updated
+		}
+
+		doc.Entries[i] = atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Updated:   rfc3339(entryUpdated),
+			Published: rfc3339(e.Published),
+			Link:      atomLink{Href: e.Link},
+			Summary:   e.Summary,
+		}
+	}
+
+	return write(w, doc)
+}
+
+func write(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(doc)
+}
+
+func rfc1123(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,104 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package feed_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/feed"
+)
+
+func testFeed() *feed.Feed {
+	published := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 6, 2, 12, 30, 0, 0, time.UTC)
+
+	return &feed.Feed{
+		Title:       "Blog",
+		Description: "News & notes",
+		Link:        "https://example.com/blog",
+		Self:        "https://example.com/feeds/blog.rss",
+		Updated:     updated,
+		Entries: []feed.Entry{{
+			ID:        "urn:uuid:a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+			Title:     "Hello <World>",
+			Summary:   "First post",
+			Link:      "https://example.com/blog/hello-world",
+			Published: published,
+			Updated:   updated,
+		}},
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, feed.WriteRSS(&buf, testFeed()))
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title   string `xml:"title"`
+				Link    string `xml:"link"`
+				GUID    string `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+
+	require.Equal(t, "2.0", doc.Version)
+	require.Equal(t, "Blog", doc.Channel.Title)
+	require.Len(t, doc.Channel.Items, 1)
+	require.Equal(t, "Hello <World>", doc.Channel.Items[0].Title)
+	require.Equal(t, "https://example.com/blog/hello-world", doc.Channel.Items[0].Link)
+	require.Equal(t, "urn:uuid:a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", doc.Channel.Items[0].GUID)
+	require.Equal(t, "Tue, 01 Jun 2021 10:00:00 +0000", doc.Channel.Items[0].PubDate)
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, feed.WriteAtom(&buf, testFeed()))
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Link    struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+
+	require.Equal(t, "2021-06-02T12:30:00Z", doc.Updated)
+	require.Len(t, doc.Entries, 1)
+	require.Equal(t, "urn:uuid:a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", doc.Entries[0].ID)
+	require.Equal(t, "2021-06-02T12:30:00Z", doc.Entries[0].Updated)
+	require.Equal(t, "https://example.com/blog/hello-world", doc.Entries[0].Link.Href)
+}
+
+func TestWriteAtomEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	f := testFeed()
+	f.Updated, f.Entries = time.Time{}, nil
+	require.NoError(t, feed.WriteAtom(&buf, f))
+
+	var doc struct {
+		Updated string `xml:"updated"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+
+	updated, err := time.Parse(time.RFC3339, doc.Updated)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), updated, time.Minute)
+}
@@ -0,0 +1,195 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/spf13/cast"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+)
+
+// Section is a feed section.
+type Section struct {
+	Title        string
+	Description  string
+	Path         string // permalink path prefix of section items, such as "/blog"
+	Type         string // content type of section items, any type if empty
+	TitleField   string // item data field used as an entry title
+	SummaryField string // item data field used as an entry summary
+}
+
+// Feeds serves RSS and Atom feeds.
+type Feeds struct {
+	db         *database.Database
+	baseURL    string
+	trustProxy bool
+	limit      int
+	maxAge     time.Duration
+	site       Section
+	sections   map[string]Section
+}
+
+// Mount registers site-wide feed handlers /feed.rss and /feed.atom, and section feed handlers
+// /feeds/{section}.rss and /feeds/{section}.atom on a router.
+func (f *Feeds) Mount(r *mux.Router) {
+	r.HandleFunc("/feed.{format:rss|atom}", f.serve).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/feeds/{section:[a-zA-Z0-9_-]+}.{format:rss|atom}", f.serve).Methods(http.MethodGet,
+		http.MethodHead)
+}
+
+func (f *Feeds) serve(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	section := f.site
+	if name, ok := vars["section"]; ok {
+		if section, ok = f.sections[strings.ToLower(name)]; !ok {
+			_, _ = httputil.WriteStatus(w, http.StatusNotFound)
+			return
+		}
+	}
+
+	base := httputil.BaseURL(r, f.baseURL, f.trustProxy)
+	feed, err := f.build(r.Context(), section, base)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to build feed", "error", err)
+		_, _ = httputil.WriteStatus(w, http.StatusInternalServerError)
+		return
+	}
+	feed.Self = base + r.URL.Path
+
+	if f.checkCache(w, r, feed.Updated) {
+		return
+	}
+
+	if vars["format"] == "atom" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = WriteAtom(w, feed)
+	} else {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = WriteRSS(w, feed)
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to write feed", "error", err)
+	}
+}
+
+// checkCache sets caching headers, see httputil.CheckCache. Without a configured base URL the links are derived from
+// requests, so feeds are not stored in shared caches.
+func (f *Feeds) checkCache(w http.ResponseWriter, r *http.Request, modTime time.Time) bool {
+	if f.baseURL == "" {
+		return httputil.CheckPrivateCache(w, r, modTime, f.maxAge)
+	}
+
+	return httputil.CheckCache(w, r, modTime, f.maxAge)
+}
+
+// build selects the latest live items of a section.
+func (f *Feeds) build(ctx context.Context, s Section, base string) (*Feed, error) {
+	prefix := strings.TrimRight(s.Path, "/")
+	prefix = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+
+	rows, err := f.db.Query(ctx, "SELECT p.path, i.uuid::text, i.data, i.published_at, i.updated_at "+
+		"FROM permalinks p JOIN content_items i ON i.uuid = p.entity_uuid WHERE "+content.LiveSQL("i")+
+		" AND p.path LIKE $1 AND ($2 = '' OR i.type = $2) ORDER BY i.published_at DESC NULLS LAST, i.id DESC "+
+		"LIMIT $3", prefix+"/%", s.Type, f.limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := &Feed{Title: s.Title, Description: s.Description, Link: base + s.Path}
+
+	for rows.Next() {
+		var (
+			path, uuid string
+			data       map[string]interface{}
+			published  pgtype.Timestamptz
+			updated    pgtype.Timestamptz
+		)
+		if err = rows.Scan(&path, &uuid, &data, &published, &updated); err != nil {
+			return nil, err
+		}
+
+		feed.Entries = append(feed.Entries, Entry{
+			ID:        "urn:uuid:" + uuid,
+			Title:     cast.ToString(data[s.TitleField]),
+			Summary:   cast.ToString(data[s.SummaryField]),
+			Link:      base + path,
+			Published: published.Time,
+			Updated:   updated.Time,
+		})
+
+		if updated.Time.After(feed.Updated) {
+			feed.Updated = updated.Time
+		}
+	}
+
+	return feed, rows.Err()
+}
+
+// New creates new feeds.
+//
+// The base URL of the site is taken from `site.url` configuration key. If it is not set, the URL is derived from
+// requests, the scheme is taken from the proxy headers only if `service.trustProxy` is set, and feeds are not stored in
+// shared caches. The site-wide feed title is taken from `site.title`.
+func New(cfg config.Config, db *database.Database) (*Feeds, error) {
+	cfg.SetDefault("feed.limit", DftLimit)
+	cfg.SetDefault("feed.maxAge", DftMaxAge)
+	cfg.SetDefault("service.trustProxy", false)
+
+	f := &Feeds{
+		db:         db,
+		baseURL:    cfg.GetString("site.url"),
+		trustProxy: cfg.GetBool("service.trustProxy"),
+		limit:      cfg.GetInt("feed.limit"),
+		maxAge:     cfg.GetDuration("feed.maxAge"),
+		site: Section{
+			Title:        cfg.GetString("site.title"),
+			Description:  cfg.GetString("site.description"),
+			Path:         "/",
+			TitleField:   DftTitleField,
+			SummaryField: DftSummaryField,
+		},
+		sections: make(map[string]Section),
+	}
+
+	for name := range cfg.GetStringMap("feed.sections") {
+		key := "feed.sections." + name
+		s := Section{
+			Title:        cfg.GetString(key + ".title"),
+			Description:  cfg.GetString(key + ".description"),
+			Path:         cfg.GetString(key + ".path"),
+			Type:         cfg.GetString(key + ".type"),
+			TitleField:   cfg.GetString(key + ".titleField"),
+			SummaryField: cfg.GetString(key + ".summaryField"),
+		}
+
+		if s.Path == "" {
+			return nil, fmt.Errorf("feed section %s has no path", name)
+		}
+		if s.TitleField == "" {
+			s.TitleField = DftTitleField
+		}
+		if s.SummaryField == "" {
+			s.SummaryField = DftSummaryField
+		}
+
+		f.sections[strings.ToLower(name)] = s
+	}
+
+	return f, nil
+}
@@ -7,12 +7,23 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/georgysavva/scany v0.2.9
 	github.com/gorilla/mux v1.8.0
+	github.com/graphql-go/graphql v0.8.0
 	github.com/jackc/pgconn v1.9.0
 	github.com/jackc/pgtype v1.8.0
 	github.com/jackc/pgx/v4 v4.12.0
+	github.com/microcosm-cc/bluemonday v1.0.16
+	github.com/prometheus/client_golang v1.11.1
 	github.com/spf13/afero v1.2.1 // indirect
 	github.com/spf13/cast v1.4.1
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
+	github.com/yuin/goldmark v1.4.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
 	golang.org/x/mod v0.4.2 // indirect
+	golang.org/x/text v0.3.6
+	gopkg.in/yaml.v3 v3.0.1
 )
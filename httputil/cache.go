@@ -0,0 +1,51 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CheckCache sets Cache-Control and Last-Modified response headers. If the request has If-Modified-Since header and
+// the resource has not been modified since, it writes 304 status and returns true, so the caller must not write the
+// response body. Zero modTime skips Last-Modified, zero maxAge disables caching.
+func CheckCache(w http.ResponseWriter, r *http.Request, modTime time.Time, maxAge time.Duration) bool {
+	return checkCache(w, r, modTime, maxAge, "public")
+}
+
+// CheckPrivateCache is like CheckCache, but allows only clients to cache the response, not shared caches.
+func CheckPrivateCache(w http.ResponseWriter, r *http.Request, modTime time.Time, maxAge time.Duration) bool {
+	return checkCache(w, r, modTime, maxAge, "private")
+}
+
+func checkCache(w http.ResponseWriter, r *http.Request, modTime time.Time, maxAge time.Duration, scope string) bool {
+	if maxAge > 0 {
+		w.Header().Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	modTime = modTime.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modTime.After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
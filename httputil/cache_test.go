@@ -0,0 +1,48 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/httputil"
+)
+
+func TestCheckCache(t *testing.T) {
+	modTime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// No conditional header
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.False(t, httputil.CheckCache(rr, req, modTime, time.Hour))
+	require.Equal(t, "public, max-age=3600", rr.Header().Get("Cache-Control"))
+	require.Equal(t, "Tue, 01 Jun 2021 10:00:00 GMT", rr.Header().Get("Last-Modified"))
+
+	// Not modified
+	rr = httptest.NewRecorder()
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	require.True(t, httputil.CheckCache(rr, req, modTime.Add(time.Millisecond), time.Hour))
+	require.Equal(t, http.StatusNotModified, rr.Code)
+
+	// Modified
+	rr = httptest.NewRecorder()
+	require.False(t, httputil.CheckCache(rr, req, modTime.Add(time.Second), time.Hour))
+
+	// Caching disabled
+	rr = httptest.NewRecorder()
+	require.False(t, httputil.CheckCache(rr, httptest.NewRequest(http.MethodGet, "/", nil), time.Time{}, 0))
+	require.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+	require.Empty(t, rr.Header().Get("Last-Modified"))
+
+	// Not for shared caches
+	rr = httptest.NewRecorder()
+	require.False(t, httputil.CheckPrivateCache(rr, httptest.NewRequest(http.MethodGet, "/", nil), modTime, time.Hour))
+	require.Equal(t, "private, max-age=3600", rr.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,59 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// MaxJSONBodySize is the maximum size of a request body accepted by ReadJSON.
+const MaxJSONBodySize = 1 << 20
+
+// ReadJSON decodes a JSON request body into v.
+func ReadJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxJSONBodySize)).Decode(v)
+}
+
+// BaseURL returns the base URL of a site without a trailing slash. If base is empty, it is derived from the request
+// scheme and host. The scheme is taken from the X-Forwarded-Proto header only if the service runs behind a trusted
+// proxy. Clients choose the host, so responses built with a derived URL must not be stored in shared caches, see
+// CheckPrivateCache.
+func BaseURL(r *http.Request, base string, trustProxy bool) string {
+	if base != "" {
+		return strings.TrimRight(base, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil || trustProxy && r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// RemoteIP returns the IP address of a client. If the service runs behind a trusted proxy, the address is taken from
+// the X-Forwarded-For or X-Real-IP header set by the proxy, otherwise they are ignored, since clients may forge them.
+// The proxy appends the address of its client to X-Forwarded-For, so only the rightmost entry is trusted: the ones
+// before it come from the client.
+func RemoteIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if v := strings.Join(r.Header.Values("X-Forwarded-For"), ","); v != "" {
+			return strings.TrimSpace(v[strings.LastIndexByte(v, ',')+1:])
+		}
+		if v := r.Header.Get("X-Real-IP"); v != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
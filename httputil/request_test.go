@@ -0,0 +1,45 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/httputil"
+)
+
+func TestBaseURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+	req.Host = "example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	require.Equal(t, "https://example.org", httputil.BaseURL(req, "https://example.org/", false))
+	require.Equal(t, "http://example.com", httputil.BaseURL(req, "", false))
+	require.Equal(t, "https://example.com", httputil.BaseURL(req, "", true))
+}
+
+func TestRemoteIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:4321"
+	req.Header.Set("X-Forwarded-For", "192.0.2.66, 192.0.2.7")
+
+	require.Equal(t, "2001:db8::1", httputil.RemoteIP(req, false))
+	// The leftmost entries are forged by the client
+	require.Equal(t, "192.0.2.7", httputil.RemoteIP(req, true))
+
+	req.Header.Add("X-Forwarded-For", "192.0.2.9")
+	require.Equal(t, "192.0.2.9", httputil.RemoteIP(req, true))
+
+	req.Header.Del("X-Forwarded-For")
+	req.Header.Set("X-Real-IP", "192.0.2.8")
+	require.Equal(t, "192.0.2.8", httputil.RemoteIP(req, true))
+
+	req.RemoteAddr = "pipe"
+	require.Equal(t, "pipe", httputil.RemoteIP(req, false))
+}
@@ -23,6 +23,35 @@ func WriteJSON(w http.ResponseWriter, v interface{}) (int, error) {
 	return w.Write(bytes)
 }
 
+// WriteJSONStatus writes a JSON structure to a response with a status code and sets Content-Type header.
+func WriteJSONStatus(w http.ResponseWriter, code int, v interface{}) (int, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	return w.Write(bytes)
+}
+
+// ErrorResponse is a JSON error response body.
+type ErrorResponse struct {
+	Error   string      `json:"error"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// WriteError writes a JSON error response with a status code. If msg is empty, the status text is used.
+func WriteError(w http.ResponseWriter, code int, msg string, details interface{}) (int, error) {
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+
+	return WriteJSONStatus(w, code, ErrorResponse{msg, details})
+}
+
+// WriteStatus writes a plain text response containing the status text.
 func WriteStatus(w http.ResponseWriter, code int) (int, error) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -0,0 +1,40 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// TemplateExecutor executes named templates.
+type TemplateExecutor interface {
+	ExecuteTemplate(ctx context.Context, w io.Writer, name string, data interface{}) error
+}
+
+// WriteTemplate renders a template to an HTML response and sets caching headers, see CheckCache. The template is
+// rendered to a buffer first, so if it fails, 500 status is written instead of a partial page and the error returned.
+func WriteTemplate(w http.ResponseWriter, r *http.Request, t TemplateExecutor, name string, data interface{},
+	modTime time.Time, maxAge time.Duration) (int, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(r.Context(), &buf, name, data); err != nil {
+		_, _ = WriteStatus(w, http.StatusInternalServerError)
+		return 0, err
+	}
+
+	if CheckCache(w, r, modTime, maxAge) {
+		return 0, nil
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return 0, nil
+	}
+
+	return w.Write(buf.Bytes())
+}
@@ -0,0 +1,59 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/httputil"
+)
+
+type executor struct{}
+
+func (executor) ExecuteTemplate(_ context.Context, w io.Writer, name string, data interface{}) error {
+	if name != "page" {
+		return errors.New("template not found")
+	}
+
+	_, err := io.WriteString(w, "<p>"+data.(string)+"</p>")
+
+	return err
+}
+
+func TestWriteTemplate(t *testing.T) {
+	modTime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := httputil.WriteTemplate(rr, req, executor{}, "page", "hello", modTime, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	require.Equal(t, "<p>hello</p>", rr.Body.String())
+
+	// Not modified
+	rr = httptest.NewRecorder()
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	_, err = httputil.WriteTemplate(rr, req, executor{}, "page", "hello", modTime, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Empty(t, rr.Body.String())
+
+	// Failed rendering
+	rr = httptest.NewRecorder()
+	_, err = httputil.WriteTemplate(rr, req, executor{}, "missing", nil, modTime, time.Minute)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Empty(t, rr.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,98 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// StatusWriter is a response writer which records the status code and the number of bytes written, e.g. for logging
+// and metrics middlewares.
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// Status returns the status code written, 200 if the handler wrote the body only and 0 if it wrote nothing.
+func (w *StatusWriter) Status() int {
+	return w.status
+}
+
+// Bytes returns the number of body bytes written.
+func (w *StatusWriter) Bytes() int {
+	return w.bytes
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *StatusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *StatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does, e.g. to upgrade a connection to WebSocket. A hijacked
+// connection is recorded with 101 status, as the handler takes over the response.
+func (w *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+// Push implements http.Pusher if the underlying writer does.
+func (w *StatusWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying response writer.
+func (w *StatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// NewStatusWriter wraps a response writer. If it is a StatusWriter already, it is returned as is.
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	if sw, ok := w.(*StatusWriter); ok {
+		return sw
+	}
+
+	return &StatusWriter{ResponseWriter: w}
+}
@@ -0,0 +1,48 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/httputil"
+)
+
+func TestStatusWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	w := httputil.NewStatusWriter(rr)
+	require.Equal(t, 0, w.Status())
+	require.Same(t, w, httputil.NewStatusWriter(w))
+
+	// Implicit status
+	_, _ = w.Write([]byte("hello"))
+	_, _ = w.Write([]byte(", world"))
+	require.Equal(t, http.StatusOK, w.Status())
+	require.Equal(t, 12, w.Bytes())
+	require.Equal(t, "hello, world", rr.Body.String())
+
+	// Explicit status
+	rr = httptest.NewRecorder()
+	w = httputil.NewStatusWriter(rr)
+	w.WriteHeader(http.StatusTeapot)
+	w.Flush()
+	require.Equal(t, http.StatusTeapot, w.Status())
+	require.Equal(t, http.StatusTeapot, rr.Code)
+	require.True(t, rr.Flushed)
+	require.Same(t, rr, w.Unwrap())
+}
+
+func TestStatusWriterOptional(t *testing.T) {
+	// Not supported by the recorder
+	w := httputil.NewStatusWriter(httptest.NewRecorder())
+	_, _, err := w.Hijack()
+	require.ErrorIs(t, err, http.ErrNotSupported)
+	require.ErrorIs(t, w.Push("/style.css", nil), http.ErrNotSupported)
+	require.Equal(t, 0, w.Status())
+}
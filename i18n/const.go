@@ -0,0 +1,11 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package i18n
+
+const (
+	DftLocale = "en"     // default locale
+	DftParam  = "locale" // query parameter requesting a locale
+	DftPrefix = true     // whether a locale is taken from the URL path prefix
+)
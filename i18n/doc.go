@@ -0,0 +1,19 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package i18n resolves locales of requests and their fallback chains.
+//
+// A request locale is taken from the URL path prefix, e.g. /de-AT/news, the query parameter, e.g. ?locale=de-AT, or
+// the Accept-Language header, in this order, and falls back to the default locale. Only the supported locales are
+// resolved, a request for a more specific one resolves to its language if that is supported, e.g. de-CH to de.
+//
+// Content in a locale which is missing is served in the next locale of its fallback chain. A chain consists of the
+// locale, its configured fallbacks or, if there are none, its parents, and the default locale, e.g. de-AT, de, en:
+//
+//	i18n:
+//	  default: en
+//	  locales: [en, de, de-AT, fr]
+//	  fallbacks:
+//	    fr: [de]
+package i18n
@@ -0,0 +1,232 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"ampho.xyz/core/config"
+)
+
+type localeKey struct{}
+
+// WithLocale returns a copy of the context with the locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFrom returns the locale of a context or an empty string if it has none.
+func LocaleFrom(ctx context.Context) string {
+	l, _ := ctx.Value(localeKey{}).(string)
+
+	return l
+}
+
+// Canonical returns the canonical form of a locale, e.g. de-AT for de_at. It returns an empty string if the locale
+// is not well-formed.
+func Canonical(locale string) string {
+	t, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return ""
+	}
+
+	return t.String()
+}
+
+// Resolver resolves locales of requests.
+type Resolver struct {
+	dft       string
+	locales   []string
+	fallbacks map[string][]string
+	param     string
+	prefix    bool
+}
+
+// Default returns the default locale.
+func (r *Resolver) Default() string {
+	return r.dft
+}
+
+// Locales returns the supported locales.
+func (r *Resolver) Locales() []string {
+	return r.locales
+}
+
+// Match returns the supported locale matching a requested one or an empty string if there is none. A locale matches
+// itself and, if it is not supported, the closest of its parents.
+func (r *Resolver) Match(locale string) string {
+	for _, l := range parents(Canonical(locale)) {
+		if r.isSupported(l) {
+			return l
+		}
+	}
+
+	return ""
+}
+
+// Chain returns the fallback chain of a locale: the locale, its fallbacks and the default locale.
+func (r *Resolver) Chain(locale string) []string {
+	locale = Canonical(locale)
+	if locale == "" {
+		return []string{r.dft}
+	}
+
+	chain := []string{locale}
+	if fb, ok := r.fallbacks[locale]; ok {
+		chain = append(chain, fb...)
+	} else {
+		chain = parents(locale)
+	}
+	chain = append(chain, r.dft)
+
+	uniq := make([]string, 0, len(chain))
+	seen := make(map[string]bool)
+	for _, l := range chain {
+		if !seen[l] {
+			seen[l] = true
+			uniq = append(uniq, l)
+		}
+	}
+
+	return uniq
+}
+
+// Resolve returns the locale of a request taken from the query parameter or the Accept-Language header, or the
+// default locale if neither requests a supported one.
+func (r *Resolver) Resolve(req *http.Request) string {
+	if v := req.URL.Query().Get(r.param); v != "" {
+		if l := r.Match(v); l != "" {
+			return l
+		}
+	}
+
+	if v := req.Header.Get("Accept-Language"); v != "" {
+		tags, _, _ := language.ParseAcceptLanguage(v)
+		for _, t := range tags {
+			if l := r.Match(t.String()); l != "" {
+				return l
+			}
+		}
+	}
+
+	return r.dft
+}
+
+// Middleware resolves the locale of a request and stores it in the request context. If the first segment of the URL
+// path is a supported locale, it takes precedence and is stripped from the path, so /de/news is routed as /news.
+// Responses vary by the Accept-Language header.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		locale := ""
+
+		if r.prefix {
+			if l, rest := r.splitPath(req.URL.Path); l != "" {
+				locale = l
+				req = req.Clone(req.Context())
+				req.URL.Path, req.URL.RawPath = rest, ""
+			}
+		}
+		if locale == "" {
+			locale = r.Resolve(req)
+			w.Header().Add("Vary", "Accept-Language")
+		}
+
+		next.ServeHTTP(w, req.WithContext(WithLocale(req.Context(), locale)))
+	})
+}
+
+// splitPath returns the supported locale of the first segment of a URL path and the rest of the path. If the segment
+// is not a supported locale, it returns an empty locale.
+func (r *Resolver) splitPath(path string) (string, string) {
+	p := strings.TrimPrefix(path, "/")
+	seg, rest := p, "/"
+	if i := strings.IndexByte(p, '/'); i >= 0 {
+		seg, rest = p[:i], p[i:]
+	}
+
+	if l := Canonical(seg); l != "" && r.isSupported(l) {
+		return l, rest
+	}
+
+	return "", path
+}
+
+func (r *Resolver) isSupported(locale string) bool {
+	for _, l := range r.locales {
+		if l == locale {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parents returns a locale followed by its parents, e.g. de-Latn-AT, de-Latn, de.
+func parents(locale string) []string {
+	if locale == "" {
+		return nil
+	}
+
+	r := []string{locale}
+	for i := strings.LastIndexByte(locale, '-'); i > 0; i = strings.LastIndexByte(locale, '-') {
+		locale = locale[:i]
+		r = append(r, locale)
+	}
+
+	return r
+}
+
+// New creates a new locale resolver. The default locale is always supported.
+func New(cfg config.Config) (*Resolver, error) {
+	cfg.SetDefault("i18n.default", DftLocale)
+	cfg.SetDefault("i18n.param", DftParam)
+	cfg.SetDefault("i18n.prefix", DftPrefix)
+
+	r := &Resolver{
+		dft:       Canonical(cfg.GetString("i18n.default")),
+		fallbacks: make(map[string][]string),
+		param:     cfg.GetString("i18n.param"),
+		prefix:    cfg.GetBool("i18n.prefix"),
+	}
+	if r.dft == "" {
+		return nil, fmt.Errorf("invalid default locale %q", cfg.GetString("i18n.default"))
+	}
+
+	r.locales = []string{r.dft}
+	for _, v := range cfg.GetStringSlice("i18n.locales") {
+		l := Canonical(v)
+		if l == "" {
+			return nil, fmt.Errorf("invalid locale %q", v)
+		}
+		if !r.isSupported(l) {
+			r.locales = append(r.locales, l)
+		}
+	}
+
+	// Keys are lower-cased by the configuration, so they are canonicalized as well
+	for k, v := range cfg.GetStringMapStringSlice("i18n.fallbacks") {
+		l := Canonical(k)
+		if l == "" {
+			return nil, fmt.Errorf("invalid locale %q", k)
+		}
+
+		chain := make([]string, 0, len(v))
+		for _, fb := range v {
+			c := Canonical(fb)
+			if c == "" {
+				return nil, fmt.Errorf("invalid fallback locale %q of %s", fb, l)
+			}
+			chain = append(chain, c)
+		}
+		r.fallbacks[l] = chain
+	}
+
+	return r, nil
+}
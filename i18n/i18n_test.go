@@ -0,0 +1,106 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package i18n_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/i18n"
+)
+
+func newResolver(t *testing.T) *i18n.Resolver {
+	cfg := config.NewTesting("i18n")
+	cfg.Set("i18n.locales", []string{"de", "de_AT", "fr", "en"})
+	cfg.Set("i18n.fallbacks", map[string]interface{}{"fr": []string{"de"}})
+
+	r, err := i18n.New(cfg)
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestCanonical(t *testing.T) {
+	require.Equal(t, "de-AT", i18n.Canonical("de_at"))
+	require.Equal(t, "en", i18n.Canonical("EN"))
+	require.Equal(t, "sr-Latn-RS", i18n.Canonical("sr-latn-rs"))
+	require.Equal(t, "", i18n.Canonical("not a locale"))
+}
+
+func TestMatchChain(t *testing.T) {
+	r := newResolver(t)
+	require.Equal(t, "en", r.Default())
+	require.Equal(t, []string{"en", "de", "de-AT", "fr"}, r.Locales())
+
+	require.Equal(t, "de-AT", r.Match("de-at"))
+	require.Equal(t, "de", r.Match("de-CH"))
+	require.Equal(t, "", r.Match("it"))
+
+	require.Equal(t, []string{"de-AT", "de", "en"}, r.Chain("de-AT"))
+	require.Equal(t, []string{"fr", "de", "en"}, r.Chain("fr"))
+	require.Equal(t, []string{"en"}, r.Chain("en"))
+	require.Equal(t, []string{"en"}, r.Chain(""))
+}
+
+func TestResolve(t *testing.T) {
+	r := newResolver(t)
+
+	tt := []struct {
+		url, accept, want string
+	}{
+		{"/", "", "en"},
+		{"/", "it, de-CH;q=0.8, fr;q=0.9", "fr"},
+		{"/", "it", "en"},
+		{"/?locale=de-AT", "fr", "de-AT"},
+		{"/?locale=it", "de", "de"},
+	}
+
+	for _, tc := range tt {
+		req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept-Language", tc.accept)
+		}
+		require.Equal(t, tc.want, r.Resolve(req), tc)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newResolver(t)
+
+	var locale, path string
+	h := r.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		locale, path = i18n.LocaleFrom(req.Context()), req.URL.Path
+	}))
+
+	do := func(url, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Language", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/de-at/news/1", "fr")
+	require.Equal(t, "de-AT", locale)
+	require.Equal(t, "/news/1", path)
+	require.Empty(t, rec.Header().Get("Vary"))
+
+	do("/fr", "")
+	require.Equal(t, "fr", locale)
+	require.Equal(t, "/", path)
+
+	rec = do("/news/1", "fr")
+	require.Equal(t, "fr", locale)
+	require.Equal(t, "/news/1", path)
+	require.Equal(t, "Accept-Language", rec.Header().Get("Vary"))
+
+	do("/it/news", "")
+	require.Equal(t, "en", locale)
+	require.Equal(t, "/it/news", path)
+}
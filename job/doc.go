@@ -0,0 +1,6 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package job provides background jobs bound to a service lifecycle.
+package job
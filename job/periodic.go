@@ -0,0 +1,105 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/service"
+)
+
+// Periodic is a job which calls a function at a fixed interval.
+type Periodic struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+
+	mu     sync.Mutex
+	logger *logger.Logger // passed to the function in its context, see logger.FromContext
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts the job in a separate goroutine. The function is called immediately and then at every interval.
+func (p *Periodic) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx := context.Background()
+	if p.logger != nil {
+		ctx = logger.WithContext(ctx, p.logger)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go p.loop(ctx, p.done)
+}
+
+// Stop stops the job and waits until the current call, if any, returns.
+func (p *Periodic) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	<-p.done
+
+	p.cancel = nil
+	p.done = nil
+}
+
+// Attach binds the job to a service lifecycle, so it starts before the service and stops after it. The job logs with
+// the service logger.
+func (p *Periodic) Attach(svc service.Service) {
+	svc.BeforeStart(func(context.Context, service.Service) error {
+		p.mu.Lock()
+		p.logger = svc.Logger()
+		p.mu.Unlock()
+
+		p.Start()
+		return nil
+	})
+
+	svc.AfterStop(func(context.Context, service.Service) error {
+		p.Stop()
+		return nil
+	})
+}
+
+func (p *Periodic) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.fn(ctx); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).Error("job failed", "job", p.name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewPeriodic creates a new periodic job.
+func NewPeriodic(name string, interval time.Duration, fn func(ctx context.Context) error) *Periodic {
+	return &Periodic{name: name, interval: interval, fn: fn}
+}
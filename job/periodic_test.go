@@ -0,0 +1,35 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package job_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/job"
+)
+
+func TestPeriodic(t *testing.T) {
+	var calls int32
+
+	p := job.NewPeriodic("test", time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	p.Start()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) >= 3
+	}, time.Second, time.Millisecond)
+	p.Stop()
+
+	n := atomic.LoadInt32(&calls)
+	time.Sleep(time.Millisecond * 10)
+	require.Equal(t, n, atomic.LoadInt32(&calls), "job must not run after stop")
+}
@@ -0,0 +1,15 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package lock
+
+import "time"
+
+const (
+	DftTTL    = time.Minute * 5 // lease time if no ttl is requested
+	DftMaxTTL = time.Hour       // maximum lease time
+)
+
+// DftAdminRoles are the roles permitted to force-release locks of other users.
+var DftAdminRoles = []string{"admin"}
@@ -0,0 +1,19 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package lock provides lease-based locks of entities being edited, so an editor opening an entity can be told
+// somebody else is editing it.
+//
+// A lock is acquired by a user for a limited time and must be prolonged by heartbeats while the user keeps editing.
+// A lock which is neither prolonged nor released expires, so a closed browser tab does not block an entity forever.
+// Administrators may force-release locks of other users.
+//
+// Storages refuse writes of users who do not hold the lock of an entity, see Locks.CheckTx:
+//
+//	lock:
+//	  ttl: 5m            # default lease time
+//	  maxTTL: 1h         # maximum lease time a user may request
+//	  required: false    # whether a write requires the lock, otherwise only the locks of others are refused
+//	  adminRoles: [admin]
+package lock
@@ -0,0 +1,269 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/security"
+)
+
+const lockColumns = "entity_uuid::text AS entity_uuid, owner, acquired_at, expires_at"
+
+var (
+	// ErrNotFound is returned when an entity is not locked.
+	ErrNotFound = errors.New("entity is not locked")
+
+	// ErrLocked is returned when an entity is locked by another user.
+	ErrLocked = errors.New("entity is locked by another user")
+
+	// ErrNotHeld is returned when a user does not hold the lock of an entity.
+	ErrNotHeld = errors.New("lock is not held")
+
+	// ErrInvalidTTL is returned when a requested lease time is not positive or exceeds the maximum.
+	ErrInvalidTTL = errors.New("invalid lock ttl")
+)
+
+// LockedError is returned when an entity is locked by another user. It carries the lock, so the user can be told
+// who is editing the entity and until when.
+type LockedError struct {
+	Lock *Lock
+}
+
+// Error implements error.
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v: %s until %s", ErrLocked, e.Lock.Owner, e.Lock.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// Is reports whether the target is ErrLocked.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is a lease of an entity by a user.
+type Lock struct {
+	EntityUUID string    `db:"entity_uuid" json:"entity"`
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `db:"acquired_at" json:"acquiredAt"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expiresAt"`
+}
+
+// Locks is a locks storage.
+type Locks struct {
+	db         *database.Database
+	ttl        time.Duration
+	maxTTL     time.Duration
+	required   bool
+	adminRoles []string
+}
+
+// TTL returns the lease time to use for a requested one. Zero means the default lease time.
+func (l *Locks) TTL(ttl time.Duration) (time.Duration, error) {
+	if ttl == 0 {
+		return l.ttl, nil
+	}
+	if ttl < 0 || ttl > l.maxTTL {
+		return 0, ErrInvalidTTL
+	}
+
+	return ttl, nil
+}
+
+// IsAdmin reports whether a user may force-release locks of other users.
+func (l *Locks) IsAdmin(p *security.Principal) bool {
+	return p.HasRole(l.adminRoles...)
+}
+
+// Get returns the active lock of an entity.
+func (l *Locks) Get(ctx context.Context, uuid string) (*Lock, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	lock := &Lock{}
+
+	err := l.getRW(ctx, lock, "SELECT "+lockColumns+" FROM locks WHERE entity_uuid = $1 AND expires_at > now()", uuid)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Acquire locks an entity by a user for the lease time, see TTL. Acquiring a lock the user already holds prolongs
+// it. If another user holds the lock, it returns LockedError.
+func (l *Locks) Acquire(ctx context.Context, uuid, owner string, ttl time.Duration) (*Lock, error) {
+	if !database.IsUUID(uuid) {
+		return nil, fmt.Errorf("invalid entity UUID %q", uuid)
+	}
+	if owner == "" {
+		return nil, ErrNotHeld
+	}
+
+	ttl, err := l.TTL(ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+
+	err = l.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		err := pgxscan.Get(ctx, tx, lock, "INSERT INTO locks (entity_uuid, owner, expires_at) "+
+			"VALUES ($1, $2, now() + $3 * interval '1 millisecond') ON CONFLICT (entity_uuid) DO UPDATE "+
+			"SET owner = excluded.owner, expires_at = excluded.expires_at, "+
+			"acquired_at = CASE WHEN locks.owner = excluded.owner THEN locks.acquired_at ELSE now() END "+
+			"WHERE locks.owner = excluded.owner OR locks.expires_at <= now() RETURNING "+lockColumns,
+			uuid, owner, ttl.Milliseconds())
+		if !pgxscan.NotFound(err) {
+			return err
+		}
+
+		// Held by another user
+		held := &Lock{}
+		err = pgxscan.Get(ctx, tx, held, "SELECT "+lockColumns+" FROM locks WHERE entity_uuid = $1", uuid)
+		if err != nil {
+			return err
+		}
+
+		return &LockedError{held}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Heartbeat prolongs the lock of an entity held by a user for the lease time, see TTL. If the user does not hold the
+// lock or it has expired, it returns ErrNotHeld.
+func (l *Locks) Heartbeat(ctx context.Context, uuid, owner string, ttl time.Duration) (*Lock, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotHeld
+	}
+
+	ttl, err := l.TTL(ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+
+	err = l.getRW(ctx, lock, "UPDATE locks SET expires_at = now() + $3 * interval '1 millisecond' "+
+		"WHERE entity_uuid = $1 AND owner = $2 AND expires_at > now() RETURNING "+lockColumns,
+		uuid, owner, ttl.Milliseconds())
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotHeld
+	} else if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Release releases the lock of an entity held by a user. If the user does not hold the lock, it returns ErrNotHeld.
+func (l *Locks) Release(ctx context.Context, uuid, owner string) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotHeld
+	}
+
+	tag, err := l.db.Exec(ctx, "DELETE FROM locks WHERE entity_uuid = $1 AND owner = $2 AND expires_at > now()",
+		uuid, owner)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+// ForceRelease releases the lock of an entity regardless of its owner. If the entity is not locked, it returns
+// ErrNotFound.
+func (l *Locks) ForceRelease(ctx context.Context, uuid string) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	tag, err := l.db.Exec(ctx, "DELETE FROM locks WHERE entity_uuid = $1 AND expires_at > now()", uuid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Check checks a user may write an entity. If another user holds the lock, it returns LockedError. If locks are
+// required and the user does not hold the lock, it returns ErrNotHeld.
+//
+// The lock may change right after the check, so storages should check it within the transaction writing the entity,
+// see CheckTx.
+func (l *Locks) Check(ctx context.Context, uuid, owner string) error {
+	return l.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return l.CheckTx(ctx, tx, uuid, owner)
+	})
+}
+
+// CheckTx checks a user may write an entity within a transaction, see Check. The lock is kept from being acquired
+// by another user or released until the transaction ends.
+func (l *Locks) CheckTx(ctx context.Context, tx pgx.Tx, uuid, owner string) error {
+	lock := &Lock{}
+	err := pgx.ErrNoRows
+	if database.IsUUID(uuid) {
+		err = pgxscan.Get(ctx, tx, lock, "SELECT "+lockColumns+" FROM locks WHERE entity_uuid = $1 AND "+
+			"expires_at > now() FOR SHARE", uuid)
+	}
+	if pgxscan.NotFound(err) {
+		if l.required {
+			return ErrNotHeld
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if lock.Owner != owner {
+		return &LockedError{lock}
+	}
+
+	return nil
+}
+
+// getRW scans a single row into a struct using an RW replica, so recent changes of locks are never missed due to
+// replication lag.
+func (l *Locks) getRW(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return l.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return pgxscan.Get(ctx, tx, dest, sql, args...)
+	})
+}
+
+// New creates a new locks storage.
+func New(cfg config.Config, db *database.Database) *Locks {
+	cfg.SetDefault("lock.ttl", DftTTL)
+	cfg.SetDefault("lock.maxTTL", DftMaxTTL)
+	cfg.SetDefault("lock.required", false)
+	cfg.SetDefault("lock.adminRoles", DftAdminRoles)
+
+	return &Locks{
+		db:         db,
+		ttl:        cfg.GetDuration("lock.ttl"),
+		maxTTL:     cfg.GetDuration("lock.maxTTL"),
+		required:   cfg.GetBool("lock.required"),
+		adminRoles: cfg.GetStringSlice("lock.adminRoles"),
+	}
+}
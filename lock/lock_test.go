@@ -0,0 +1,114 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/lock"
+	"ampho.xyz/core/security"
+)
+
+func TestTTL(t *testing.T) {
+	cfg := config.NewTesting("lock")
+	cfg.Set("lock.maxTTL", "30m")
+	l := lock.New(cfg, nil)
+
+	ttl, err := l.TTL(0)
+	require.NoError(t, err)
+	require.Equal(t, lock.DftTTL, ttl)
+
+	ttl, err = l.TTL(time.Minute * 30)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute*30, ttl)
+
+	_, err = l.TTL(time.Minute * 31)
+	require.ErrorIs(t, err, lock.ErrInvalidTTL)
+	_, err = l.TTL(-time.Minute)
+	require.ErrorIs(t, err, lock.ErrInvalidTTL)
+}
+
+func TestIsAdmin(t *testing.T) {
+	l := lock.New(config.NewTesting("lock"), nil)
+	require.True(t, l.IsAdmin(&security.Principal{Subject: "ann", Roles: []string{"editor", "admin"}}))
+	require.False(t, l.IsAdmin(&security.Principal{Subject: "bob", Roles: []string{"editor"}}))
+	require.False(t, l.IsAdmin(nil))
+
+	cfg := config.NewTesting("lock")
+	cfg.Set("lock.adminRoles", []string{"chief"})
+	l = lock.New(cfg, nil)
+	require.True(t, l.IsAdmin(&security.Principal{Subject: "ann", Roles: []string{"chief"}}))
+	require.False(t, l.IsAdmin(&security.Principal{Subject: "bob", Roles: []string{"admin"}}))
+}
+
+func TestLockedError(t *testing.T) {
+	exp := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	var err error = &lock.LockedError{Lock: &lock.Lock{Owner: "ann", ExpiresAt: exp}}
+
+	require.ErrorIs(t, err, lock.ErrLocked)
+	require.Equal(t, "entity is locked by another user: ann until 2021-05-01T12:00:00Z", err.Error())
+
+	var locked *lock.LockedError
+	require.True(t, errors.As(err, &locked))
+	require.Equal(t, "ann", locked.Lock.Owner)
+}
+
+func TestLease(t *testing.T) {
+	cfg := config.NewTesting("lock")
+	cfg.Set("lock.required", true)
+	l := lock.New(cfg, databasetest.New(t))
+	ctx := context.Background()
+	uuid := "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"
+
+	require.ErrorIs(t, l.Check(ctx, uuid, "ann"), lock.ErrNotHeld)
+	require.ErrorIs(t, l.Check(ctx, "malformed", "ann"), lock.ErrNotHeld)
+
+	acquired, err := l.Acquire(ctx, uuid, "ann", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "ann", acquired.Owner)
+	require.NoError(t, l.Check(ctx, uuid, "ann"))
+
+	// Held by another user
+	_, err = l.Acquire(ctx, uuid, "bob", 0)
+	var locked *lock.LockedError
+	require.ErrorAs(t, err, &locked)
+	require.Equal(t, "ann", locked.Lock.Owner)
+	require.ErrorIs(t, l.Check(ctx, uuid, "bob"), lock.ErrLocked)
+	_, err = l.Heartbeat(ctx, uuid, "bob", 0)
+	require.ErrorIs(t, err, lock.ErrNotHeld)
+
+	// Prolonged by the owner
+	lk, err := l.Heartbeat(ctx, uuid, "ann", time.Hour)
+	require.NoError(t, err)
+	require.True(t, lk.ExpiresAt.After(acquired.ExpiresAt))
+	require.Equal(t, acquired.AcquiredAt, lk.AcquiredAt)
+
+	// Expired, so the lock is free
+	_, err = l.Heartbeat(ctx, uuid, "ann", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 20)
+
+	_, err = l.Get(ctx, uuid)
+	require.ErrorIs(t, err, lock.ErrNotFound)
+	_, err = l.Heartbeat(ctx, uuid, "ann", 0)
+	require.ErrorIs(t, err, lock.ErrNotHeld)
+	require.ErrorIs(t, l.Release(ctx, uuid, "ann"), lock.ErrNotHeld)
+
+	lk, err = l.Acquire(ctx, uuid, "bob", 0)
+	require.NoError(t, err)
+	require.Equal(t, "bob", lk.Owner)
+	require.True(t, lk.AcquiredAt.After(acquired.AcquiredAt))
+
+	require.ErrorIs(t, l.Release(ctx, uuid, "ann"), lock.ErrNotHeld)
+	require.NoError(t, l.ForceRelease(ctx, uuid))
+	require.ErrorIs(t, l.ForceRelease(ctx, uuid), lock.ErrNotFound)
+}
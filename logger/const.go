@@ -0,0 +1,11 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package logger
+
+const (
+	DftLevel  = "info"   // minimum level of records written
+	DftFormat = "logfmt" // record format: json or logfmt
+	DftOutput = "stderr" // stderr, stdout or a file path
+)
@@ -0,0 +1,6 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package logger provides a structured leveled logger writing JSON or logfmt records.
+package logger
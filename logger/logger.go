@@ -0,0 +1,301 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"ampho.xyz/core/config"
+)
+
+// Level is a severity of a record.
+type Level int8
+
+// Levels.
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level name.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel returns a level by its name.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Format is a record format.
+type Format string
+
+// Formats.
+const (
+	FormatJSON   Format = "json"
+	FormatLogfmt Format = "logfmt"
+)
+
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Logger writes structured records: a time, a level, a message and fields given as key-value pairs. It is safe for
+// concurrent use.
+type Logger struct {
+	out    *output
+	level  Level
+	format Format
+	fields []interface{}
+}
+
+// Enabled reports whether records of a level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// With returns a logger adding fields to each record.
+func (l *Logger) With(keyValues ...interface{}) *Logger {
+	c := *l
+	c.fields = append(append(make([]interface{}, 0, len(l.fields)+len(keyValues)), l.fields...), keyValues...)
+
+	return &c
+}
+
+// Debug writes a debug record.
+func (l *Logger) Debug(msg string, keyValues ...interface{}) {
+	l.Log(LevelDebug, msg, keyValues...)
+}
+
+// Info writes an info record.
+func (l *Logger) Info(msg string, keyValues ...interface{}) {
+	l.Log(LevelInfo, msg, keyValues...)
+}
+
+// Warn writes a warning record.
+func (l *Logger) Warn(msg string, keyValues ...interface{}) {
+	l.Log(LevelWarn, msg, keyValues...)
+}
+
+// Error writes an error record.
+func (l *Logger) Error(msg string, keyValues ...interface{}) {
+	l.Log(LevelError, msg, keyValues...)
+}
+
+// Log writes a record of a level if it is enabled. Fields are key-value pairs, keys are expected to be strings.
+func (l *Logger) Log(level Level, msg string, keyValues ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	kv := make([]interface{}, 0, 6+len(l.fields)+len(keyValues))
+	kv = append(kv, "time", time.Now(), "level", level.String(), "msg", msg)
+	kv = append(append(kv, l.fields...), keyValues...)
+	if len(kv)%2 != 0 {
+		kv = append(kv, nil)
+	}
+
+	buf := &bytes.Buffer{}
+	if l.format == FormatJSON {
+		encodeJSON(buf, kv)
+	} else {
+		encodeLogfmt(buf, kv)
+	}
+	buf.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = l.out.w.Write(buf.Bytes())
+}
+
+// StdLogger returns a standard library logger writing records of a level through the logger, e.g. for
+// http.Server.ErrorLog.
+func (l *Logger) StdLogger(level Level) *log.Logger {
+	return log.New(&stdWriter{l, level}, "", 0)
+}
+
+type stdWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	w.l.Log(w.level, strings.TrimSuffix(string(p), "\n"))
+
+	return len(p), nil
+}
+
+// value converts a field value to a form both encoders handle.
+func value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return t.String()
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+
+	return v
+}
+
+func encodeJSON(buf *bytes.Buffer, kv []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, _ := json.Marshal(fmt.Sprint(kv[i]))
+		buf.Write(k)
+		buf.WriteByte(':')
+
+		v, err := json.Marshal(value(kv[i+1]))
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(kv[i+1]))
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+}
+
+func encodeLogfmt(buf *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(logfmtKey(fmt.Sprint(kv[i])))
+		buf.WriteByte('=')
+
+		v := value(kv[i+1])
+		if v == nil {
+			continue
+		}
+		s := fmt.Sprint(v)
+		if needsQuotes(s) {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+}
+
+// logfmtKey replaces characters not allowed in logfmt keys.
+func logfmtKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar {
+			return '_'
+		}
+		return r
+	}, k)
+}
+
+func needsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type loggerKey struct{}
+
+// WithContext returns a copy of the context with the logger.
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of a context, e.g. a request logger set by the service, or the default one if the
+// context has none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+
+	return dft
+}
+
+var dft = NewWriter(os.Stderr, LevelInfo, FormatLogfmt)
+
+// NewWriter creates a new logger writing records of a level and above to a writer.
+func NewWriter(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w}, level: level, format: format}
+}
+
+// New creates a new logger configured by log.level, log.format and log.output settings.
+func New(cfg config.Config) (*Logger, error) {
+	cfg.SetDefault("log.level", DftLevel)
+	cfg.SetDefault("log.format", DftFormat)
+	cfg.SetDefault("log.output", DftOutput)
+
+	level, err := ParseLevel(cfg.GetString("log.level"))
+	if err != nil {
+		return nil, err
+	}
+
+	format := Format(strings.ToLower(cfg.GetString("log.format")))
+	if format != FormatJSON && format != FormatLogfmt {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	var w io.Writer
+	switch out := cfg.GetString("log.output"); out {
+	case "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+
+	return NewWriter(w, level, format), nil
+}
@@ -0,0 +1,116 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/logger"
+)
+
+func TestLevel(t *testing.T) {
+	for _, l := range []logger.Level{logger.LevelDebug, logger.LevelInfo, logger.LevelWarn, logger.LevelError} {
+		p, err := logger.ParseLevel(strings.ToUpper(l.String()))
+		require.NoError(t, err)
+		require.Equal(t, l, p)
+	}
+
+	_, err := logger.ParseLevel("verbose")
+	require.Error(t, err)
+}
+
+func TestJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := logger.NewWriter(buf, logger.LevelInfo, logger.FormatJSON).With("service", "hello")
+
+	l.Debug("hidden")
+	l.Info("served", "status", 200, "duration", 1500*time.Millisecond, "error", errors.New("oops"), "odd")
+	require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+
+	rec := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	require.Equal(t, "info", rec["level"])
+	require.Equal(t, "served", rec["msg"])
+	require.Equal(t, "hello", rec["service"])
+	require.Equal(t, float64(200), rec["status"])
+	require.Equal(t, "1.5s", rec["duration"])
+	require.Equal(t, "oops", rec["error"])
+	require.Nil(t, rec["odd"])
+	require.Contains(t, rec, "odd")
+
+	ts, err := time.Parse(time.RFC3339Nano, rec["time"].(string))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), ts, time.Minute)
+
+	// Keys are kept in order
+	require.True(t, strings.HasPrefix(buf.String(), `{"time":`))
+	require.Less(t, strings.Index(buf.String(), `"service"`), strings.Index(buf.String(), `"status"`))
+}
+
+func TestLogfmt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := logger.NewWriter(buf, logger.LevelDebug, logger.FormatLogfmt)
+
+	l.Debug("request served", "uri", "/a?b=c", "status", 404, "empty", "", "nil", nil, "quote", `say "hi"`)
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	require.Regexp(t, `^time=\S+ level=debug `, line)
+	require.True(t, strings.HasSuffix(line, `msg="request served" uri="/a?b=c" status=404 empty="" nil= `+
+		`quote="say \"hi\""`), line)
+}
+
+func TestStdLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.NewWriter(buf, logger.LevelInfo, logger.FormatLogfmt).StdLogger(logger.LevelWarn).Printf("tls: %s", "bad")
+
+	require.Contains(t, buf.String(), `level=warn msg="tls: bad"`)
+}
+
+func TestContext(t *testing.T) {
+	require.NotNil(t, logger.FromContext(context.Background()))
+
+	l := logger.NewWriter(ioutil.Discard, logger.LevelInfo, logger.FormatJSON)
+	require.Same(t, l, logger.FromContext(logger.WithContext(context.Background(), l)))
+}
+
+func TestNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	cfg := config.NewTesting("hello")
+	cfg.Set("log.level", "warn")
+	cfg.Set("log.format", "json")
+	cfg.Set("log.output", path)
+
+	l, err := logger.New(cfg)
+	require.NoError(t, err)
+	require.False(t, l.Enabled(logger.LevelInfo))
+	require.True(t, l.Enabled(logger.LevelWarn))
+
+	l.Warn("disk is almost full")
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"msg":"disk is almost full"`)
+
+	cfg.Set("log.format", "xml")
+	_, err = logger.New(cfg)
+	require.Error(t, err)
+
+	cfg.Set("log.format", "json")
+	cfg.Set("log.level", "loud")
+	_, err = logger.New(cfg)
+	require.Error(t, err)
+}
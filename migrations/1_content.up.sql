@@ -0,0 +1,31 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE content_items
+(
+    id           bigserial PRIMARY KEY,
+    uuid         uuid        NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now(),
+    deleted_at   timestamptz,
+    type         text        NOT NULL,
+    status       text        NOT NULL DEFAULT 'draft',
+    revision     integer     NOT NULL DEFAULT 1,
+    data         jsonb       NOT NULL DEFAULT '{}',
+    publish_at   timestamptz,
+    expire_at    timestamptz,
+    published_at timestamptz
+);
+
+CREATE INDEX content_items_type_idx ON content_items (type);
+CREATE INDEX content_items_publish_at_idx ON content_items (publish_at) WHERE status = 'scheduled';
+CREATE INDEX content_items_expire_at_idx ON content_items (expire_at) WHERE status IN ('scheduled', 'published');
+
+CREATE TABLE content_revisions
+(
+    id         bigserial PRIMARY KEY,
+    item_id    bigint      NOT NULL REFERENCES content_items (id) ON DELETE CASCADE,
+    number     integer     NOT NULL,
+    data       jsonb       NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (item_id, number)
+);
@@ -0,0 +1,2 @@
+DROP INDEX content_items_data_idx;
+DROP TABLE content_types;
@@ -0,0 +1,14 @@
+CREATE TABLE content_types
+(
+    id          bigserial PRIMARY KEY,
+    uuid        uuid        NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    deleted_at  timestamptz,
+    name        text        NOT NULL UNIQUE,
+    title       text        NOT NULL DEFAULT '',
+    description text        NOT NULL DEFAULT '',
+    fields      jsonb       NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX content_items_data_idx ON content_items USING gin (data jsonb_path_ops);
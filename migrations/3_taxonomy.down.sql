@@ -0,0 +1,2 @@
+DROP TABLE taxonomy_term_entities;
+DROP TABLE taxonomy_terms;
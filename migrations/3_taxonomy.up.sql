@@ -0,0 +1,27 @@
+CREATE EXTENSION IF NOT EXISTS ltree;
+
+CREATE TABLE taxonomy_terms
+(
+    id         bigserial PRIMARY KEY,
+    uuid       uuid        NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    deleted_at timestamptz,
+    vocabulary text        NOT NULL,
+    name       text        NOT NULL,
+    parent_id  bigint REFERENCES taxonomy_terms (id) ON DELETE CASCADE,
+    path       ltree       NOT NULL
+);
+
+CREATE INDEX taxonomy_terms_path_idx ON taxonomy_terms USING gist (path);
+CREATE UNIQUE INDEX taxonomy_terms_name_idx ON taxonomy_terms (vocabulary, (COALESCE(parent_id, 0)), (lower(name)));
+
+CREATE TABLE taxonomy_term_entities
+(
+    term_id     bigint      NOT NULL REFERENCES taxonomy_terms (id) ON DELETE CASCADE,
+    entity_uuid uuid        NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (term_id, entity_uuid)
+);
+
+CREATE INDEX taxonomy_term_entities_entity_idx ON taxonomy_term_entities (entity_uuid);
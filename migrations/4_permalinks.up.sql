@@ -0,0 +1,22 @@
+CREATE TABLE permalinks
+(
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    path        text        NOT NULL UNIQUE,
+    entity_uuid uuid        NOT NULL UNIQUE
+);
+
+CREATE TABLE redirects
+(
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    source      text        NOT NULL UNIQUE,
+    target      text        NOT NULL,
+    status      integer     NOT NULL DEFAULT 301,
+    entity_uuid uuid
+);
+
+CREATE INDEX redirects_target_idx ON redirects (target);
+CREATE INDEX redirects_entity_uuid_idx ON redirects (entity_uuid);
@@ -0,0 +1,13 @@
+CREATE TABLE relations
+(
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    source_uuid uuid        NOT NULL,
+    target_uuid uuid        NOT NULL,
+    name        text        NOT NULL,
+    position    integer     NOT NULL DEFAULT 0,
+    on_delete   text        NOT NULL DEFAULT 'restrict',
+    UNIQUE (source_uuid, name, target_uuid)
+);
+
+CREATE INDEX relations_target_uuid_idx ON relations (target_uuid);
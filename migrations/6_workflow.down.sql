@@ -0,0 +1,2 @@
+DROP TABLE workflow_transitions;
+DROP TABLE workflow_states;
@@ -0,0 +1,23 @@
+CREATE TABLE workflow_states
+(
+    entity_uuid uuid        NOT NULL,
+    workflow    text        NOT NULL,
+    state       text        NOT NULL,
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (entity_uuid, workflow)
+);
+
+CREATE TABLE workflow_transitions
+(
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    entity_uuid uuid        NOT NULL,
+    workflow    text        NOT NULL,
+    transition  text        NOT NULL,
+    from_state  text        NOT NULL,
+    to_state    text        NOT NULL,
+    actor       text        NOT NULL DEFAULT '',
+    comment     text        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX workflow_transitions_entity_uuid_idx ON workflow_transitions (entity_uuid, id);
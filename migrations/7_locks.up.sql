@@ -0,0 +1,7 @@
+CREATE TABLE locks
+(
+    entity_uuid uuid PRIMARY KEY,
+    owner       text        NOT NULL,
+    acquired_at timestamptz NOT NULL DEFAULT now(),
+    expires_at  timestamptz NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE content_variants;
@@ -0,0 +1,10 @@
+CREATE TABLE content_variants
+(
+    id         bigserial PRIMARY KEY,
+    item_uuid  uuid        NOT NULL REFERENCES content_items (uuid) ON DELETE CASCADE,
+    locale     text        NOT NULL,
+    data       jsonb       NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (item_uuid, locale)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE ratelimits
+(
+    key        text PRIMARY KEY,
+    tokens     double precision NOT NULL DEFAULT 0,
+    count      bigint           NOT NULL DEFAULT 0,
+    prev_count bigint           NOT NULL DEFAULT 0,
+    start_at   timestamptz,
+    expires_at timestamptz      NOT NULL
+);
+
+CREATE INDEX ratelimits_expires_at_idx ON ratelimits (expires_at);
@@ -0,0 +1,10 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package migrations provides database schema migrations of the core packages.
+//
+// All migrations are kept in a single source, so they are applied in a deterministic order:
+//
+//	db.Migrate(migrations.Source(), migration.Up, 0)
+package migrations
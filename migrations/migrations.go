@@ -0,0 +1,19 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package migrations
+
+import (
+	"embed"
+
+	"github.com/Boostport/migration"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Source returns a migration source containing all schema migrations.
+func Source() migration.Source {
+	return migration.EmbedMigrationSource{EmbedFS: files}
+}
@@ -0,0 +1,9 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package permalink provides human-readable URL paths of entities which survive renames.
+//
+// Every entity has at most one path. When the path changes, a permanent redirect from the old path to the new one is
+// created automatically. Redirects are served for paths no route matches, see Permalinks.Mount.
+package permalink
@@ -0,0 +1,75 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package permalink
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+)
+
+// Mount serves redirects of a router: its not found handler is wrapped with the redirect middleware, so redirect
+// rules are looked up for requests no route matches only. Paths served by Handler redirect as well.
+func (p *Permalinks) Mount(r *mux.Router) {
+	notFound := r.NotFoundHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	r.NotFoundHandler = p.Middleware(notFound)
+}
+
+// Middleware serves redirect rules for GET and HEAD requests and passes any other requests to the next handler. Since
+// it looks up a redirect for every request, it is intended to wrap not found handlers, see Mount.
+func (p *Permalinks) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rd, err := p.Redirect(r.Context(), r.URL.Path)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				logger.FromContext(r.Context()).Error("failed to look up redirect", "path", r.URL.Path, "error", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target := rd.Target
+		if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+			target += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, target, rd.Status)
+	})
+}
+
+// Handler returns a handler which resolves a request path to an entity UUID and calls fn with it. Requests to
+// unknown paths are redirected if there is a redirect rule and get 404 otherwise.
+func (p *Permalinks) Handler(fn func(w http.ResponseWriter, r *http.Request, entityUUID string)) http.Handler {
+	notFound := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = httputil.WriteStatus(w, http.StatusNotFound)
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uuid, err := p.Resolve(r.Context(), r.URL.Path)
+		if errors.Is(err, ErrNotFound) {
+			notFound.ServeHTTP(w, r)
+			return
+		} else if err != nil {
+			logger.FromContext(r.Context()).Error("failed to resolve permalink", "path", r.URL.Path, "error", err)
+			_, _ = httputil.WriteStatus(w, http.StatusInternalServerError)
+			return
+		}
+
+		fn(w, r, uuid)
+	})
+}
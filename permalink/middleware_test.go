@@ -0,0 +1,36 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package permalink_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/permalink"
+)
+
+func TestMountMatchedRoutes(t *testing.T) {
+	// Without a database any redirect lookup panics, so matched routes must not look redirects up
+	r := mux.NewRouter()
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	permalink.New(nil).Mount(r)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/hello", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Other methods are not redirected
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/unknown", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
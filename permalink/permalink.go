@@ -0,0 +1,332 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package permalink
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/slug"
+)
+
+var (
+	// ErrNotFound is returned when a path or a redirect does not exist.
+	ErrNotFound = errors.New("permalink not found")
+
+	// ErrExists is returned when a path already belongs to another entity.
+	ErrExists = errors.New("path is already in use")
+
+	// ErrBadStatus is returned on attempt to create a redirect with a non-redirect status code.
+	ErrBadStatus = errors.New("bad redirect status code")
+)
+
+// Redirect is a redirect rule.
+type Redirect struct {
+	ID         uint
+	CreatedAt  pgtype.Timestamptz
+	UpdatedAt  pgtype.Timestamptz
+	Source     string
+	Target     string
+	Status     int
+	EntityUUID pgtype.UUID // set for redirects created on path change
+}
+
+// Permalinks maps URL paths to entities and keeps redirects from their old paths.
+type Permalinks struct {
+	db *database.Database
+}
+
+// Path returns the path of an entity.
+func (p *Permalinks) Path(ctx context.Context, entityUUID string) (string, error) {
+	if !database.IsUUID(entityUUID) {
+		return "", ErrNotFound
+	}
+
+	var r string
+
+	err := p.db.QueryRow(ctx, "SELECT path FROM permalinks WHERE entity_uuid = $1", entityUUID).Scan(&r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	return r, err
+}
+
+// Resolve returns UUID of an entity by its path.
+func (p *Permalinks) Resolve(ctx context.Context, urlPath string) (string, error) {
+	var r string
+
+	err := p.db.QueryRow(ctx, "SELECT entity_uuid::text FROM permalinks WHERE path = $1", Clean(urlPath)).Scan(&r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	return r, err
+}
+
+// assignAttempts is the number of times Assign tries to take a path when concurrent assignments take the same one.
+const assignAttempts = 5
+
+// Assign makes a path for an entity from a prefix and a title, such as "/blog" and "Hello, World!" giving
+// "/blog/hello-world". If the path is taken by another entity, a numeric suffix is added. If the entity already has
+// the same path, it is kept, and so is its suffix while another entity still has the path without it. Otherwise the
+// old path redirects to the new one.
+//
+// If another entity takes the same path concurrently, Assign retries with the next free suffix.
+func (p *Permalinks) Assign(ctx context.Context, entityUUID, prefix, title string) (string, error) {
+	base := slug.Make(title)
+	if base == "" {
+		base = entityUUID
+	}
+	base = Clean(path.Join(prefix, base))
+
+	var (
+		r   string
+		err error
+	)
+
+	for i := 0; i < assignAttempts; i++ {
+		if r, err = p.assign(ctx, entityUUID, base); err != ErrExists {
+			break
+		}
+	}
+
+	return r, err
+}
+
+// assign makes a path for an entity from a base path, see Assign.
+func (p *Permalinks) assign(ctx context.Context, entityUUID, base string) (string, error) {
+	var r string
+
+	err := p.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		current, err := lockPath(ctx, tx, entityUUID)
+		if err != nil {
+			return err
+		}
+
+		if current == base {
+			r = current
+			return nil
+		}
+
+		rows, err := tx.Query(ctx, "SELECT path FROM permalinks WHERE (path = $1 OR path LIKE $2) "+
+			"AND entity_uuid <> $3", base, escapeLike(base)+"-%", entityUUID)
+		if err != nil {
+			return err
+		}
+
+		taken := make(map[string]bool)
+		for rows.Next() {
+			var s string
+			if err = rows.Scan(&s); err != nil {
+				rows.Close()
+				return err
+			}
+			taken[s] = true
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		// The suffix is kept only while it resolves a collision
+		if taken[base] && hasSuffix(current, base) {
+			r = current
+			return nil
+		}
+
+		for n := 1; ; n++ {
+			if r = slug.WithSuffix(base, n); !taken[r] {
+				break
+			}
+		}
+
+		return setPath(ctx, tx, entityUUID, r, current)
+	})
+	if err != nil {
+		return "", mapError(err)
+	}
+
+	return r, nil
+}
+
+// Set sets an explicit path of an entity. If the entity had another path, it redirects to the new one.
+func (p *Permalinks) Set(ctx context.Context, entityUUID, urlPath string) error {
+	err := p.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		current, err := lockPath(ctx, tx, entityUUID)
+		if err != nil {
+			return err
+		}
+
+		return setPath(ctx, tx, entityUUID, Clean(urlPath), current)
+	})
+
+	return mapError(err)
+}
+
+// Remove removes the path of an entity and redirects to it.
+func (p *Permalinks) Remove(ctx context.Context, entityUUID string) error {
+	return p.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM permalinks WHERE entity_uuid = $1", entityUUID); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, "DELETE FROM redirects WHERE entity_uuid = $1", entityUUID)
+
+		return err
+	})
+}
+
+// Redirect returns a redirect rule by its source path.
+func (p *Permalinks) Redirect(ctx context.Context, urlPath string) (*Redirect, error) {
+	r := &Redirect{}
+
+	err := p.db.SelectOne(ctx, r, "SELECT id, created_at, updated_at, source, target, status, entity_uuid "+
+		"FROM redirects WHERE source = $1", Clean(urlPath))
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Redirects returns all redirect rules ordered by source path.
+func (p *Permalinks) Redirects(ctx context.Context) ([]*Redirect, error) {
+	var r []*Redirect
+
+	err := p.db.SelectAll(ctx, &r, "SELECT id, created_at, updated_at, source, target, status, entity_uuid "+
+		"FROM redirects ORDER BY source")
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// SetRedirect creates or updates a redirect rule. Target may be a path or an absolute URL.
+func (p *Permalinks) SetRedirect(ctx context.Context, source, target string, status int) error {
+	switch status {
+	case 301, 302, 303, 307, 308:
+	default:
+		return ErrBadStatus
+	}
+
+	_, err := p.db.Exec(ctx, "INSERT INTO redirects (source, target, status) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (source) DO UPDATE SET target = excluded.target, status = excluded.status, updated_at = now()",
+		Clean(source), target, status)
+
+	return err
+}
+
+// DeleteRedirect deletes a redirect rule.
+func (p *Permalinks) DeleteRedirect(ctx context.Context, source string) error {
+	tag, err := p.db.Exec(ctx, "DELETE FROM redirects WHERE source = $1", Clean(source))
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Clean returns the canonical form of a URL path: with a leading slash and without a trailing one.
+func Clean(urlPath string) string {
+	return path.Clean("/" + urlPath)
+}
+
+func lockPath(ctx context.Context, tx pgx.Tx, entityUUID string) (string, error) {
+	var r string
+
+	err := tx.QueryRow(ctx, "SELECT path FROM permalinks WHERE entity_uuid = $1 FOR UPDATE", entityUUID).Scan(&r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+
+	return r, err
+}
+
+func setPath(ctx context.Context, tx pgx.Tx, entityUUID, newPath, oldPath string) error {
+	if newPath == oldPath {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, "INSERT INTO permalinks (path, entity_uuid) VALUES ($1, $2) ON CONFLICT (entity_uuid) "+
+		"DO UPDATE SET path = excluded.path, updated_at = now()", newPath, entityUUID)
+	if err != nil {
+		return err
+	}
+
+	// The new path is live now, so it must not redirect anywhere
+	if _, err = tx.Exec(ctx, "DELETE FROM redirects WHERE source = $1", newPath); err != nil {
+		return err
+	}
+
+	if oldPath == "" {
+		return nil
+	}
+
+	// Avoid redirect chains
+	if _, err = tx.Exec(ctx, "UPDATE redirects SET target = $2, updated_at = now() WHERE target = $1",
+		oldPath, newPath); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO redirects (source, target, status, entity_uuid) VALUES ($1, $2, 301, $3) "+
+		"ON CONFLICT (source) DO UPDATE SET target = excluded.target, status = excluded.status, "+
+		"entity_uuid = excluded.entity_uuid, updated_at = now()", oldPath, newPath, entityUUID)
+
+	return err
+}
+
+// hasSuffix checks whether a path is the base path with a numeric uniqueness suffix.
+func hasSuffix(urlPath, base string) bool {
+	if !strings.HasPrefix(urlPath, base+"-") {
+		return false
+	}
+
+	n := urlPath[len(base)+1:]
+	if n == "" {
+		return false
+	}
+
+	for _, c := range n {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrExists
+	}
+
+	return err
+}
+
+// New creates a new permalinks storage.
+func New(db *database.Database) *Permalinks {
+	return &Permalinks{db}
+}
@@ -0,0 +1,163 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package permalink_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/permalink"
+)
+
+const (
+	uuid1 = "6f1c3b52-2c56-4d5e-9d6a-1f7a0c1b2d01"
+	uuid2 = "6f1c3b52-2c56-4d5e-9d6a-1f7a0c1b2d02"
+	uuid3 = "6f1c3b52-2c56-4d5e-9d6a-1f7a0c1b2d03"
+)
+
+func TestClean(t *testing.T) {
+	for in, out := range map[string]string{
+		"":            "/",
+		"/":           "/",
+		"blog":        "/blog",
+		"/blog/":      "/blog",
+		"//blog/../a": "/a",
+	} {
+		require.Equal(t, out, permalink.Clean(in), in)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	p := permalink.New(databasetest.New(t))
+	ctx := context.Background()
+
+	path, err := p.Assign(ctx, uuid1, "/blog", "Hello, World!")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world", path)
+
+	// Taken paths get a suffix
+	path, err = p.Assign(ctx, uuid2, "/blog", "Hello World")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world-2", path)
+
+	// The same title keeps the path, suffix included
+	path, err = p.Assign(ctx, uuid2, "/blog", "Hello world")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world-2", path)
+
+	uuid, err := p.Resolve(ctx, "/blog/hello-world-2/")
+	require.NoError(t, err)
+	require.Equal(t, uuid2, uuid)
+
+	// A new title redirects from the old path
+	path, err = p.Assign(ctx, uuid1, "/blog", "Goodbye")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/goodbye", path)
+
+	rd, err := p.Redirect(ctx, "/blog/hello-world")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/goodbye", rd.Target)
+	require.Equal(t, http.StatusMovedPermanently, rd.Status)
+
+	// Chains are collapsed and live paths do not redirect
+	_, err = p.Assign(ctx, uuid1, "/blog", "Farewell")
+	require.NoError(t, err)
+	rd, err = p.Redirect(ctx, "/blog/hello-world")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/farewell", rd.Target)
+
+	_, err = p.Assign(ctx, uuid3, "/blog", "Hello World")
+	require.NoError(t, err)
+	_, err = p.Redirect(ctx, "/blog/hello-world")
+	require.Equal(t, permalink.ErrNotFound, err)
+
+	require.Equal(t, permalink.ErrExists, p.Set(ctx, uuid1, "/blog/hello-world"))
+
+	// A suffix which did not resolve a collision is not kept
+	path, err = p.Assign(ctx, uuid1, "/", "Top 10")
+	require.NoError(t, err)
+	require.Equal(t, "/top-10", path)
+	path, err = p.Assign(ctx, uuid1, "/", "Top")
+	require.NoError(t, err)
+	require.Equal(t, "/top", path)
+
+	rd, err = p.Redirect(ctx, "/top-10")
+	require.NoError(t, err)
+	require.Equal(t, "/top", rd.Target)
+
+	// Nor is one whose collision is gone
+	path, err = p.Assign(ctx, uuid3, "/blog", "Hello World")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world", path)
+	path, err = p.Assign(ctx, uuid2, "/blog", "Hello World")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world-2", path)
+	path, err = p.Assign(ctx, uuid3, "/blog", "Goodbye")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/goodbye", path)
+	path, err = p.Assign(ctx, uuid2, "/blog", "Hello World")
+	require.NoError(t, err)
+	require.Equal(t, "/blog/hello-world", path)
+}
+
+func TestAssignConcurrently(t *testing.T) {
+	p := permalink.New(databasetest.New(t))
+	uuids := []string{uuid1, uuid2, uuid3}
+	paths := make([]string, len(uuids))
+	errs := make([]error, len(uuids))
+
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func(i int, uuid string) {
+			defer wg.Done()
+			paths[i], errs[i] = p.Assign(context.Background(), uuid, "/", "Same title")
+		}(i, uuid)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := range uuids {
+		require.NoError(t, errs[i])
+		require.False(t, seen[paths[i]], paths[i])
+		seen[paths[i]] = true
+	}
+}
+
+func TestMountRedirects(t *testing.T) {
+	p := permalink.New(databasetest.New(t))
+	ctx := context.Background()
+	require.NoError(t, p.SetRedirect(ctx, "/old", "/new", http.StatusFound))
+	require.Equal(t, permalink.ErrBadStatus, p.SetRedirect(ctx, "/old", "/new", http.StatusOK))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	p.Mount(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old?page=2", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "/new?page=2", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Handler redirects paths without entities
+	h := p.Handler(func(w http.ResponseWriter, r *http.Request, entityUUID string) {})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	require.NoError(t, p.DeleteRedirect(ctx, "/old"))
+	require.Equal(t, permalink.ErrNotFound, p.DeleteRedirect(ctx, "/old"))
+}
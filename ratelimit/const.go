@@ -0,0 +1,16 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit
+
+import "time"
+
+const (
+	DftAlgorithm    = AlgorithmTokenBucket // limiting algorithm
+	DftLimit        = 100                  // requests allowed per window
+	DftWindow       = time.Minute          // window the limit applies to
+	DftKey          = KeyIP                // what requests are counted by
+	DftAPIKeyHeader = "X-API-Key"          // header carrying API keys
+	DftStorage      = StorageMemory        // limiter state storage
+)
@@ -0,0 +1,8 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package ratelimit provides rate limiting of HTTP requests with token-bucket and sliding-window limiters keyed by
+// client IP, API key or JWT subject. API keys are counted separately only if a KeyValidator knows them, other requests
+// are counted by the client IP.
+package ratelimit
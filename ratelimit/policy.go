@@ -0,0 +1,172 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Algorithms.
+const (
+	// AlgorithmTokenBucket allows bursts of up to the limit, tokens are refilled evenly over the window.
+	AlgorithmTokenBucket = "tokenBucket"
+
+	// AlgorithmSlidingWindow allows up to the limit in any window, approximated by weighting the count of the
+	// previous fixed window.
+	AlgorithmSlidingWindow = "slidingWindow"
+)
+
+// Keys requests are counted by.
+const (
+	KeyIP      = "ip"      // client IP address
+	KeyAPIKey  = "apiKey"  // API key header, client IP if absent or unknown
+	KeySubject = "subject" // JWT subject of an authenticated user, client IP if anonymous
+)
+
+// State is a limiter state of a key kept by a store.
+type State struct {
+	Tokens    float64   // tokens left in a bucket
+	Count     int64     // requests in the current window
+	PrevCount int64     // requests in the previous window
+	Start     time.Time // last bucket refill or current window start, zero for a new key
+}
+
+// Result is a result of taking a request from a limit.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until the limit is fully available
+	RetryAfter time.Duration // time until a request is allowed, if it is not
+}
+
+// Policy is a rate limiting policy. Policies with a route apply to requests matching the route template and
+// methods, if any, only.
+type Policy struct {
+	Name      string
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+	Key       string
+	Route     string
+	Methods   []string
+}
+
+func (p *Policy) validate() error {
+	switch {
+	case p.Algorithm != AlgorithmTokenBucket && p.Algorithm != AlgorithmSlidingWindow:
+		return fmt.Errorf("rate limit %s: unknown algorithm %q", p.Name, p.Algorithm)
+	case p.Key != KeyIP && p.Key != KeyAPIKey && p.Key != KeySubject:
+		return fmt.Errorf("rate limit %s: unknown key %q", p.Name, p.Key)
+	case p.Window <= 0:
+		return fmt.Errorf("rate limit %s: window must be positive", p.Name)
+	}
+
+	return nil
+}
+
+// matches reports whether the policy applies to a request of a route and method.
+func (p *Policy) matches(route, method string) bool {
+	if p.Route != route {
+		return false
+	}
+	if len(p.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Take takes a request from the limit of a state at a time, updating the state.
+func (p *Policy) Take(s *State, now time.Time) *Result {
+	if p.Algorithm == AlgorithmSlidingWindow {
+		return p.takeWindow(s, now)
+	}
+
+	return p.takeToken(s, now)
+}
+
+func (p *Policy) takeToken(s *State, now time.Time) *Result {
+	limit := float64(p.Limit)
+	rate := limit / p.Window.Seconds()
+
+	if s.Start.IsZero() {
+		s.Tokens = limit
+	} else if elapsed := now.Sub(s.Start).Seconds(); elapsed > 0 {
+		s.Tokens = math.Min(limit, s.Tokens+elapsed*rate)
+	}
+	s.Start = now
+
+	r := &Result{Limit: p.Limit}
+	if s.Tokens >= 1 {
+		s.Tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - s.Tokens) / rate)
+	}
+	r.Remaining = int(math.Floor(s.Tokens))
+	r.Reset = seconds((limit - s.Tokens) / rate)
+
+	return r
+}
+
+func (p *Policy) takeWindow(s *State, now time.Time) *Result {
+	start := now.Truncate(p.Window)
+	if !s.Start.Equal(start) {
+		if s.Start.Equal(start.Add(-p.Window)) {
+			s.PrevCount = s.Count
+		} else {
+			s.PrevCount = 0
+		}
+		s.Count, s.Start = 0, start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(p.Window)
+	estimate := float64(s.PrevCount)*weight + float64(s.Count)
+	limit := float64(p.Limit)
+
+	r := &Result{Limit: p.Limit, Reset: p.Window - elapsed}
+	if estimate+1 <= limit {
+		s.Count++
+		estimate++
+		r.Allowed = true
+	} else {
+		r.RetryAfter = p.retryWindow(s, elapsed)
+	}
+	r.Remaining = int(math.Max(0, limit-math.Ceil(estimate)))
+
+	return r
+}
+
+// retryWindow returns the time until the estimate of a sliding window decreases enough to allow a request.
+func (p *Policy) retryWindow(s *State, elapsed time.Duration) time.Duration {
+	w, limit := float64(p.Window), float64(p.Limit)
+
+	// The current window alone is full, the count becomes the previous one in the next window
+	if float64(s.Count)+1 > limit {
+		if limit < 1 {
+			return p.Window - elapsed + p.Window
+		}
+		return p.Window - elapsed + time.Duration(w*(1-(limit-1)/float64(s.Count)))
+	}
+
+	// The weight of the previous window has to decrease
+	t := w*(1-(limit-1-float64(s.Count))/float64(s.PrevCount)) - float64(elapsed)
+
+	return time.Duration(math.Max(0, t))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,81 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/ratelimit"
+)
+
+func TestTokenBucket(t *testing.T) {
+	p := &ratelimit.Policy{Algorithm: ratelimit.AlgorithmTokenBucket, Limit: 2, Window: 10 * time.Second}
+	s := &ratelimit.State{}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Burst up to the limit
+	r := p.Take(s, now)
+	require.True(t, r.Allowed)
+	require.Equal(t, 2, r.Limit)
+	require.Equal(t, 1, r.Remaining)
+	require.Equal(t, 5*time.Second, r.Reset)
+
+	r = p.Take(s, now)
+	require.True(t, r.Allowed)
+	require.Equal(t, 0, r.Remaining)
+	require.Equal(t, 10*time.Second, r.Reset)
+
+	r = p.Take(s, now.Add(time.Second))
+	require.False(t, r.Allowed)
+	require.Equal(t, 0, r.Remaining)
+	require.Equal(t, 4*time.Second, r.RetryAfter)
+
+	// A token is refilled every 5 seconds
+	r = p.Take(s, now.Add(5*time.Second))
+	require.True(t, r.Allowed)
+	require.Equal(t, 0, r.Remaining)
+
+	r = p.Take(s, now.Add(20*time.Second))
+	require.True(t, r.Allowed)
+	require.Equal(t, 1, r.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	p := &ratelimit.Policy{Algorithm: ratelimit.AlgorithmSlidingWindow, Limit: 4, Window: 10 * time.Second}
+	s := &ratelimit.State{}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		r := p.Take(s, now.Add(2*time.Second))
+		require.True(t, r.Allowed)
+		require.Equal(t, 3-i, r.Remaining)
+		require.Equal(t, 8*time.Second, r.Reset)
+	}
+
+	r := p.Take(s, now.Add(2*time.Second))
+	require.False(t, r.Allowed)
+	require.Equal(t, 0, r.Remaining)
+	require.Equal(t, 8*time.Second+2500*time.Millisecond, r.RetryAfter)
+
+	// The previous window weighs 3/4 at the 12.5th second
+	r = p.Take(s, now.Add(12500*time.Millisecond))
+	require.True(t, r.Allowed)
+	require.Equal(t, 0, r.Remaining)
+
+	r = p.Take(s, now.Add(12500*time.Millisecond))
+	require.False(t, r.Allowed)
+	require.Equal(t, 2500*time.Millisecond, r.RetryAfter)
+
+	r = p.Take(s, now.Add(15*time.Second))
+	require.True(t, r.Allowed)
+
+	// Windows older than the previous one are not counted
+	r = p.Take(s, now.Add(30*time.Second))
+	require.True(t, r.Allowed)
+	require.Equal(t, 3, r.Remaining)
+}
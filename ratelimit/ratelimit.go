@@ -0,0 +1,258 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/security"
+)
+
+// KeyValidator reports whether an API key is known, e.g. issued and not revoked. It is called for every request
+// limited by API key, so it is expected to be fast; validators checking a database should cache the results.
+type KeyValidator func(ctx context.Context, key string) bool
+
+// Option configures a limiter.
+type Option func(l *Limiter)
+
+// WithKeyValidator sets the validator of API keys. It is required if a policy limits requests by API key, since
+// clients could get a fresh limit with every made up key otherwise.
+func WithKeyValidator(fn KeyValidator) Option {
+	return func(l *Limiter) {
+		l.validKey = fn
+	}
+}
+
+// Limiter limits rates of requests by policies.
+type Limiter struct {
+	store        Store
+	dft          *Policy
+	routes       []*Policy
+	apiKeyHeader string
+	validKey     KeyValidator
+	trustProxy   bool
+}
+
+// Default returns the default policy applying to requests no route policy matches.
+func (l *Limiter) Default() *Policy {
+	return l.dft
+}
+
+// Routes returns the route policies.
+func (l *Limiter) Routes() []*Policy {
+	return l.routes
+}
+
+// Policy returns the policy applying to a request of a route template and method.
+func (l *Limiter) Policy(route, method string) *Policy {
+	for _, p := range l.routes {
+		if p.matches(route, method) {
+			return p
+		}
+	}
+
+	return l.dft
+}
+
+// Take takes a request of a client identified by an ID from the limit of a policy.
+func (l *Limiter) Take(ctx context.Context, p *Policy, id string) (*Result, error) {
+	var r *Result
+
+	now := time.Now()
+	err := l.store.Update(ctx, p.Name+":"+id, now.Add(2*p.Window), func(s *State) {
+		r = p.Take(s, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ClientID returns the ID of the client of a request to count it by under a policy: ip:ADDRESS, key:HASH or
+// sub:SUBJECT. Requests without a valid API key or anonymous ones are counted by the client IP.
+func (l *Limiter) ClientID(r *http.Request, p *Policy) string {
+	switch p.Key {
+	case KeyAPIKey:
+		if k := r.Header.Get(l.apiKeyHeader); k != "" && l.validKey != nil && l.validKey(r.Context(), k) {
+			// Keys are secrets, they are not kept in stores
+			h := sha256.Sum256([]byte(k))
+			return "key:" + hex.EncodeToString(h[:16])
+		}
+	case KeySubject:
+		if pr := security.PrincipalFrom(r.Context()); pr != nil && pr.Subject != "" {
+			return "sub:" + pr.Subject
+		}
+	}
+
+	return "ip:" + httputil.RemoteIP(r, l.trustProxy)
+}
+
+// Middleware limits rates of requests by the policy of the matched route, see Policy, and sets RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers. Requests exceeding the limit are rejected with
+// 429 status and the Retry-After header. If the store fails, requests are served and the error is logged.
+//
+// It is a router middleware, since the route is known after matching only. To limit by JWT subject it has to follow
+// security.Middleware:
+//
+//	svc.Router().Use(security.Middleware, limiter.Middleware)
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if cr := mux.CurrentRoute(r); cr != nil {
+			route, _ = cr.GetPathTemplate()
+		}
+
+		p := l.Policy(route, r.Method)
+		if p.Limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := l.Take(r.Context(), p, l.ClientID(r, p))
+		if err != nil {
+			logger.FromContext(r.Context()).Error("rate limiting failed", "policy", p.Name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", p.Limit, ceilSeconds(p.Window)))
+
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			_, _ = httputil.WriteError(w, http.StatusTooManyRequests, "", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// validate checks a policy and whether the limiter can identify clients by its key.
+func (l *Limiter) validate(p *Policy) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	if p.Key == KeyAPIKey && p.Limit > 0 && l.validKey == nil {
+		return fmt.Errorf("rate limit %s: limiting by API key needs a key validator", p.Name)
+	}
+
+	return nil
+}
+
+// NewStore creates a store set by ratelimit.storage: memory or postgres. The database is used by the Postgres store
+// only, it may be nil otherwise.
+func NewStore(cfg config.Config, db *database.Database) (Store, error) {
+	cfg.SetDefault("ratelimit.storage", DftStorage)
+
+	switch s := cfg.GetString("ratelimit.storage"); s {
+	case StorageMemory:
+		return NewMemoryStore(), nil
+	case StoragePostgres:
+		if db == nil {
+			return nil, fmt.Errorf("rate limit storage %s needs a database", s)
+		}
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit storage %q", s)
+	}
+}
+
+// New creates a new limiter keeping states in a store. The default policy is configured by ratelimit.algorithm,
+// limit, window and key settings, route policies by ratelimit.routes.NAME.route and methods settings, other settings
+// of a route policy default to the default policy ones. A policy with a zero limit does not limit requests. Policies
+// limiting requests by API key need a key validator, see WithKeyValidator.
+func New(cfg config.Config, store Store, opts ...Option) (*Limiter, error) {
+	cfg.SetDefault("ratelimit.algorithm", DftAlgorithm)
+	cfg.SetDefault("ratelimit.limit", DftLimit)
+	cfg.SetDefault("ratelimit.window", DftWindow)
+	cfg.SetDefault("ratelimit.key", DftKey)
+	cfg.SetDefault("ratelimit.apiKeyHeader", DftAPIKeyHeader)
+	cfg.SetDefault("service.trustProxy", false)
+
+	l := &Limiter{
+		store: store,
+		dft: &Policy{
+			Name:      "default",
+			Algorithm: cfg.GetString("ratelimit.algorithm"),
+			Limit:     cfg.GetInt("ratelimit.limit"),
+			Window:    cfg.GetDuration("ratelimit.window"),
+			Key:       cfg.GetString("ratelimit.key"),
+		},
+		apiKeyHeader: cfg.GetString("ratelimit.apiKeyHeader"),
+		trustProxy:   cfg.GetBool("service.trustProxy"),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := l.validate(l.dft); err != nil {
+		return nil, err
+	}
+
+	for name := range cfg.GetStringMap("ratelimit.routes") {
+		key := "ratelimit.routes." + name
+		p := *l.dft
+		p.Name = name
+		p.Route = cfg.GetString(key + ".route")
+		for _, m := range cfg.GetStringSlice(key + ".methods") {
+			p.Methods = append(p.Methods, strings.ToUpper(m))
+		}
+		if cfg.IsSet(key + ".algorithm") {
+			p.Algorithm = cfg.GetString(key + ".algorithm")
+		}
+		if cfg.IsSet(key + ".limit") {
+			p.Limit = cfg.GetInt(key + ".limit")
+		}
+		if cfg.IsSet(key + ".window") {
+			p.Window = cfg.GetDuration(key + ".window")
+		}
+		if cfg.IsSet(key + ".key") {
+			p.Key = cfg.GetString(key + ".key")
+		}
+
+		if p.Route == "" {
+			return nil, fmt.Errorf("rate limit %s: no route", name)
+		}
+		if err := l.validate(&p); err != nil {
+			return nil, err
+		}
+		l.routes = append(l.routes, &p)
+	}
+
+	// Policies with methods take precedence over ones of the same route without
+	sort.Slice(l.routes, func(i, j int) bool {
+		a, b := l.routes[i], l.routes[j]
+		if (len(a.Methods) > 0) != (len(b.Methods) > 0) {
+			return len(a.Methods) > 0
+		}
+		return a.Name < b.Name
+	})
+
+	return l, nil
+}
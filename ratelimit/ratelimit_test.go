@@ -0,0 +1,191 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/ratelimit"
+	"ampho.xyz/core/security"
+)
+
+func newRouter(t *testing.T, set func(cfg config.Config), opts ...ratelimit.Option) http.Handler {
+	cfg := config.NewTesting("ratelimit")
+	cfg.Set("ratelimit.limit", 2)
+	set(cfg)
+
+	l, err := ratelimit.New(cfg, ratelimit.NewMemoryStore(), opts...)
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sub := r.Header.Get("X-Subject"); sub != "" {
+				r = r.WithContext(security.WithPrincipal(r.Context(), &security.Principal{Subject: sub}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, l.Middleware)
+	r.HandleFunc("/items", ok)
+	r.HandleFunc("/login", ok).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/health", ok)
+
+	return r
+}
+
+func do(h http.Handler, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	h := newRouter(t, func(cfg config.Config) {
+		cfg.Set("ratelimit.routes.login.route", "/login")
+		cfg.Set("ratelimit.routes.login.methods", []string{"post"})
+		cfg.Set("ratelimit.routes.login.limit", 1)
+		cfg.Set("ratelimit.routes.login.window", "1h")
+		cfg.Set("ratelimit.routes.health.route", "/health")
+		cfg.Set("ratelimit.routes.health.limit", 0)
+	})
+
+	w := do(h, http.MethodGet, "/items", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = do(h, http.MethodGet, "/items", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = do(h, http.MethodGet, "/items", nil)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.JSONEq(t, `{"error":"Too Many Requests"}`, w.Body.String())
+
+	// Clients are counted separately, proxy headers are not trusted by default
+	w = do(h, http.MethodGet, "/items", map[string]string{"X-Forwarded-For": "10.0.0.1"})
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Route override
+	w = do(h, http.MethodPost, "/login", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1;w=3600", w.Header().Get("RateLimit-Policy"))
+	w = do(h, http.MethodPost, "/login", nil)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// Other methods fall back to the default policy
+	w = do(h, http.MethodGet, "/login", nil)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	// Unlimited
+	for i := 0; i < 5; i++ {
+		w = do(h, http.MethodGet, "/health", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestMiddlewareKeys(t *testing.T) {
+	h := newRouter(t, func(cfg config.Config) {
+		cfg.Set("ratelimit.key", ratelimit.KeyAPIKey)
+		cfg.Set("ratelimit.routes.login.route", "/login")
+		cfg.Set("ratelimit.routes.login.key", ratelimit.KeySubject)
+	}, ratelimit.WithKeyValidator(func(ctx context.Context, key string) bool {
+		return key == "a" || key == "b"
+	}))
+
+	// API key
+	for _, key := range []string{"a", "b"} {
+		for i := 0; i < 2; i++ {
+			w := do(h, http.MethodGet, "/items", map[string]string{"X-API-Key": key})
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+		w := do(h, http.MethodGet, "/items", map[string]string{"X-API-Key": key})
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+	}
+	w := do(h, http.MethodGet, "/items", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Unknown keys are counted by the client IP
+	w = do(h, http.MethodGet, "/items", map[string]string{"X-API-Key": "forged-1"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do(h, http.MethodGet, "/items", map[string]string{"X-API-Key": "forged-2"})
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// JWT subject
+	for _, sub := range []string{"alice", "bob"} {
+		for i := 0; i < 2; i++ {
+			w := do(h, http.MethodGet, "/login", map[string]string{"X-Subject": sub})
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+		w := do(h, http.MethodGet, "/login", map[string]string{"X-Subject": sub})
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+	}
+	w = do(h, http.MethodGet, "/login", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNew(t *testing.T) {
+	l, err := ratelimit.New(config.NewTesting("ratelimit"), ratelimit.NewMemoryStore())
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.DftAlgorithm, l.Default().Algorithm)
+	require.Equal(t, ratelimit.DftLimit, l.Default().Limit)
+	require.Equal(t, ratelimit.DftWindow, l.Default().Window)
+	require.Equal(t, ratelimit.DftKey, l.Default().Key)
+	require.Empty(t, l.Routes())
+
+	for key, val := range map[string]interface{}{
+		"ratelimit.algorithm":              "leakyBucket",
+		"ratelimit.key":                    "cookie",
+		"ratelimit.window":                 "0s",
+		"ratelimit.routes.login.limit":     5,
+		"ratelimit.routes.items.algorithm": "fixedWindow",
+		"ratelimit.routes.items.window":    "-1s",
+		"ratelimit.routes.items.key":       ratelimit.KeyAPIKey, // no key validator
+	} {
+		cfg := config.NewTesting("ratelimit")
+		cfg.Set("ratelimit.routes.items.route", "/items")
+		cfg.Set(key, val)
+		_, err = ratelimit.New(cfg, ratelimit.NewMemoryStore())
+		require.Error(t, err, key)
+	}
+
+	_, err = ratelimit.NewStore(config.NewTesting("ratelimit"), nil)
+	require.NoError(t, err)
+	cfg := config.NewTesting("ratelimit")
+	cfg.Set("ratelimit.storage", ratelimit.StoragePostgres)
+	_, err = ratelimit.NewStore(cfg, nil)
+	require.Error(t, err)
+	cfg.Set("ratelimit.storage", "redis")
+	_, err = ratelimit.NewStore(cfg, nil)
+	require.Error(t, err)
+}
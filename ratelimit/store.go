@@ -0,0 +1,121 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+)
+
+// Storages.
+const (
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+)
+
+// Store keeps limiter states.
+type Store interface {
+	// Update calls fn with the state of a key, a zero one if the key is new or expired, and saves it until the
+	// expiration time. Concurrent updates of a key are serialized.
+	Update(ctx context.Context, key string, expires time.Time, fn func(s *State)) error
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// MemoryStore keeps limiter states in memory, so limits apply to each service instance separately.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	nextSweep time.Time
+}
+
+// Update implements Store.
+func (m *MemoryStore) Update(_ context.Context, key string, expires time.Time, fn func(s *State)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.After(m.nextSweep) {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.nextSweep = now.Add(time.Minute)
+	}
+
+	e, ok := m.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &memoryEntry{}
+		m.entries[key] = e
+	}
+
+	fn(&e.state)
+	e.expires = expires
+
+	return nil
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+// PostgresStore keeps limiter states in the ratelimits table, so limits are shared by service instances.
+type PostgresStore struct {
+	db *database.Database
+}
+
+// Update implements Store. The state row is locked for the update.
+func (p *PostgresStore) Update(ctx context.Context, key string, expires time.Time, fn func(s *State)) error {
+	return p.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO ratelimits (key, expires_at) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE "+
+			"SET tokens = 0, count = 0, prev_count = 0, start_at = NULL WHERE ratelimits.expires_at < now()",
+			key, expires)
+		if err != nil {
+			return err
+		}
+
+		var (
+			s     State
+			start *time.Time
+		)
+		err = tx.QueryRow(ctx, "SELECT tokens, count, prev_count, start_at FROM ratelimits WHERE key = $1 FOR UPDATE",
+			key).Scan(&s.Tokens, &s.Count, &s.PrevCount, &start)
+		if err != nil {
+			return err
+		}
+		if start != nil {
+			s.Start = *start
+		}
+
+		fn(&s)
+
+		_, err = tx.Exec(ctx, "UPDATE ratelimits SET tokens = $2, count = $3, prev_count = $4, start_at = $5, "+
+			"expires_at = $6 WHERE key = $1", key, s.Tokens, s.Count, s.PrevCount, s.Start, expires)
+
+		return err
+	})
+}
+
+// Cleanup deletes expired states. It is expected to be called periodically, e.g. by job.Periodic.
+func (p *PostgresStore) Cleanup(ctx context.Context) error {
+	_, err := p.db.Exec(ctx, "DELETE FROM ratelimits WHERE expires_at < now()")
+
+	return err
+}
+
+// NewPostgresStore creates a new Postgres store.
+func NewPostgresStore(db *database.Database) *PostgresStore {
+	return &PostgresStore{db}
+}
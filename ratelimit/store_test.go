@@ -0,0 +1,62 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package ratelimit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/ratelimit"
+)
+
+func TestPostgresStoreConcurrency(t *testing.T) {
+	store := ratelimit.NewPostgresStore(databasetest.New(t))
+	p := &ratelimit.Policy{Algorithm: ratelimit.AlgorithmTokenBucket, Limit: 5, Window: time.Hour}
+	expires := time.Now().Add(time.Hour)
+
+	take := func() (bool, error) {
+		var r *ratelimit.Result
+		err := store.Update(context.Background(), "client", expires, func(s *ratelimit.State) {
+			r = p.Take(s, time.Now())
+		})
+		return r != nil && r.Allowed, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+		errs    []error
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := take()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if ok {
+				allowed++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Updates of the bucket are serialized, so no more tokens than it holds are taken
+	require.Empty(t, errs)
+	require.Equal(t, 5, allowed)
+
+	ok, err := take()
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,12 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package relation stores named references between entities by UUID, e.g. from an article to its author, and keeps
+// them consistent when entities are deleted.
+//
+// Every relation has a delete action applied when its target is deleted: restrict forbids the deletion, cascade
+// deletes the source as well, and nullify drops the reference from the source. Entity storages apply the actions in
+// the transaction deleting an entity, see DeleteTx. Relations also answer reverse lookups, like where a media asset
+// is used, see Relations.Sources.
+package relation
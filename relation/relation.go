@@ -0,0 +1,240 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package relation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/database"
+)
+
+// Action is an action applied to a relation when its target is deleted.
+type Action string
+
+const (
+	ActionRestrict Action = "restrict" // the target cannot be deleted
+	ActionCascade  Action = "cascade"  // the source is deleted along with the target
+	ActionNullify  Action = "nullify"  // the reference is removed from the source
+)
+
+const relationColumns = "source_uuid::text AS source_uuid, target_uuid::text AS target_uuid, name, position, " +
+	"on_delete"
+
+// ErrRestricted is returned when an entity cannot be deleted because other entities refer to it.
+var ErrRestricted = errors.New("entity is referenced by other entities")
+
+// RestrictedError lists the relations which prevent an entity from being deleted.
+type RestrictedError []*Relation
+
+// Error implements error.
+func (e RestrictedError) Error() string {
+	return fmt.Sprintf("%v: %d", ErrRestricted, len(e))
+}
+
+// Is reports whether the target is ErrRestricted.
+func (e RestrictedError) Is(target error) bool {
+	return target == ErrRestricted
+}
+
+// Relation is a named reference from a source entity to a target one.
+type Relation struct {
+	Source   string `db:"source_uuid" json:"source"`
+	Target   string `db:"target_uuid" json:"target"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	OnDelete Action `json:"onDelete"`
+}
+
+// Ref is a reference of a source entity.
+type Ref struct {
+	Name     string
+	Target   string
+	OnDelete Action
+}
+
+// Effects are the actions to apply to sources of relations of a deleted entity.
+type Effects struct {
+	Cascade []string    // UUIDs of sources to delete
+	Nullify []*Relation // removed relations which references must be dropped from their sources
+}
+
+// Relations is a relations storage.
+type Relations struct {
+	db *database.Database
+}
+
+// Set replaces all references of a source entity.
+func (r *Relations) Set(ctx context.Context, source string, refs []Ref) error {
+	return r.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		return SetTx(ctx, tx, source, refs)
+	})
+}
+
+// Targets returns relations of a source entity with the name or with any name if it is empty.
+func (r *Relations) Targets(ctx context.Context, source, name string) ([]*Relation, error) {
+	return r.selectRelations(ctx, "source_uuid = $1", source, name)
+}
+
+// Sources returns relations referring to a target entity with the name or with any name if it is empty.
+func (r *Relations) Sources(ctx context.Context, target, name string) ([]*Relation, error) {
+	return r.selectRelations(ctx, "target_uuid = $1", target, name)
+}
+
+// SourcesOf returns relations referring to any of the target entities.
+func (r *Relations) SourcesOf(ctx context.Context, targets []string) ([]*Relation, error) {
+	valid := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if database.IsUUID(t) {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, nil
+	}
+
+	var rels []*Relation
+
+	err := r.db.SelectAll(ctx, &rels, "SELECT "+relationColumns+" FROM relations "+
+		"WHERE target_uuid = ANY($1::uuid[]) ORDER BY id", valid)
+	if err != nil {
+		return nil, err
+	}
+
+	return rels, nil
+}
+
+// Delete deletes an entity relations, see DeleteTx.
+func (r *Relations) Delete(ctx context.Context, uuid string) (*Effects, error) {
+	var effects *Effects
+
+	err := r.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var err error
+		effects, err = DeleteTx(ctx, tx, uuid)
+		return err
+	})
+
+	return effects, err
+}
+
+func (r *Relations) selectRelations(ctx context.Context, cond, uuid, name string) ([]*Relation, error) {
+	if !database.IsUUID(uuid) {
+		return nil, nil
+	}
+
+	var rels []*Relation
+
+	err := r.db.SelectAll(ctx, &rels, "SELECT "+relationColumns+" FROM relations WHERE "+cond+
+		" AND ($2 = '' OR name = $2) ORDER BY name, position, id", uuid, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return rels, nil
+}
+
+// SetTx replaces all references of a source entity within a transaction. References to the same target with the
+// same name are stored once, positions keep the order of the references with the same name.
+func SetTx(ctx context.Context, tx pgx.Tx, source string, refs []Ref) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM relations WHERE source_uuid = $1", source); err != nil {
+		return err
+	}
+
+	positions := make(map[string]int)
+	for _, ref := range refs {
+		if !database.IsUUID(ref.Target) {
+			continue
+		}
+
+		action := ref.OnDelete
+		if action == "" {
+			action = ActionRestrict
+		}
+
+		_, err := tx.Exec(ctx, "INSERT INTO relations (source_uuid, target_uuid, name, position, on_delete) "+
+			"VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING", source, ref.Target, ref.Name,
+			positions[ref.Name], string(action))
+		if err != nil {
+			return err
+		}
+		positions[ref.Name]++
+	}
+
+	return nil
+}
+
+// DeleteTx removes relations of an entity being deleted within a transaction. If other entities refer to it with the
+// restrict action, it returns RestrictedError. Otherwise it returns the effects on the referring entities the
+// caller must apply.
+func DeleteTx(ctx context.Context, tx pgx.Tx, uuid string) (*Effects, error) {
+	effects, err := EffectsTx(ctx, tx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM relations WHERE target_uuid = $1 OR source_uuid = $1", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return effects, nil
+}
+
+// EffectsTx returns the effects deleting an entity would have on the entities referring to it within a transaction,
+// leaving the relations intact. If other entities refer to it with the restrict action, it returns RestrictedError.
+// The relations are locked until the transaction ends.
+func EffectsTx(ctx context.Context, tx pgx.Tx, uuid string) (*Effects, error) {
+	var rels []*Relation
+
+	err := pgxscan.Select(ctx, tx, &rels, "SELECT "+relationColumns+" FROM relations "+
+		"WHERE target_uuid = $1 AND source_uuid <> $1 ORDER BY id FOR UPDATE", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		restricted RestrictedError
+		effects    = &Effects{}
+		cascade    = make(map[string]bool)
+	)
+
+	for _, rel := range rels {
+		switch rel.OnDelete {
+		case ActionCascade:
+			if !cascade[rel.Source] {
+				cascade[rel.Source] = true
+				effects.Cascade = append(effects.Cascade, rel.Source)
+			}
+		case ActionNullify:
+			effects.Nullify = append(effects.Nullify, rel)
+		default:
+			restricted = append(restricted, rel)
+		}
+	}
+	if len(restricted) > 0 {
+		return nil, restricted
+	}
+
+	return effects, nil
+}
+
+// Check checks a delete action name is known. Empty name is the default restrict action.
+func Check(action Action) error {
+	switch action {
+	case "", ActionRestrict, ActionCascade, ActionNullify:
+		return nil
+	}
+
+	return fmt.Errorf("unknown delete action %q", action)
+}
+
+// New creates a new relations storage.
+func New(db *database.Database) *Relations {
+	return &Relations{db}
+}
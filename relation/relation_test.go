@@ -0,0 +1,118 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package relation_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/relation"
+)
+
+const (
+	uuid1 = "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f1"
+	uuid2 = "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f2"
+	uuid3 = "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f3"
+	uuid4 = "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f4"
+)
+
+func TestRestrictedError(t *testing.T) {
+	err := fmt.Errorf("delete: %w", relation.RestrictedError{{Source: "a", Target: "b", Name: "author"}})
+	require.ErrorIs(t, err, relation.ErrRestricted)
+
+	var restricted relation.RestrictedError
+	require.True(t, errors.As(err, &restricted))
+	require.Equal(t, "author", restricted[0].Name)
+}
+
+func TestCheck(t *testing.T) {
+	for _, a := range []relation.Action{"", relation.ActionRestrict, relation.ActionCascade, relation.ActionNullify} {
+		require.NoError(t, relation.Check(a))
+	}
+	require.Error(t, relation.Check("ignore"))
+}
+
+func TestSet(t *testing.T) {
+	r := relation.New(databasetest.New(t))
+	ctx := context.Background()
+
+	require.NoError(t, r.Set(ctx, uuid1, []relation.Ref{
+		{Name: "tags", Target: uuid3},
+		{Name: "tags", Target: uuid2},
+		{Name: "tags", Target: uuid3},
+		{Name: "author", Target: uuid2, OnDelete: relation.ActionCascade},
+		{Name: "author", Target: "malformed"},
+	}))
+
+	rels, err := r.Targets(ctx, uuid1, "")
+	require.NoError(t, err)
+	require.Len(t, rels, 3)
+	require.Equal(t, relation.Relation{Source: uuid1, Target: uuid2, Name: "author", OnDelete: relation.ActionCascade},
+		*rels[0])
+	require.Equal(t, relation.Relation{Source: uuid1, Target: uuid3, Name: "tags", OnDelete: relation.ActionRestrict},
+		*rels[1])
+	require.Equal(t, relation.Relation{Source: uuid1, Target: uuid2, Name: "tags", Position: 1,
+		OnDelete: relation.ActionRestrict}, *rels[2])
+
+	rels, err = r.Sources(ctx, uuid2, "tags")
+	require.NoError(t, err)
+	require.Len(t, rels, 1)
+
+	rels, err = r.SourcesOf(ctx, []string{uuid2, uuid3, "malformed"})
+	require.NoError(t, err)
+	require.Len(t, rels, 3)
+
+	// References are replaced
+	require.NoError(t, r.Set(ctx, uuid1, nil))
+	rels, err = r.Targets(ctx, uuid1, "")
+	require.NoError(t, err)
+	require.Empty(t, rels)
+}
+
+func TestDelete(t *testing.T) {
+	r := relation.New(databasetest.New(t))
+	ctx := context.Background()
+
+	require.NoError(t, r.Set(ctx, uuid1, []relation.Ref{{Name: "parent", Target: uuid4,
+		OnDelete: relation.ActionCascade}}))
+	require.NoError(t, r.Set(ctx, uuid2, []relation.Ref{{Name: "image", Target: uuid4,
+		OnDelete: relation.ActionNullify}}))
+	require.NoError(t, r.Set(ctx, uuid3, []relation.Ref{{Name: "author", Target: uuid4}}))
+	require.NoError(t, r.Set(ctx, uuid4, []relation.Ref{{Name: "self", Target: uuid4}, {Name: "next",
+		Target: uuid3}}))
+
+	// Restricted, nothing is deleted
+	_, err := r.Delete(ctx, uuid4)
+	var restricted relation.RestrictedError
+	require.ErrorAs(t, err, &restricted)
+	require.Len(t, restricted, 1)
+	require.Equal(t, uuid3, restricted[0].Source)
+
+	rels, err := r.Sources(ctx, uuid4, "")
+	require.NoError(t, err)
+	require.Len(t, rels, 4)
+
+	// Self references do not restrict
+	require.NoError(t, r.Set(ctx, uuid3, nil))
+	effects, err := r.Delete(ctx, uuid4)
+	require.NoError(t, err)
+	require.Equal(t, []string{uuid1}, effects.Cascade)
+	require.Len(t, effects.Nullify, 1)
+	require.Equal(t, uuid2, effects.Nullify[0].Source)
+	require.Equal(t, "image", effects.Nullify[0].Name)
+
+	// Relations of the entity are gone both ways
+	rels, err = r.Sources(ctx, uuid4, "")
+	require.NoError(t, err)
+	require.Empty(t, rels)
+	rels, err = r.Sources(ctx, uuid3, "")
+	require.NoError(t, err)
+	require.Empty(t, rels)
+}
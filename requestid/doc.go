@@ -0,0 +1,7 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package requestid provides request IDs, which correlate logs, outbound HTTP calls and database queries made while
+// serving a request.
+package requestid
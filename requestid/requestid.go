@@ -0,0 +1,125 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Header is the request ID header.
+const Header = "X-Request-ID"
+
+// MaxLength is the maximum length of a request ID accepted from a client.
+const MaxLength = 128
+
+type idKey struct{}
+
+// WithID returns a copy of the context with the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the request ID of a context or an empty string if it has none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+
+	return id
+}
+
+// New generates a new request ID: 16 random bytes in hex, the same form as a W3C trace ID.
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// IsValid reports whether a request ID is not empty, not longer than MaxLength and consists of ASCII letters, digits,
+// dots, dashes and underscores only, so it is safe to put into headers, logs and SQL comments.
+func IsValid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FromRequest returns the request ID sent by a client: the X-Request-ID header, or the trace ID of the W3C
+// traceparent header. It returns an empty string if neither is present and valid.
+func FromRequest(r *http.Request) string {
+	if id := r.Header.Get(Header); IsValid(id) {
+		return id
+	}
+
+	return traceID(r.Header.Get("traceparent"))
+}
+
+// traceID returns the trace ID of a traceparent header value: version-traceid-parentid-flags.
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 {
+		return ""
+	}
+
+	id := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(id); err != nil || id == strings.Repeat("0", 32) {
+		return ""
+	}
+
+	return id
+}
+
+// Middleware takes the request ID from the request, see FromRequest, or generates a new one, stores it in the request
+// context and echoes it in the X-Request-ID response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := FromRequest(r)
+		if id == "" {
+			id = New()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := FromContext(req.Context()); id != "" && req.Header.Get(Header) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(Header, id)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Transport returns an HTTP transport passing the request ID of an outbound request context on in the X-Request-ID
+// header. If base is nil, http.DefaultTransport is used.
+//
+//	client := &http.Client{Transport: requestid.Transport(nil)}
+//	req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+//	resp, err := client.Do(req)
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base}
+}
@@ -0,0 +1,98 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package requestid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/requestid"
+)
+
+func TestIsValid(t *testing.T) {
+	require.True(t, requestid.IsValid("abc-123_X.y"))
+	require.True(t, requestid.IsValid(requestid.New()))
+	require.False(t, requestid.IsValid(""))
+	require.False(t, requestid.IsValid("a b"))
+	require.False(t, requestid.IsValid("*/ DROP TABLE x; /*"))
+	require.False(t, requestid.IsValid(strings.Repeat("a", requestid.MaxLength+1)))
+
+	require.Len(t, requestid.New(), 32)
+	require.NotEqual(t, requestid.New(), requestid.New())
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.Empty(t, requestid.FromRequest(req))
+
+	req.Header.Set("traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestid.FromRequest(req))
+
+	req.Header.Set(requestid.Header, "abc")
+	require.Equal(t, "abc", requestid.FromRequest(req))
+
+	// Invalid IDs are ignored
+	req.Header.Set(requestid.Header, "a b")
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestid.FromRequest(req))
+
+	for _, v := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+		"garbage",
+	} {
+		req.Header.Set("traceparent", v)
+		require.Empty(t, requestid.FromRequest(req), v)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestid.FromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestid.Header, "abc")
+	h.ServeHTTP(rr, req)
+	require.Equal(t, "abc", got)
+	require.Equal(t, "abc", rr.Header().Get(requestid.Header))
+
+	// Generated
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.True(t, requestid.IsValid(got))
+	require.Equal(t, got, rr.Header().Get(requestid.Header))
+}
+
+func TestTransport(t *testing.T) {
+	ids := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get(requestid.Header)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: requestid.Transport(nil)}
+	do := func(ctx context.Context) string {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return <-ids
+	}
+
+	require.Equal(t, "abc", do(requestid.WithID(context.Background(), "abc")))
+	require.Empty(t, do(context.Background()))
+}
@@ -0,0 +1,16 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package richtext
+
+// LinkScheme is the URL scheme of entity references in links and images.
+const LinkScheme = "entity:"
+
+const (
+	PolicyUGC    = "ugc"    // formatting, links, images and tables commonly found in user generated content
+	PolicyStrict = "strict" // text only, all elements are stripped
+	PolicyNone   = "none"   // only the configured elements and attributes
+)
+
+const DftPolicy = PolicyUGC // base sanitisation policy
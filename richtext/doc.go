@@ -0,0 +1,10 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package richtext renders rich-text fields: it converts Markdown to HTML and sanitises the result against an
+// allowlist policy.
+//
+// Links and images may refer to entities instead of URLs, e.g. [About us](entity:<uuid>). Such references are replaced
+// by entity permalinks on rendering. Links to entities without a permalink are removed leaving their text.
+package richtext
@@ -0,0 +1,214 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package richtext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/permalink"
+)
+
+// Linker resolves entity UUIDs to URL paths. It returns permalink.ErrNotFound if an entity has no path.
+type Linker interface {
+	Path(ctx context.Context, entityUUID string) (string, error)
+}
+
+// Renderer renders Markdown to sanitised HTML.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	linker Linker
+}
+
+// Render converts Markdown to HTML, replaces entity references with permalinks and sanitises the result. Raw HTML in
+// the Markdown is kept if the policy allows it, entity references in raw HTML are not replaced.
+func (r *Renderer) Render(ctx context.Context, markdown string) (string, error) {
+	src := []byte(markdown)
+	doc := r.md.Parser().Parse(text.NewReader(src))
+
+	if err := r.rewriteLinks(ctx, doc); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := r.md.Renderer().Render(&buf, src, doc); err != nil {
+		return "", err
+	}
+
+	return r.policy.Sanitize(buf.String()), nil
+}
+
+// Sanitize sanitises HTML.
+func (r *Renderer) Sanitize(html string) string {
+	return r.policy.Sanitize(html)
+}
+
+// rewriteLinks replaces entity references in link and image destinations with entity paths.
+func (r *Renderer) rewriteLinks(ctx context.Context, doc ast.Node) error {
+	var refs []ast.Node
+
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			if dst := destination(n); dst != nil && bytes.HasPrefix(dst, []byte(LinkScheme)) {
+				refs = append(refs, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]string)
+	for _, n := range refs {
+		ref := string(destination(n))[len(LinkScheme):]
+		uuid, fragment := ref, ""
+		if i := strings.IndexByte(ref, '#'); i >= 0 {
+			uuid, fragment = ref[:i], ref[i:]
+		}
+
+		p, ok := paths[uuid]
+		if !ok {
+			if p, err = r.path(ctx, uuid); err != nil {
+				return err
+			}
+			paths[uuid] = p
+		}
+
+		switch n := n.(type) {
+		case *ast.Link:
+			if p == "" {
+				unwrap(n)
+			} else {
+				n.Destination = []byte(p + fragment)
+			}
+		case *ast.Image:
+			if p == "" {
+				n.Parent().RemoveChild(n.Parent(), n)
+			} else {
+				n.Destination = []byte(p + fragment)
+			}
+		}
+	}
+
+	return nil
+}
+
+// path returns the path of an entity or an empty string if it has none.
+func (r *Renderer) path(ctx context.Context, uuid string) (string, error) {
+	if r.linker == nil || !database.IsUUID(uuid) {
+		return "", nil
+	}
+
+	p, err := r.linker.Path(ctx, uuid)
+	if errors.Is(err, permalink.ErrNotFound) {
+		return "", nil
+	}
+
+	return p, err
+}
+
+// destination returns the destination of a link or an image node, nil for other nodes.
+func destination(n ast.Node) []byte {
+	switch n := n.(type) {
+	case *ast.Link:
+		return n.Destination
+	case *ast.Image:
+		return n.Destination
+	}
+
+	return nil
+}
+
+// unwrap replaces a node with its children.
+func unwrap(n ast.Node) {
+	parent := n.Parent()
+	for c := n.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		parent.InsertBefore(parent, n, c)
+		c = next
+	}
+	parent.RemoveChild(parent, n)
+}
+
+// newPolicy builds a sanitisation policy from the configuration.
+func newPolicy(cfg config.Config) (*bluemonday.Policy, error) {
+	var p *bluemonday.Policy
+
+	switch name := cfg.GetString("richtext.policy"); name {
+	case PolicyUGC:
+		p = bluemonday.UGCPolicy()
+	case PolicyStrict:
+		p = bluemonday.StrictPolicy()
+	case PolicyNone:
+		p = bluemonday.NewPolicy()
+	default:
+		return nil, fmt.Errorf("unknown rich text policy %q", name)
+	}
+
+	if elements := cfg.GetStringSlice("richtext.allowElements"); len(elements) > 0 {
+		p.AllowElements(elements...)
+	}
+
+	// Attributes are allowed on the comma separated elements or globally if no elements are given
+	for attr, elements := range cfg.GetStringMapString("richtext.allowAttrs") {
+		var names []string
+		for _, name := range strings.Split(elements, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			p.AllowAttrs(attr).Globally()
+		} else {
+			p.AllowAttrs(attr).OnElements(names...)
+		}
+	}
+
+	if schemes := cfg.GetStringSlice("richtext.allowSchemes"); len(schemes) > 0 {
+		p.AllowURLSchemes(schemes...)
+	}
+
+	p.RequireNoFollowOnFullyQualifiedLinks(cfg.GetBool("richtext.noFollow"))
+	p.AddTargetBlankToFullyQualifiedLinks(cfg.GetBool("richtext.targetBlank"))
+
+	return p, nil
+}
+
+// New creates a new renderer. The linker may be nil, entity references are removed then.
+func New(cfg config.Config, linker Linker) (*Renderer, error) {
+	cfg.SetDefault("richtext.policy", DftPolicy)
+
+	policy, err := newPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []renderer.Option{html.WithUnsafe()}
+	if cfg.GetBool("richtext.hardWraps") {
+		options = append(options, html.WithHardWraps())
+	}
+
+	return &Renderer{
+		md:     goldmark.New(goldmark.WithExtensions(extension.GFM), goldmark.WithRendererOptions(options...)),
+		policy: policy,
+		linker: linker,
+	}, nil
+}
@@ -0,0 +1,68 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package richtext_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/permalink"
+	"ampho.xyz/core/richtext"
+)
+
+const (
+	aboutUUID   = "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"
+	missingUUID = "0b3f9c1a-7e2d-4f6a-9c8b-1d2e3f4a5b6c"
+)
+
+type linker map[string]string
+
+func (l linker) Path(_ context.Context, entityUUID string) (string, error) {
+	if p, ok := l[entityUUID]; ok {
+		return p, nil
+	}
+
+	return "", permalink.ErrNotFound
+}
+
+func TestRender(t *testing.T) {
+	r, err := richtext.New(config.NewTesting("richtext"), linker{aboutUUID: "/about"})
+	require.NoError(t, err)
+
+	for md, want := range map[string]string{
+		"# Title\n\n**bold** ~~gone~~\n":                     "<h1>Title</h1>\n<p><strong>bold</strong> <del>gone</del></p>\n",
+		"[About](entity:" + aboutUUID + "#team)":             `<p><a href="/about#team" rel="nofollow">About</a></p>` + "\n",
+		"[Missing *page*](entity:" + missingUUID + ") ok":    "<p>Missing <em>page</em> ok</p>\n",
+		"![Logo](entity:" + missingUUID + ")":                "<p></p>\n",
+		"<script>alert(1)</script><b onclick=\"x()\">hi</b>": "<b>hi</b>",
+	} {
+		html, err := r.Render(context.Background(), md)
+		require.NoError(t, err)
+		require.Equal(t, want, html, md)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	cfg := config.NewTesting("richtext")
+	cfg.Set("richtext.policy", richtext.PolicyNone)
+	cfg.Set("richtext.allowElements", []string{"p", "span"})
+	cfg.Set("richtext.allowAttrs", map[string]string{"class": "span"})
+
+	r, err := richtext.New(cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, `<p><span class="x">a</span>b</p>`,
+		r.Sanitize(`<p class="y"><span class="x">a</span><em>b</em></p>`))
+
+	html, err := r.Render(context.Background(), "[About](entity:"+aboutUUID+")")
+	require.NoError(t, err)
+	require.Equal(t, "<p>About</p>\n", html)
+
+	cfg.Set("richtext.policy", "unknown")
+	_, err = richtext.New(cfg, nil)
+	require.Error(t, err)
+}
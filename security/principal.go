@@ -0,0 +1,93 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package security
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"ampho.xyz/core/httputil"
+)
+
+type principalKey struct{}
+
+// AccessAudience is the audience of access tokens. Tokens signed with the same key for other audiences, such as
+// content preview tokens, are not access tokens.
+const AccessAudience = "ampho.access"
+
+// Claims are the claims of an access token: the subject identifies a user, roles grant permissions. The audience is
+// either AccessAudience or empty.
+type Claims struct {
+	jwt.StandardClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Principal is an authenticated user.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+// HasRole reports whether the principal has any of the roles.
+func (p *Principal) HasRole(roles ...string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, r := range roles {
+		for _, pr := range p.Roles {
+			if r == pr {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WithPrincipal returns a copy of the context with the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of a context or nil if the request is anonymous.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+
+	return p
+}
+
+// Middleware authenticates requests with a bearer access token in the Authorization header and stores the principal
+// in the request context. Requests without a token pass anonymously, requests with an invalid one or a token issued
+// for another audience are rejected with 401 status.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		const prefix = "Bearer "
+		if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+			_, _ = httputil.WriteError(w, http.StatusUnauthorized, "", nil)
+			return
+		}
+
+		claims := &Claims{}
+		token, err := ParseTokenWithClaims(auth[len(prefix):], claims)
+		if err != nil || !token.Valid || claims.Subject == "" ||
+			(claims.Audience != "" && claims.Audience != AccessAudience) {
+			_, _ = httputil.WriteError(w, http.StatusUnauthorized, "", nil)
+			return
+		}
+
+		p := &Principal{Subject: claims.Subject, Roles: claims.Roles}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
@@ -0,0 +1,67 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package security_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/security"
+)
+
+func TestMiddleware(t *testing.T) {
+	security.SetHMACKey([]byte("secret"))
+
+	var p *security.Principal
+	h := security.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p = security.PrincipalFrom(r.Context())
+	}))
+
+	do := func(auth string) int {
+		p = nil
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Anonymous
+	require.Equal(t, http.StatusOK, do(""))
+	require.Nil(t, p)
+	require.False(t, p.HasRole("editor"))
+
+	claims := &security.Claims{Roles: []string{"editor"}}
+	claims.Subject = "ann"
+	token, err := security.GetTokenSignedString(security.NewTokenWithClaims(claims))
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, do("Bearer "+token))
+	require.Equal(t, "ann", p.Subject)
+	require.True(t, p.HasRole("legal", "editor"))
+	require.False(t, p.HasRole("legal"))
+
+	claims.Audience = security.AccessAudience
+	token, err = security.GetTokenSignedString(security.NewTokenWithClaims(claims))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, do("Bearer "+token))
+	require.Equal(t, "ann", p.Subject)
+
+	// Tokens for other audiences, e.g. content preview tokens, are not access tokens
+	claims.Audience = "ampho.preview"
+	token, err = security.GetTokenSignedString(security.NewTokenWithClaims(claims))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, do("Bearer "+token))
+	require.Nil(t, p)
+
+	require.Equal(t, http.StatusUnauthorized, do("Bearer "+token+"x"))
+	require.Equal(t, http.StatusUnauthorized, do("Basic "+token))
+	require.Nil(t, p)
+}
@@ -8,10 +8,28 @@ import "time"
 
 const (
 	DftShutdownTimeout = time.Second * 15 // service shutdown timeout
-
-
+	DftPreStopDelay    = time.Duration(0) // how long a stopping service keeps serving requests being not ready
 
 	DftAddress      = "127.0.0.1:8765" // HTTP server address
 	DftReadTimeout  = time.Second * 15 // network read timeout
 	DftWriteTimeout = time.Second * 15 // network write timeout
+
+	DftHealthTimeout     = time.Second * 5 // health check timeout
+	DftHealthCacheTTL    = time.Second     // how long a health check result is reused
+	DftHealthDiskMinFree = 100 << 20       // minimum free disk space in bytes
+
+	DftMetricsPath = "/metrics" // Prometheus metrics endpoint path
+
+	DftCORSMaxAge = time.Minute * 10 // how long browsers cache preflight responses
+)
+
+var (
+	// DftCORSAllowedMethods are methods allowed in cross-origin requests.
+	DftCORSAllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+	// DftCORSAllowedHeaders are request headers allowed in cross-origin requests.
+	DftCORSAllowedHeaders = []string{"Accept", "Accept-Language", "Authorization", "Content-Type", "X-Request-ID"}
+
+	// DftCORSExposedHeaders are response headers exposed to cross-origin requests.
+	DftCORSExposedHeaders = []string{"X-Request-ID"}
 )
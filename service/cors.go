@@ -0,0 +1,244 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/httputil"
+)
+
+// cors is a CORS policy.
+type cors struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	patterns    []*regexp.Regexp
+	methods     []string
+	anyHeader   bool
+	headers     map[string]bool
+	exposed     string
+	credentials bool
+	maxAge      time.Duration
+}
+
+// allowsOrigin reports whether requests from an origin are allowed.
+func (c *cors) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if c.anyOrigin || c.origins[origin] {
+		return true
+	}
+
+	for _, re := range c.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routeMethods returns the allowed methods the route matching the path of a request serves. If no route matches the
+// path, it returns nil and the status to respond with.
+func (c *cors) routeMethods(router *mux.Router, r *http.Request) ([]string, int) {
+	var (
+		methods  []string
+		notFound bool
+	)
+
+	for _, m := range c.methods {
+		probe := r.WithContext(r.Context())
+		probe.Method = m
+
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, m)
+		} else if match.MatchErr != mux.ErrMethodMismatch {
+			notFound = true
+		}
+	}
+
+	if len(methods) > 0 {
+		return methods, 0
+	} else if notFound {
+		return nil, http.StatusNotFound
+	}
+
+	return nil, http.StatusMethodNotAllowed
+}
+
+// allowsHeaders reports whether all headers of an Access-Control-Request-Headers value are allowed.
+func (c *cors) allowsHeaders(requested string) bool {
+	if c.anyHeader {
+		return true
+	}
+
+	for _, h := range strings.Split(requested, ",") {
+		if h = strings.TrimSpace(h); h != "" && !c.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setOrigin sets the allowed origin of a response. The wildcard is used if any origin is allowed, otherwise the
+// origin is echoed and responses vary by it.
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// middleware answers preflight requests itself, since routes do not match OPTIONS requests usually, and adds CORS
+// headers to actual requests. Requests of other origins are served without CORS headers, so browsers block them.
+//
+// Preflight requests are matched against the router: paths no route serves get 404 status, and methods the route
+// does not serve get 405 status. Allowed methods are the configured methods the route serves.
+func (c *cors) middleware(next http.Handler, router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if !c.anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+
+		reqMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || reqMethod == "" {
+			if c.allowsOrigin(origin) {
+				c.setOrigin(h, origin)
+				if c.exposed != "" {
+					h.Set("Access-Control-Expose-Headers", c.exposed)
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+
+		methods, code := c.routeMethods(router, r)
+		allowsMethod := false
+		for _, m := range methods {
+			allowsMethod = allowsMethod || m == reqMethod
+		}
+		if code == 0 && !allowsMethod {
+			code = http.StatusMethodNotAllowed
+		}
+		if code != 0 {
+			_, _ = httputil.WriteError(w, code, "", nil)
+			return
+		}
+
+		reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if c.allowsOrigin(origin) && c.allowsHeaders(reqHeaders) {
+			c.setOrigin(h, origin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if c.maxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// wildcardPattern converts an origin with wildcards, e.g. https://*.example.com, to a regexp. A wildcard matches one
+// or more host labels.
+func wildcardPattern(origin string) (*regexp.Regexp, error) {
+	parts := strings.Split(regexp.QuoteMeta(origin), `\*`)
+
+	return regexp.Compile("^" + strings.Join(parts, `[a-z0-9-]+(?:\.[a-z0-9-]+)*`) + "$")
+}
+
+// newCORS creates a CORS policy configured by service.cors.* settings. It returns nil if no origins are allowed.
+//
+// Allowed origins are exact origins, * to allow any origin, origins with wildcards, e.g. https://*.example.com, and
+// regular expressions prefixed with re:, e.g. re:^https://(admin|www)\.example\.com$. Any origin may not be allowed
+// along with credentials, since it would let any site make requests on behalf of users.
+func newCORS(cfg config.Config) (*cors, error) {
+	cfg.SetDefault("service.cors.allowedOrigins", []string{})
+	cfg.SetDefault("service.cors.allowedMethods", DftCORSAllowedMethods)
+	cfg.SetDefault("service.cors.allowedHeaders", DftCORSAllowedHeaders)
+	cfg.SetDefault("service.cors.exposedHeaders", DftCORSExposedHeaders)
+	cfg.SetDefault("service.cors.allowCredentials", false)
+	cfg.SetDefault("service.cors.maxAge", DftCORSMaxAge)
+
+	origins := cfg.GetStringSlice("service.cors.allowedOrigins")
+	if len(origins) == 0 {
+		return nil, nil
+	}
+
+	c := &cors{
+		origins:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: cfg.GetBool("service.cors.allowCredentials"),
+		maxAge:      cfg.GetDuration("service.cors.maxAge"),
+	}
+
+	for _, o := range origins {
+		switch {
+		case o == "*":
+			c.anyOrigin = true
+		case strings.HasPrefix(o, "re:"):
+			re, err := regexp.Compile(strings.TrimPrefix(o, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORS origin %q: %w", o, err)
+			}
+			c.patterns = append(c.patterns, re)
+		case strings.Contains(o, "*"):
+			re, err := wildcardPattern(strings.ToLower(o))
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORS origin %q: %w", o, err)
+			}
+			c.patterns = append(c.patterns, re)
+		default:
+			c.origins[strings.ToLower(strings.TrimRight(o, "/"))] = true
+		}
+	}
+
+	if c.anyOrigin && c.credentials {
+		return nil, errors.New("CORS credentials may not be allowed for any origin")
+	}
+
+	for _, m := range cfg.GetStringSlice("service.cors.allowedMethods") {
+		c.methods = append(c.methods, strings.ToUpper(m))
+	}
+
+	for _, h := range cfg.GetStringSlice("service.cors.allowedHeaders") {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	c.exposed = strings.Join(cfg.GetStringSlice("service.cors.exposedHeaders"), ", ")
+
+	return c, nil
+}
@@ -0,0 +1,162 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/service"
+	"ampho.xyz/core/servicetest"
+)
+
+func newCORSService(t *testing.T, set func(cfg config.Config)) service.Service {
+	cfg := config.NewTesting("hello")
+	set(cfg)
+
+	svc, err := service.New(cfg)
+	require.NoError(t, err)
+
+	svc.Router().HandleFunc("/items/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}).Methods(http.MethodGet, http.MethodPut)
+
+	return svc
+}
+
+func preflight(svc service.Service, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/items/1", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+
+	return servicetest.DoRequest(svc, req)
+}
+
+func TestCORS(t *testing.T) {
+	svc := newCORSService(t, func(cfg config.Config) {
+		cfg.Set("service.cors.allowedOrigins", []string{
+			"https://admin.example.com", "https://*.example.org", `re:^https://(a|b)\.example\.net$`,
+		})
+		cfg.Set("service.cors.allowCredentials", true)
+	})
+
+	// Preflight
+	w := preflight(svc, "https://admin.example.com", http.MethodPut, "content-type, authorization")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "content-type, authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	require.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	for _, origin := range []string{"https://www.example.org", "https://a.b.example.org", "https://b.example.net"} {
+		w = preflight(svc, origin, http.MethodGet, "")
+		require.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+
+	// Not allowed
+	for _, origin := range []string{"https://evil.com", "https://example.org", "https://c.example.net",
+		"https://evil.com/.example.org"} {
+		w = preflight(svc, origin, http.MethodGet, "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+	w = preflight(svc, "https://admin.example.com", "TRACE", "")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = preflight(svc, "https://admin.example.com", http.MethodGet, "X-Secret")
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Actual request
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+
+	req.Header.Set("Origin", "https://evil.com")
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Plain OPTIONS requests are routed as usual
+	w = servicetest.DoRequest(svc, httptest.NewRequest(http.MethodOptions, "/items/1", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	svc := newCORSService(t, func(cfg config.Config) {
+		cfg.Set("service.cors.allowedOrigins", []string{"*"})
+		cfg.Set("service.cors.allowedHeaders", []string{"*"})
+		cfg.Set("service.cors.maxAge", 0)
+	})
+
+	w := preflight(svc, "https://any.com", http.MethodPut, "X-Anything")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "X-Anything", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+	require.NotContains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORSRoutes(t *testing.T) {
+	svc := newCORSService(t, func(cfg config.Config) {
+		cfg.Set("service.cors.allowedOrigins", []string{"*"})
+	})
+	svc.Router().HandleFunc("/any", func(w http.ResponseWriter, r *http.Request) {})
+
+	// The route does not serve the method
+	w := preflight(svc, "https://any.com", http.MethodDelete, "")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// No route serves the path
+	req := httptest.NewRequest(http.MethodOptions, "/missing", nil)
+	req.Header.Set("Origin", "https://any.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// A route serving any method is allowed the configured ones
+	req.URL.Path = "/any"
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORSDisabled(t *testing.T) {
+	svc := newCORSService(t, func(cfg config.Config) {})
+
+	w := preflight(svc, "https://admin.example.com", http.MethodGet, "")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	_, err := service.New(func() config.Config {
+		cfg := config.NewTesting("hello")
+		cfg.Set("service.cors.allowedOrigins", []string{"re:("})
+		return cfg
+	}())
+	require.Error(t, err)
+}
+
+func TestCORSAnyOriginCredentials(t *testing.T) {
+	cfg := config.NewTesting("hello")
+	cfg.Set("service.cors.allowedOrigins", []string{"https://admin.example.com", "*"})
+	cfg.Set("service.cors.allowCredentials", true)
+
+	_, err := service.New(cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,33 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+//go:build !windows
+// +build !windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// DiskCheck returns a health check which fails if the file system containing the path has less than minFree bytes
+// available to unprivileged users.
+func DiskCheck(path string, minFree uint64) CheckFunc {
+	return func(_ context.Context) error {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return err
+		}
+
+		// Field types differ between platforms
+		free := uint64(st.Bavail) * uint64(st.Bsize)
+		if free < minFree {
+			return fmt.Errorf("%d bytes free on %s, %d required", free, path, minFree)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,17 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// DiskCheck returns a health check of free disk space, which is not supported on Windows: the check always fails.
+func DiskCheck(path string, minFree uint64) CheckFunc {
+	return func(_ context.Context) error {
+		return errors.New("disk check is not supported on windows")
+	}
+}
@@ -38,5 +38,7 @@ func Example_extend() {
 	// ...
 
 	// Run the service until SIGINT
-	svc.Run()
+	if err = svc.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -31,7 +31,9 @@ func Example_newDefault() {
 	// ...
 
 	// Run the service until SIGINT
-	svc.Run()
+	if err = svc.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // This example shows how to instantiate and use a service using testing configuration.
@@ -0,0 +1,271 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"ampho.xyz/core/httputil"
+)
+
+// Health statuses.
+const (
+	HealthOK          = "ok"          // all checks pass
+	HealthDegraded    = "degraded"    // some non-critical checks fail
+	HealthUnavailable = "unavailable" // some critical checks fail or the service is not ready
+	HealthFail        = "fail"        // a check fails
+	HealthUnknown     = "unknown"     // a check has not completed before the caller gave up waiting
+)
+
+// CheckFunc checks a dependency of a service, e.g. database.Replica.Ping. It returns an error if the dependency is
+// unhealthy.
+type CheckFunc func(ctx context.Context) error
+
+// CheckOption configures a health check.
+type CheckOption func(c *healthCheck)
+
+// NonCritical makes a health check non-critical: its failure degrades the service health, but the service stays
+// ready.
+func NonCritical() CheckOption {
+	return func(c *healthCheck) {
+		c.critical = false
+	}
+}
+
+// CheckTimeout sets the time a health check may take, service.health.timeout by default.
+func CheckTimeout(d time.Duration) CheckOption {
+	return func(c *healthCheck) {
+		c.timeout = d
+	}
+}
+
+// CheckCacheTTL sets how long a health check result is reused, service.health.cacheTTL by default.
+func CheckCacheTTL(d time.Duration) CheckOption {
+	return func(c *healthCheck) {
+		c.cacheTTL = d
+	}
+}
+
+// CheckResult is a result of a health check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// HealthReport is a result of all health checks of a service.
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Ready  *bool                   `json:"ready,omitempty"`
+	Checks map[string]*CheckResult `json:"checks"`
+}
+
+type healthCheck struct {
+	name     string
+	fn       CheckFunc
+	critical bool
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	result  *CheckResult
+	running chan struct{} // closed when the running check completes, nil if the check is not running
+}
+
+// run returns the cached result of the check or runs the check if the result is outdated. Concurrent callers wait
+// for a single run. The check runs detached from the caller context, so its result does not depend on a caller
+// giving up: such a caller gets an unknown result, which is not cached.
+func (c *healthCheck) run(ctx context.Context) *CheckResult {
+	start := time.Now()
+
+	c.mu.Lock()
+	if c.result != nil && time.Since(c.result.CheckedAt) < c.cacheTTL {
+		r := c.result
+		c.mu.Unlock()
+		return r
+	}
+
+	done := c.running
+	if done == nil {
+		done = make(chan struct{})
+		c.running = done
+		go c.refresh(done)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.result
+	case <-ctx.Done():
+		return &CheckResult{
+			Status:    HealthUnknown,
+			Critical:  c.critical,
+			Error:     fmt.Sprintf("not completed: %v", ctx.Err()),
+			Duration:  time.Since(start).String(),
+			CheckedAt: start,
+		}
+	}
+}
+
+// refresh runs the check within its timeout, caches the result and closes done.
+func (c *healthCheck) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.call(ctx)
+
+	r := &CheckResult{
+		Status:    HealthOK,
+		Critical:  c.critical,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		r.Status, r.Error = HealthFail, err.Error()
+	}
+
+	c.mu.Lock()
+	c.result, c.running = r, nil
+	c.mu.Unlock()
+	close(done)
+}
+
+// call calls the check function, returning when the context is done even if the function does not.
+func (c *healthCheck) call(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", c.timeout)
+	}
+}
+
+// health is a registry of health checks.
+type health struct {
+	mu       sync.RWMutex
+	checks   []*healthCheck
+	timeout  time.Duration
+	cacheTTL time.Duration
+}
+
+func (h *health) add(name string, fn CheckFunc, opts ...CheckOption) {
+	c := &healthCheck{name: name, fn: fn, critical: true, timeout: h.timeout, cacheTTL: h.cacheTTL}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, other := range h.checks {
+		if other.name == name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+	sort.Slice(h.checks, func(i, j int) bool { return h.checks[i].name < h.checks[j].name })
+}
+
+// report runs all checks concurrently.
+func (h *health) report(ctx context.Context) *HealthReport {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	results := make([]*CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	rep := &HealthReport{Status: HealthOK, Checks: make(map[string]*CheckResult, len(checks))}
+	for i, c := range checks {
+		rep.Checks[c.name] = results[i]
+
+		if results[i].Status == HealthOK {
+			continue
+		}
+		if c.critical {
+			rep.Status = HealthUnavailable
+		} else if rep.Status == HealthOK {
+			rep.Status = HealthDegraded
+		}
+	}
+
+	return rep
+}
+
+func writeReport(w http.ResponseWriter, rep *HealthReport) {
+	code := http.StatusOK
+	if rep.Status == HealthUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = httputil.WriteJSONStatus(w, code, rep)
+}
+
+// AddHealthCheck registers a critical health check, unless NonCritical is given. A check with the same name is
+// replaced.
+func (s *Base) AddHealthCheck(name string, fn CheckFunc, opts ...CheckOption) {
+	s.health.add(name, fn, opts...)
+}
+
+// Health runs all health checks, reusing cached results.
+func (s *Base) Health(ctx context.Context) *HealthReport {
+	return s.health.report(ctx)
+}
+
+// serveHealth reports the results of all health checks. It responds with 503 status if a critical check fails.
+func (s *Base) serveHealth(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, s.Health(r.Context()))
+}
+
+// serveLive reports the service process is alive. It does not run health checks, since restarting the service does
+// not fix its dependencies.
+func (s *Base) serveLive(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, &HealthReport{Status: HealthOK, Checks: map[string]*CheckResult{}})
+}
+
+// serveReady reports whether the service is ready to serve requests: it is started and not stopping, see Ready, and
+// all critical health checks pass. Otherwise it responds with 503 status.
+func (s *Base) serveReady(w http.ResponseWriter, r *http.Request) {
+	rep := s.Health(r.Context())
+
+	ready := s.Ready()
+	rep.Ready = &ready
+	if !ready {
+		rep.Status = HealthUnavailable
+	}
+
+	writeReport(w, rep)
+}
@@ -0,0 +1,165 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/service"
+	"ampho.xyz/core/servicetest"
+)
+
+func getReport(t *testing.T, svc service.Service, path string) (int, *service.HealthReport) {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err)
+
+	w := servicetest.DoRequest(svc, req)
+
+	rep := &service.HealthReport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), rep))
+
+	return w.Code, rep
+}
+
+func TestHealth(t *testing.T) {
+	svc := newService(t, "")
+
+	code, rep := getReport(t, svc, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, service.HealthOK, rep.Status)
+	require.Empty(t, rep.Checks)
+
+	svc.AddHealthCheck("cache", func(context.Context) error { return errors.New("down") }, service.NonCritical())
+	svc.AddHealthCheck("db", func(context.Context) error { return nil })
+
+	code, rep = getReport(t, svc, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, service.HealthDegraded, rep.Status)
+	require.Equal(t, service.HealthOK, rep.Checks["db"].Status)
+	require.True(t, rep.Checks["db"].Critical)
+	require.Equal(t, service.HealthFail, rep.Checks["cache"].Status)
+	require.Equal(t, "down", rep.Checks["cache"].Error)
+	require.False(t, rep.Checks["cache"].Critical)
+
+	// Replaced by name
+	svc.AddHealthCheck("db", func(context.Context) error { return errors.New("refused") })
+
+	code, rep = getReport(t, svc, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, service.HealthUnavailable, rep.Status)
+	require.Len(t, rep.Checks, 2)
+	require.Equal(t, "refused", rep.Checks["db"].Error)
+}
+
+func TestHealthTimeout(t *testing.T) {
+	svc := newService(t, "")
+
+	svc.AddHealthCheck("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, service.CheckTimeout(10*time.Millisecond))
+	svc.AddHealthCheck("panic", func(ctx context.Context) error {
+		panic("oops")
+	})
+
+	start := time.Now()
+	rep := svc.Health(context.Background())
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+	require.Equal(t, service.HealthUnavailable, rep.Status)
+	require.Contains(t, rep.Checks["slow"].Error, "timed out")
+	require.Contains(t, rep.Checks["panic"].Error, "oops")
+}
+
+func TestHealthCancelled(t *testing.T) {
+	svc := newService(t, "")
+
+	var calls int32
+	release := make(chan struct{})
+	svc.AddHealthCheck("slow", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, service.CheckCacheTTL(time.Hour))
+
+	// A caller giving up is not a check failure
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	rep := svc.Health(ctx)
+	require.Equal(t, service.HealthUnavailable, rep.Status)
+	require.Equal(t, service.HealthUnknown, rep.Checks["slow"].Status)
+	require.NotContains(t, rep.Checks["slow"].Error, "timed out")
+
+	// The check goes on and its result is cached for later callers
+	close(release)
+	rep = svc.Health(context.Background())
+	require.Equal(t, service.HealthOK, rep.Status)
+	rep = svc.Health(context.Background())
+	require.Equal(t, service.HealthOK, rep.Status)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHealthCache(t *testing.T) {
+	svc := newService(t, "")
+
+	var calls int32
+	svc.AddHealthCheck("counted", func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, service.CheckCacheTTL(time.Hour))
+	svc.AddHealthCheck("uncached", func(context.Context) error { return nil }, service.CheckCacheTTL(0))
+
+	first := svc.Health(context.Background())
+	second := svc.Health(context.Background())
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Equal(t, first.Checks["counted"].CheckedAt, second.Checks["counted"].CheckedAt)
+	require.NotEqual(t, first.Checks["uncached"].CheckedAt, second.Checks["uncached"].CheckedAt)
+}
+
+func TestLiveReady(t *testing.T) {
+	svc := newService(t, "")
+	svc.AddHealthCheck("db", func(context.Context) error { return errors.New("refused") })
+	svc.AddHealthCheck("cache", func(context.Context) error { return nil }, service.NonCritical())
+
+	code, rep := getReport(t, svc, "/livez")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, service.HealthOK, rep.Status)
+
+	// Not started
+	code, rep = getReport(t, svc, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, *rep.Ready)
+
+	// Critical check fails
+	svc.SetReady(true)
+	code, rep = getReport(t, svc, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.True(t, *rep.Ready)
+
+	svc.AddHealthCheck("db", func(context.Context) error { return nil })
+	code, rep = getReport(t, svc, "/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, service.HealthOK, rep.Status)
+}
+
+func TestDiskCheck(t *testing.T) {
+	if err := service.DiskCheck(t.TempDir(), 0)(context.Background()); err != nil {
+		t.Skip(err)
+	}
+
+	require.Error(t, service.DiskCheck(t.TempDir(), 1<<62)(context.Background()))
+	require.Error(t, service.DiskCheck("/nonexistent/path", 0)(context.Background()))
+}
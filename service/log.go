@@ -0,0 +1,60 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/requestid"
+)
+
+// logRequests stores the request logger, which adds the request and trace IDs to records, in the request context, see
+// logger.FromContext, and writes an access log record after the request is served if enabled. Requests failed with
+// 5xx status are logged as errors.
+func (s *Base) logRequests(next http.Handler, accessLog, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := s.logger
+		if id := requestid.FromContext(r.Context()); id != "" {
+			l = l.With("requestId", id)
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			l = l.With("traceId", sc.TraceID().String())
+		}
+		r = r.WithContext(logger.WithContext(r.Context(), l))
+
+		if !accessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := httputil.NewStatusWriter(w)
+		start := time.Now()
+
+		next.ServeHTTP(sw, r)
+
+		status := sw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := logger.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = logger.LevelError
+		}
+
+		l.Log(level, "request",
+			"method", r.Method,
+			"uri", r.RequestURI,
+			"status", status,
+			"bytes", sw.Bytes(),
+			"duration", time.Since(start),
+			"remoteIp", httputil.RemoteIP(r, trustProxy),
+		)
+	})
+}
@@ -0,0 +1,85 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/service"
+	"ampho.xyz/core/servicetest"
+)
+
+func TestAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	cfg := config.NewTesting("hello")
+	cfg.Set("log.format", "json")
+	cfg.Set("log.output", path)
+
+	svc, err := service.New(cfg)
+	require.NoError(t, err)
+
+	svc.Router().HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("greeting", "name", "world")
+		_, _ = w.Write([]byte("hello, world"))
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/hello?x=1", nil)
+	require.NoError(t, err)
+	req.RequestURI = "/hello?x=1"
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Request-ID", "abc")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "abc", w.Header().Get("X-Request-ID"))
+
+	// Not matching any route
+	req, err = http.NewRequest(http.MethodGet, "/missing", nil)
+	require.NoError(t, err)
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	generated := w.Header().Get("X-Request-ID")
+	require.NotEmpty(t, generated)
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 3)
+
+	var recs []map[string]interface{}
+	for _, line := range lines {
+		rec := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+
+	require.Equal(t, "greeting", recs[0]["msg"])
+	require.Equal(t, "abc", recs[0]["requestId"])
+
+	require.Equal(t, "request", recs[1]["msg"])
+	require.Equal(t, "info", recs[1]["level"])
+	require.Equal(t, "abc", recs[1]["requestId"])
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", recs[1]["traceId"])
+	require.Equal(t, "GET", recs[1]["method"])
+	require.Equal(t, "/hello?x=1", recs[1]["uri"])
+	require.Equal(t, float64(200), recs[1]["status"])
+	require.Equal(t, float64(12), recs[1]["bytes"])
+	require.Equal(t, "192.0.2.1", recs[1]["remoteIp"])
+	require.NotEmpty(t, recs[1]["duration"])
+
+	require.Equal(t, float64(404), recs[2]["status"])
+	require.Equal(t, generated, recs[2]["requestId"])
+}
@@ -0,0 +1,130 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ampho.xyz/core/httputil"
+)
+
+// unmatchedRoute is the route label of requests not matching any route, so they are counted without adding series
+// per path.
+const unmatchedRoute = "unmatched"
+
+type routeKey struct{}
+
+// metrics holds HTTP metrics of a service.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// Metrics returns the Prometheus registry of the service metrics, so other components can register their collectors.
+// The collector of the service database is registered already, see WithDatabase.
+func (s *Base) Metrics() *prometheus.Registry {
+	return s.metrics.registry
+}
+
+// middleware counts requests and measures their latency by route template, method and status. The route template is
+// used instead of the path to keep the number of series bounded. It wraps the router, so requests not matching any
+// route are counted as well, see routeMiddleware. Requests of panicking handlers are counted with 500 status, as
+// recoverPanics responds to them outside.
+func (m *metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, &route))
+
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		sw := httputil.NewStatusWriter(w)
+		start := time.Now()
+		panicked := true
+
+		defer func() {
+			status := sw.Status()
+			if panicked {
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				status = http.StatusOK
+			}
+			labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+
+			m.requests.With(labels).Inc()
+			m.duration.With(labels).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(sw, r)
+		panicked = false
+	})
+}
+
+// routeMiddleware passes the route template matched by the router to middleware. It is a router middleware, since the
+// route is known after matching only.
+func routeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if cr := mux.CurrentRoute(r); cr != nil {
+				if tpl, err := cr.GetPathTemplate(); err == nil {
+					*route = tpl
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newMetrics(namespace string) *metrics {
+	labels := []string{"route", "method", "status"}
+
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests served.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests.",
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,98 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/service"
+	"ampho.xyz/core/servicetest"
+)
+
+func TestMetrics(t *testing.T) {
+	svc := newService(t, "")
+	svc.Router().HandleFunc("/hello/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, name := range []string{"alice", "bob"} {
+		req, err := http.NewRequest(http.MethodPost, "/hello/"+name, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, servicetest.DoRequest(svc, req).Code)
+	}
+
+	svc.Router().HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	req, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, servicetest.DoRequest(svc, req).Code)
+
+	// Requests not matching any route do not add series per path
+	for _, path := range []string{"/missing/1", "/missing/2"} {
+		req, err = http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, servicetest.DoRequest(svc, req).Code)
+	}
+	svc.Router().HandleFunc("/posts", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+	req, err = http.NewRequest(http.MethodGet, "/posts", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, servicetest.DoRequest(svc, req).Code)
+
+	req, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	w := servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	require.Contains(t, body, `http_requests_total{method="POST",route="/hello/{name}",status="201"} 2`)
+	require.Contains(t, body, `http_request_duration_seconds_count{method="POST",route="/hello/{name}",status="201"} 2`)
+	require.Contains(t, body, `http_requests_total{method="GET",route="/panic",status="500"} 1`)
+	require.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 2`)
+	require.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="405"} 1`)
+	require.Contains(t, body, "http_requests_in_flight 1")
+	require.Contains(t, body, "go_goroutines")
+}
+
+func TestMetricsConfig(t *testing.T) {
+	cfg := config.NewTesting("hello")
+	cfg.Set("service.metrics.path", "/internal/metrics")
+	cfg.Set("service.metrics.namespace", "ampho")
+
+	svc, err := service.New(cfg)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/internal/metrics", nil)
+	require.NoError(t, err)
+	w := servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "ampho_http_requests_in_flight 1")
+
+	cfg = config.NewTesting("hello")
+	cfg.Set("service.metrics.enabled", false)
+
+	svc, err = service.New(cfg)
+	require.NoError(t, err)
+
+	req, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, servicetest.DoRequest(svc, req).Code)
+}
+
+func TestMetricsDatabase(t *testing.T) {
+	svc, err := service.New(config.NewTesting("hello"), service.WithDatabase(databasetest.New(t)))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	w := servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `database_pool_max_connections{replica="rw.0"}`)
+}
@@ -0,0 +1,102 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/requestid"
+)
+
+// ErrorReporter reports an error, e.g. to an error tracking service. Panics recovered while serving requests are
+// reported as PanicError. It is called synchronously, so slow reporters are expected to send reports in the
+// background.
+type ErrorReporter func(ctx context.Context, err error)
+
+// PanicError is a panic recovered while serving a request.
+type PanicError struct {
+	Value interface{} // value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+
+	return err
+}
+
+// AddErrorReporter registers a function reporting errors, e.g. panics recovered while serving requests.
+func (s *Base) AddErrorReporter(fn ErrorReporter) {
+	s.reporters = append(s.reporters, fn)
+}
+
+// ReportError reports an error to all registered reporters. A panicking reporter does not prevent others from
+// reporting.
+func (s *Base) ReportError(ctx context.Context, err error) {
+	for _, fn := range s.reporters {
+		func() {
+			defer func() {
+				if v := recover(); v != nil {
+					logger.FromContext(ctx).Error("error reporter panicked", "error", fmt.Sprint(v))
+				}
+			}()
+			fn(ctx, err)
+		}()
+	}
+}
+
+// recoverPanics recovers from panics of handlers: logs the stack with the request logger, responds with a JSON 500
+// error unless the response has been started and reports the panic, see AddErrorReporter. http.ErrAbortHandler is
+// re-panicked, since it is used to abort responses on purpose.
+func (s *Base) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := httputil.NewStatusWriter(w)
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			ctx := r.Context()
+			err := &PanicError{Value: v, Stack: debug.Stack()}
+
+			logger.FromContext(ctx).Error("handler panicked", "error", err, "stack", string(err.Stack))
+
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			if sw.Status() == 0 {
+				var details interface{}
+				if id := requestid.FromContext(ctx); id != "" {
+					details = map[string]string{"requestId": id}
+				}
+				_, _ = httputil.WriteError(sw, http.StatusInternalServerError, "", details)
+			}
+
+			s.ReportError(ctx, err)
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
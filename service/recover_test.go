@@ -0,0 +1,103 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/service"
+	"ampho.xyz/core/servicetest"
+)
+
+var errBoom = errors.New("boom")
+
+func TestRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+
+	cfg := config.NewTesting("hello")
+	cfg.Set("log.format", "json")
+	cfg.Set("log.output", path)
+
+	svc, err := service.New(cfg)
+	require.NoError(t, err)
+
+	var reported []error
+	svc.AddErrorReporter(func(ctx context.Context, err error) {
+		panic("broken reporter")
+	})
+	svc.AddErrorReporter(func(ctx context.Context, err error) {
+		reported = append(reported, err)
+	})
+
+	svc.Router().HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic(errBoom)
+	})
+	svc.Router().HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	})
+	svc.Router().HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "abc")
+
+	w := servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	resp := httputil.ErrorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "Internal Server Error", resp.Error)
+	require.Equal(t, map[string]interface{}{"requestId": "abc"}, resp.Details)
+
+	require.Len(t, reported, 1)
+	require.True(t, errors.Is(reported[0], errBoom))
+	pe := &service.PanicError{}
+	require.True(t, errors.As(reported[0], &pe))
+	require.Contains(t, string(pe.Stack), "recover_test.go")
+
+	// The response has been started
+	req, err = http.NewRequest(http.MethodGet, "/late", nil)
+	require.NoError(t, err)
+	w = servicetest.DoRequest(svc, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Len(t, reported, 2)
+	require.Equal(t, "panic: late", reported[1].Error())
+
+	// Aborted on purpose
+	req, err = http.NewRequest(http.MethodGet, "/abort", nil)
+	require.NoError(t, err)
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() { servicetest.DoRequest(svc, req) })
+	require.Len(t, reported, 2)
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	rec := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(string(b), "\n", 2)[0]), &rec))
+	require.Equal(t, "handler panicked", rec["msg"])
+	require.Equal(t, "error", rec["level"])
+	require.Equal(t, "abc", rec["requestId"])
+	require.Equal(t, "panic: boom", rec["error"])
+	require.Contains(t, rec["stack"], "runtime/debug.Stack")
+
+	require.Contains(t, string(b), `"msg":"error reporter panicked"`)
+	require.Contains(t, string(b), `"status":500`)
+}
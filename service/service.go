@@ -6,16 +6,32 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/requestid"
+	"ampho.xyz/core/tracing"
 )
 
+// ErrForcedStop is returned by Run when a second stop signal interrupts graceful stopping.
+var ErrForcedStop = errors.New("service stop forced")
+
+// Hook is a function called at a phase of a service lifecycle.
+type Hook func(ctx context.Context, svc Service) error
+
 // Service is the service interface.
 type Service interface {
 	// Config returns configuration.
@@ -24,31 +40,80 @@ type Service interface {
 	// Router returns router.
 	Router() *mux.Router
 
+	// Logger returns logger.
+	Logger() *logger.Logger
+
 	// Server returns HTTP server.
 	Server() *http.Server
 
-	// BeforeStart schedules a function to be called before service start.
-	BeforeStart(fn func(svc Service))
+	// BeforeStart schedules a function to be called before service start. If it fails, the service does not start.
+	BeforeStart(fn Hook)
+
+	// AfterStart schedules a function to be called after the service starts listening. If it fails, the service
+	// stops.
+	AfterStart(fn Hook)
+
+	// BeforeStop schedules a function to be called before service stop, while requests are still served.
+	BeforeStop(fn Hook)
+
+	// AfterStop schedules a function to be called after service stop, or when the service fails to start after the
+	// BeforeStart hooks scheduled before it have been called.
+	AfterStop(fn Hook)
+
+	// OnReload schedules a function to be called when the service is asked to reload, e.g. by SIGHUP.
+	OnReload(fn Hook)
+
+	// Ready reports whether the service is ready to serve requests.
+	Ready() bool
+
+	// SetReady marks the service ready or not ready to serve requests.
+	SetReady(ready bool)
+
+	// Reload calls the functions scheduled by OnReload.
+	Reload(ctx context.Context) error
 
-	// AfterStop schedules a function to be called after service stop.
-	AfterStop(fn func(svc Service))
+	// AddHealthCheck registers a health check reported by the /healthz and /readyz endpoints.
+	AddHealthCheck(name string, fn CheckFunc, opts ...CheckOption)
 
-	// Start starts the service. Assumed to be called in a goroutine.
-	Start()
+	// Health runs all health checks.
+	Health(ctx context.Context) *HealthReport
 
-	// Stop stops the service.
-	Stop()
+	// Metrics returns the Prometheus registry of the service metrics.
+	Metrics() *prometheus.Registry
 
-	// Run starts the service and blocks until SIGINT received. Usually it should be a last call in the `main()`.
-	Run()
+	// AddErrorReporter registers a function reporting errors, e.g. panics recovered while serving requests.
+	AddErrorReporter(fn ErrorReporter)
+
+	// ReportError reports an error to all registered reporters.
+	ReportError(ctx context.Context, err error)
+
+	// Start starts the service and serves requests until it is stopped. Assumed to be called in a goroutine.
+	Start(ctx context.Context) error
+
+	// Stop stops the service. The context limits the time to finish serving active requests.
+	Stop(ctx context.Context) error
+
+	// Run starts the service and blocks until SIGINT or SIGTERM received. Usually it should be a last call in the
+	// `main()`.
+	Run() error
 }
 
 // Base is the base service structure.
 type Base struct {
 	config      config.Config
 	server      *http.Server
-	beforeStart []func(svc Service)
-	afterStop   []func(svc Service)
+	beforeStart []Hook
+	afterStart  []Hook
+	beforeStop  []Hook
+	afterStop   []Hook
+	stopMarks   []int // number of BeforeStart hooks scheduled before each AfterStop hook
+	router      *mux.Router
+	logger      *logger.Logger
+	reload      []Hook
+	ready       int32
+	health      *health
+	metrics     *metrics
+	reporters   []ErrorReporter
 }
 
 // Config returns configuration.
@@ -58,7 +123,12 @@ func (s *Base) Config() config.Config {
 
 // Router returns router.
 func (s *Base) Router() *mux.Router {
-	return s.server.Handler.(*mux.Router)
+	return s.router
+}
+
+// Logger returns logger.
+func (s *Base) Logger() *logger.Logger {
+	return s.logger
 }
 
 // Server returns HTTP server.
@@ -66,78 +136,335 @@ func (s *Base) Server() *http.Server {
 	return s.server
 }
 
-// BeforeStart schedules a function to be called before service start.
-func (s *Base) BeforeStart(fn func(svc Service)) {
+// BeforeStart schedules a function to be called before service start. If it fails, the service does not start.
+func (s *Base) BeforeStart(fn Hook) {
 	s.beforeStart = append(s.beforeStart, fn)
 }
 
-// AfterStop schedules a function to be called after service stop.
-func (s *Base) AfterStop(fn func(svc Service)) {
+// AfterStart schedules a function to be called after the service starts listening. If it fails, the service stops.
+func (s *Base) AfterStart(fn Hook) {
+	s.afterStart = append(s.afterStart, fn)
+}
+
+// BeforeStop schedules a function to be called before service stop, while requests are still served.
+func (s *Base) BeforeStop(fn Hook) {
+	s.beforeStop = append(s.beforeStop, fn)
+}
+
+// AfterStop schedules a function to be called after service stop, or when the service fails to start after the
+// BeforeStart hooks scheduled before it have been called, see Start.
+func (s *Base) AfterStop(fn Hook) {
 	s.afterStop = append(s.afterStop, fn)
+	s.stopMarks = append(s.stopMarks, len(s.beforeStart))
+}
+
+// OnReload schedules a function to be called when the service is asked to reload, e.g. by SIGHUP.
+func (s *Base) OnReload(fn Hook) {
+	s.reload = append(s.reload, fn)
+}
+
+// Ready reports whether the service is ready to serve requests. The service is ready after it starts and until it
+// starts stopping.
+func (s *Base) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// SetReady marks the service ready or not ready to serve requests.
+func (s *Base) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+
+	atomic.StoreInt32(&s.ready, v)
+}
+
+// Reload calls the functions scheduled by OnReload. All of them are called even if some fail, the first error is
+// returned and the others are logged.
+func (s *Base) Reload(ctx context.Context) error {
+	return s.runHooks(ctx, "reload", s.reload)
+}
+
+// Start starts the service and serves requests until it is stopped, then it returns nil. Assumed to be called in a
+// goroutine.
+//
+// BeforeStart hooks are called first. If one of them fails, the rest are not called and the error is returned. The
+// resources acquired by the preceding ones are released: AfterStop hooks scheduled before the failed hook are called
+// in reverse order, so a component scheduling a BeforeStart hook along with an AfterStop one is unwound only if it
+// has started. If the service fails to listen, all AfterStop hooks are called the same way. If an AfterStart hook
+// fails, the service is stopped, see Stop, and the error is returned.
+func (s *Base) Start(ctx context.Context) error {
+	for i, fn := range s.beforeStart {
+		if err := fn(ctx, s); err != nil {
+			s.abort(ctx, i)
+			return fmt.Errorf("before start: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		s.abort(ctx, len(s.beforeStart))
+		return err
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.server.Serve(ln)
+	}()
+
+	for _, fn := range s.afterStart {
+		if err = fn(ctx, s); err != nil {
+			if stopErr := s.Stop(ctx); stopErr != nil {
+				s.logger.Error("failed to stop service", "error", stopErr)
+			}
+			<-served
+			return fmt.Errorf("after start: %w", err)
+		}
+	}
+	s.SetReady(true)
+
+	if err = <-served; err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
 }
 
-// Start starts the service. Assumed to be called in a goroutine.
-func (s *Base) Start() {
-	for _, fn := range s.beforeStart {
-		fn(s)
+// Stop stops the service: calls BeforeStop hooks, shuts the server down waiting for active requests until the
+// context is done and calls AfterStop hooks. All hooks are called even if some of them fail, the first error is
+// returned and the others are logged.
+func (s *Base) Stop(ctx context.Context) error {
+	s.SetReady(false)
+
+	err := s.runHooks(ctx, "before stop", s.beforeStop)
+
+	if shutdownErr := s.server.Shutdown(ctx); shutdownErr != nil {
+		if err == nil {
+			err = shutdownErr
+		} else {
+			s.logger.Error("failed to shut server down", "error", shutdownErr)
+		}
+	}
+
+	if hookErr := s.runHooks(ctx, "after stop", s.afterStop); err == nil {
+		err = hookErr
 	}
 
-	err := s.server.ListenAndServe()
-	if err != http.ErrServerClosed {
-		log.Fatal(err)
+	return err
+}
+
+// Run starts the service and blocks until SIGINT or SIGTERM received, then stops it gracefully. Usually it should be
+// a last call in the `main()`. It returns an error if the service fails to start or to stop.
+//
+// On the first stop signal the service is marked not ready, so load balancers stop routing requests to it, but keeps
+// serving requests for the service.preStopDelay. Then it is stopped, waiting for active requests to finish within the
+// service.shutdownTimeout, see Stop. A second stop signal aborts waiting, Run returns ErrForcedStop immediately.
+// SIGHUP reloads the service, see Reload.
+func (s *Base) Run() error {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(c)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- s.Start(context.Background())
+	}()
+
+	for {
+		select {
+		case err := <-started:
+			return err
+		case sig := <-c:
+			if sig == syscall.SIGHUP {
+				if err := s.Reload(context.Background()); err != nil {
+					s.logger.Error("failed to reload service", "error", err)
+				}
+				continue
+			}
+
+			s.logger.Info("stopping service", "signal", sig)
+			return s.drain(started, c)
+		}
 	}
 }
 
-// Stop stops the service.
-func (s *Base) Stop() {
-	ctx, cancel := context.WithTimeout(context.Background(), s.Config().GetDuration("service.shutdownTimeout"))
+// drain stops the service started by Run unless a second stop signal comes.
+func (s *Base) drain(started <-chan error, c <-chan os.Signal) error {
+	s.SetReady(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
-		log.Panic(err.Error())
+	stopped := make(chan error, 1)
+	go func() {
+		if d := s.config.GetDuration("service.preStopDelay"); d > 0 {
+			t := time.NewTimer(d)
+			defer t.Stop()
+
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		stopCtx, stopCancel := context.WithTimeout(ctx, s.config.GetDuration("service.shutdownTimeout"))
+		defer stopCancel()
+
+		err := s.Stop(stopCtx)
+		if startErr := <-started; err == nil {
+			err = startErr
+		}
+		stopped <- err
+	}()
+
+	for {
+		select {
+		case err := <-stopped:
+			return err
+		case sig := <-c:
+			if sig == syscall.SIGHUP {
+				continue
+			}
+
+			s.logger.Warn("forcing service stop", "signal", sig)
+			return ErrForcedStop
+		}
 	}
+}
 
-	for _, fn := range s.afterStop {
-		fn(s)
+// abort calls AfterStop hooks scheduled before the BeforeStart hook with the index, the latest first, when the
+// service fails to start.
+func (s *Base) abort(ctx context.Context, started int) {
+	var hooks []Hook
+	for i := len(s.afterStop) - 1; i >= 0; i-- {
+		if s.stopMarks[i] <= started {
+			hooks = append(hooks, s.afterStop[i])
+		}
+	}
+
+	if err := s.runHooks(ctx, "after stop", hooks); err != nil {
+		s.logger.Error("failed to release resources", "error", err)
 	}
 }
 
-// Run starts the service and blocks until SIGINT received. Usually it should be a last call in the `main()`.
-func (s *Base) Run() {
-	go s.Start()
+// runHooks calls all hooks of a phase. It returns the first error, the others are logged.
+func (s *Base) runHooks(ctx context.Context, phase string, hooks []Hook) error {
+	var first error
+
+	for _, fn := range hooks {
+		err := fn(ctx, s)
+		if err == nil {
+			continue
+		}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+		err = fmt.Errorf("%s: %w", phase, err)
+		if first == nil {
+			first = err
+		} else {
+			s.logger.Error("hook failed", "error", err)
+		}
+	}
+
+	return first
+}
 
-	s.Stop()
+// Option configures a service.
+type Option func(s *Base)
+
+// WithDatabase registers the connection pool metrics of a database, see database.Database.Collector.
+func WithDatabase(db *database.Database) Option {
+	return func(s *Base) {
+		s.metrics.registry.MustRegister(db.Collector())
+	}
 }
 
-// New creates a new base service instance using default configuration.
-func New(cfg config.Config) (*Base, error) {
+// New creates a new base service instance using default configuration and the options.
+func New(cfg config.Config, opts ...Option) (*Base, error) {
 	cfg.SetDefault("service.address", DftAddress)
 	cfg.SetDefault("service.readTimeout", DftReadTimeout)
 	cfg.SetDefault("service.writeTimeout", DftWriteTimeout)
 	cfg.SetDefault("service.shutdownTimeout", DftShutdownTimeout)
+	cfg.SetDefault("service.preStopDelay", DftPreStopDelay)
+	cfg.SetDefault("service.health.timeout", DftHealthTimeout)
+	cfg.SetDefault("service.health.cacheTTL", DftHealthCacheTTL)
+	cfg.SetDefault("service.health.disk.minFree", DftHealthDiskMinFree)
+	cfg.SetDefault("service.metrics.enabled", true)
+	cfg.SetDefault("service.metrics.path", DftMetricsPath)
+	cfg.SetDefault("service.accessLog", true)
+	cfg.SetDefault("service.trustProxy", false)
+
+	lg, err := logger.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tp, err := tracing.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	crs, err := newCORS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	router := mux.NewRouter()
 
 	// Server
 	srv := &http.Server{
-		Handler:      mux.NewRouter(),
+		Handler:      router,
 		Addr:         cfg.GetString("service.address"),
 		ReadTimeout:  cfg.GetDuration("service.readTimeout"),
 		WriteTimeout: cfg.GetDuration("service.writeTimeout"),
+		ErrorLog:     lg.StdLogger(logger.LevelError),
 	}
 
 	// Service
-	svc := &Base{cfg, srv, nil, nil}
-
-	// Middlewares
-	svc.Router().Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("%s %s", r.Method, r.RequestURI)
-			next.ServeHTTP(w, r)
-		})
+	svc := &Base{
+		config: cfg,
+		server: srv,
+		router: router,
+		logger: lg,
+		health: &health{
+			timeout:  cfg.GetDuration("service.health.timeout"),
+			cacheTTL: cfg.GetDuration("service.health.cacheTTL"),
+		},
+		metrics: newMetrics(cfg.GetString("service.metrics.namespace")),
+	}
+
+	// Tracing
+	svc.Router().Use(tracing.RouteMiddleware)
+	svc.AfterStop(func(ctx context.Context, _ Service) error {
+		return tp.Shutdown(ctx)
 	})
 
+	// Metrics
+	if cfg.GetBool("service.metrics.enabled") {
+		svc.Router().Use(routeMiddleware)
+		svc.Router().Handle(cfg.GetString("service.metrics.path"), svc.metrics.handler()).Methods(http.MethodGet)
+	}
+
+	// Health
+	svc.Router().HandleFunc("/healthz", svc.serveHealth).Methods(http.MethodGet)
+	svc.Router().HandleFunc("/livez", svc.serveLive).Methods(http.MethodGet)
+	svc.Router().HandleFunc("/readyz", svc.serveReady).Methods(http.MethodGet)
+	if path := cfg.GetString("service.health.disk.path"); path != "" {
+		svc.AddHealthCheck("disk", DiskCheck(path, uint64(cfg.GetInt("service.health.disk.minFree"))), NonCritical())
+	}
+
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	// Middlewares wrapping the router, so they handle requests not matching any route as well
+	if cfg.GetBool("service.metrics.enabled") {
+		srv.Handler = svc.metrics.middleware(srv.Handler)
+	}
+	srv.Handler = svc.recoverPanics(srv.Handler)
+	if crs != nil {
+		srv.Handler = crs.middleware(srv.Handler, router)
+	}
+	srv.Handler = svc.logRequests(srv.Handler, cfg.GetBool("service.accessLog"), cfg.GetBool("service.trustProxy"))
+	srv.Handler = requestid.Middleware(srv.Handler)
+	srv.Handler = tracing.Middleware(srv.Handler)
+
 	return svc, nil
 }
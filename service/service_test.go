@@ -0,0 +1,212 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/service"
+)
+
+// recorder records lifecycle phases hooks are called at.
+type recorder struct {
+	mu     sync.Mutex
+	phases []string
+}
+
+func (r *recorder) hook(phase string, err error) service.Hook {
+	return func(context.Context, service.Service) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.phases = append(r.phases, phase)
+		return err
+	}
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.phases...)
+}
+
+func newService(t *testing.T, addr string) *service.Base {
+	cfg := config.NewTesting("hello")
+	cfg.Set("service.address", addr)
+
+	svc, err := service.New(cfg)
+	require.NoError(t, err)
+
+	return svc
+}
+
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	return ln.Addr().String()
+}
+
+func TestLifecycle(t *testing.T) {
+	addr := freeAddress(t)
+	svc := newService(t, addr)
+	svc.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := &recorder{}
+	svc.BeforeStart(rec.hook("before start", nil))
+	svc.AfterStart(rec.hook("after start", nil))
+	svc.BeforeStop(rec.hook("before stop", nil))
+	svc.AfterStop(rec.hook("after stop", nil))
+
+	started := make(chan error, 1)
+	go func() {
+		started <- svc.Start(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, []string{"before start", "after start"}, rec.get())
+
+	require.NoError(t, svc.Stop(context.Background()))
+	require.NoError(t, <-started)
+	require.Equal(t, []string{"before start", "after start", "before stop", "after stop"}, rec.get())
+}
+
+func TestBeforeStartFailure(t *testing.T) {
+	svc := newService(t, freeAddress(t))
+	failure := errors.New("no database")
+
+	rec := &recorder{}
+	svc.AfterStop(rec.hook("after stop 0", nil))
+	svc.BeforeStart(rec.hook("before start 1", nil))
+	svc.AfterStop(rec.hook("after stop 1", nil))
+	svc.BeforeStart(rec.hook("before start 2", failure))
+	svc.AfterStop(rec.hook("after stop 2", nil))
+	svc.BeforeStart(rec.hook("before start 3", nil))
+	svc.AfterStart(rec.hook("after start", nil))
+	svc.AfterStop(rec.hook("after stop 3", nil))
+
+	// Only the hooks which started are unwound, the latest first
+	err := svc.Start(context.Background())
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, []string{"before start 1", "before start 2", "after stop 1", "after stop 0"}, rec.get())
+}
+
+func TestAfterStartFailure(t *testing.T) {
+	svc := newService(t, freeAddress(t))
+	failure := errors.New("warm-up failed")
+
+	rec := &recorder{}
+	svc.AfterStart(rec.hook("after start", failure))
+	svc.BeforeStop(rec.hook("before stop", nil))
+	svc.AfterStop(rec.hook("after stop", nil))
+
+	err := svc.Start(context.Background())
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, []string{"after start", "before stop", "after stop"}, rec.get())
+}
+
+func TestListenFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	svc := newService(t, ln.Addr().String())
+	rec := &recorder{}
+	svc.AfterStop(rec.hook("after stop 1", nil))
+	svc.BeforeStart(rec.hook("before start", nil))
+	svc.AfterStart(rec.hook("after start", nil))
+	svc.AfterStop(rec.hook("after stop 2", nil))
+
+	require.Error(t, svc.Start(context.Background()))
+	require.Equal(t, []string{"before start", "after stop 2", "after stop 1"}, rec.get())
+}
+
+func TestStopHookFailure(t *testing.T) {
+	svc := newService(t, freeAddress(t))
+	failure := errors.New("flush failed")
+
+	rec := &recorder{}
+	svc.BeforeStop(rec.hook("before stop", failure))
+	svc.AfterStop(rec.hook("after stop", errors.New("close failed")))
+
+	err := svc.Stop(context.Background())
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, []string{"before stop", "after stop"}, rec.get())
+}
+
+func TestUpgrade(t *testing.T) {
+	addr := freeAddress(t)
+	svc := newService(t, addr)
+	svc.Router().HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		line, _ := rw.ReadString('\n')
+		_, _ = rw.WriteString(line)
+		_ = rw.Flush()
+	}).Methods(http.MethodGet)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- svc.Start(context.Background())
+	}()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+	defer func() { _ = conn.Close() }()
+
+	// Every middleware of the service passes the connection through
+	_, err := conn.Write([]byte("GET /echo HTTP/1.1\r\nHost: " + addr +
+		"\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n"))
+	require.NoError(t, err)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	_, err = conn.Write([]byte("ping\n"))
+	require.NoError(t, err)
+	line, err := br.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ping\n", line)
+
+	resp, err = http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	require.Contains(t, string(body), `http_requests_total{method="GET",route="/echo",status="101"} 1`)
+
+	require.NoError(t, svc.Stop(context.Background()))
+	require.NoError(t, <-started)
+}
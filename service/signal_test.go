@@ -0,0 +1,90 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+//go:build !windows
+// +build !windows
+
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/service"
+)
+
+func run(svc service.Service) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.Run()
+	}()
+
+	return done
+}
+
+func signal(t *testing.T, sig syscall.Signal) {
+	require.NoError(t, syscall.Kill(syscall.Getpid(), sig))
+}
+
+func TestRunSignals(t *testing.T) {
+	addr := freeAddress(t)
+	svc := newService(t, addr)
+	svc.Config().Set("service.preStopDelay", "300ms")
+	svc.Router().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := &recorder{}
+	svc.OnReload(rec.hook("reload", nil))
+	svc.AfterStop(rec.hook("after stop", nil))
+
+	done := run(svc)
+	require.Eventually(t, svc.Ready, time.Second*5, time.Millisecond*10)
+
+	signal(t, syscall.SIGHUP)
+	require.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, []string{"reload"}, rec.get())
+	require.True(t, svc.Ready())
+
+	signal(t, syscall.SIGTERM)
+	require.Eventually(t, func() bool { return !svc.Ready() }, time.Second*5, time.Millisecond*10)
+
+	// Requests are served during the pre-stop delay
+	resp, err := http.Get("http://" + addr + "/")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, <-done)
+	require.Equal(t, []string{"reload", "after stop"}, rec.get())
+}
+
+func TestRunForcedStop(t *testing.T) {
+	svc := newService(t, freeAddress(t))
+
+	release := make(chan struct{})
+	defer close(release)
+	svc.BeforeStop(func(context.Context, service.Service) error {
+		<-release
+		return nil
+	})
+
+	done := run(svc)
+	require.Eventually(t, svc.Ready, time.Second*5, time.Millisecond*10)
+
+	signal(t, syscall.SIGINT)
+	require.Eventually(t, func() bool { return !svc.Ready() }, time.Second*5, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	signal(t, syscall.SIGTERM)
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, service.ErrForcedStop)
+	case <-time.After(time.Second * 5):
+		t.Fatal("Run did not return after the second signal")
+	}
+}
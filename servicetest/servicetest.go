@@ -12,9 +12,9 @@ import (
 	"ampho.xyz/core/service"
 )
 
-// DoRequest performs a request to the service and writes a response.
+// DoRequest performs a request to the service, passing it through the service middlewares, and writes a response.
 func DoRequest(svc service.Service, req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
-	svc.Router().ServeHTTP(rr, req)
+	svc.Server().Handler.ServeHTTP(rr, req)
 	return rr
 }
@@ -0,0 +1,14 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package sitemap
+
+import "time"
+
+const (
+	MaxURLs = 50000 // maximum number of URLs in a single sitemap allowed by the protocol
+
+	DftMaxAge   = time.Hour   // how long clients may cache a sitemap
+	DftCacheTTL = time.Minute // how long pages are cached before items are split into pages again
+)
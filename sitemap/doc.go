@@ -0,0 +1,9 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package sitemap provides sitemap.xml generation for live content items which have permalinks.
+//
+// Sitemaps with more URLs than allowed by the protocol are split into pages listed by a sitemap index. For more
+// information look at https://www.sitemaps.org/protocol.html.
+package sitemap
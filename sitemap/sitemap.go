@@ -0,0 +1,263 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package sitemap
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+)
+
+const (
+	xmlns      = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sourceSQL  = "FROM permalinks p JOIN content_items i ON i.uuid = p.entity_uuid WHERE "
+	orderSQL   = " ORDER BY i.id"
+	urlColumns = "SELECT p.path, i.updated_at "
+)
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// page is a sitemap page.
+type page struct {
+	start   int64     // ID of the first item
+	modTime time.Time // last modification time of the items
+}
+
+// Sitemap serves sitemaps of live content items which have permalinks.
+type Sitemap struct {
+	db         *database.Database
+	baseURL    string
+	trustProxy bool
+	maxAge     time.Duration
+	pageSize   int
+	cacheTTL   time.Duration
+
+	mu       sync.Mutex
+	cache    []page
+	cachedAt time.Time
+}
+
+// Mount registers /sitemap.xml and /sitemap-{page}.xml handlers on a router.
+func (s *Sitemap) Mount(r *mux.Router) {
+	r.HandleFunc("/sitemap.xml", s.serveRoot).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/sitemap-{page:[0-9]+}.xml", s.servePage).Methods(http.MethodGet, http.MethodHead)
+}
+
+func (s *Sitemap) serveRoot(w http.ResponseWriter, r *http.Request) {
+	pages, err := s.pages(r.Context())
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var modTime time.Time
+	for _, p := range pages {
+		if p.modTime.After(modTime) {
+			modTime = p.modTime
+		}
+	}
+
+	if s.checkCache(w, r, modTime) {
+		return
+	}
+
+	if len(pages) <= 1 {
+		s.writeURLs(w, r, page{})
+		return
+	}
+
+	base := httputil.BaseURL(r, s.baseURL, s.trustProxy)
+	entries := make([]sitemapEntry, len(pages))
+	for i, p := range pages {
+		entries[i] = sitemapEntry{base + "/sitemap-" + strconv.Itoa(i+1) + ".xml", p.modTime.UTC().Format(time.RFC3339)}
+	}
+
+	writeHeader(w)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name       `xml:"sitemapindex"`
+		Xmlns   string         `xml:"xmlns,attr"`
+		Entries []sitemapEntry `xml:"sitemap"`
+	}{Xmlns: xmlns, Entries: entries})
+}
+
+func (s *Sitemap) servePage(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(mux.Vars(r)["page"])
+
+	pages, err := s.pages(r.Context())
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	if n < 1 || n > len(pages) {
+		_, _ = httputil.WriteStatus(w, http.StatusNotFound)
+		return
+	}
+
+	if s.checkCache(w, r, pages[n-1].modTime) {
+		return
+	}
+
+	s.writeURLs(w, r, pages[n-1])
+}
+
+// pages returns sitemap pages. Splitting items into pages takes a scan of all of them, so pages are cached for
+// the cache TTL. Pages are delimited by item IDs, so items created or deleted meanwhile do not shift other pages.
+func (s *Sitemap) pages(ctx context.Context) ([]page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cache != nil && time.Since(s.cachedAt) < s.cacheTTL {
+		return s.cache, nil
+	}
+
+	rows, err := s.db.Query(ctx, "SELECT min(id), max(updated_at) FROM (SELECT (row_number() OVER ("+orderSQL+
+		") - 1) / $1 AS n, i.id, i.updated_at "+sourceSQL+content.LiveSQL("i")+") s GROUP BY n ORDER BY n",
+		s.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := []page{}
+	for rows.Next() {
+		var (
+			p       page
+			modTime pgtype.Timestamptz
+		)
+		if err = rows.Scan(&p.start, &modTime); err != nil {
+			return nil, err
+		}
+		p.modTime = modTime.Time
+		r = append(r, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.cache, s.cachedAt = r, time.Now()
+
+	return r, nil
+}
+
+// writeURLs streams URLs of a page.
+func (s *Sitemap) writeURLs(w http.ResponseWriter, r *http.Request, p page) {
+	rows, err := s.db.Query(r.Context(), urlColumns+sourceSQL+content.LiveSQL("i")+" AND i.id >= $1"+orderSQL+
+		" LIMIT $2", p.start, s.pageSize)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	writeHeader(w)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	base := httputil.BaseURL(r, s.baseURL, s.trustProxy)
+	enc := xml.NewEncoder(w)
+	start := xml.StartElement{Name: xml.Name{Local: "urlset"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"},
+		Value: xmlns}}}
+	urlStart := xml.StartElement{Name: xml.Name{Local: "url"}}
+
+	if err = enc.EncodeToken(start); err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var (
+			path    string
+			modTime pgtype.Timestamptz
+		)
+		if err = rows.Scan(&path, &modTime); err != nil {
+			break
+		}
+
+		if err = enc.EncodeElement(urlEntry{base + path, modTime.Time.UTC().Format(time.RFC3339)}, urlStart); err != nil {
+			return
+		}
+	}
+
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		// Headers are sent already, so only an incomplete document may signal the error
+		logger.FromContext(r.Context()).Error("failed to write sitemap", "error", err)
+		_ = enc.Flush()
+		return
+	}
+
+	_ = enc.EncodeToken(start.End())
+	_ = enc.Flush()
+}
+
+// checkCache sets caching headers, see httputil.CheckCache. Without a configured base URL the URLs are derived from
+// requests, so sitemaps are not stored in shared caches.
+func (s *Sitemap) checkCache(w http.ResponseWriter, r *http.Request, modTime time.Time) bool {
+	if s.baseURL == "" {
+		return httputil.CheckPrivateCache(w, r, modTime, s.maxAge)
+	}
+
+	return httputil.CheckCache(w, r, modTime, s.maxAge)
+}
+
+func (s *Sitemap) fail(w http.ResponseWriter, r *http.Request, err error) {
+	logger.FromContext(r.Context()).Error("failed to make sitemap", "error", err)
+	_, _ = httputil.WriteStatus(w, http.StatusInternalServerError)
+}
+
+func writeHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = io.WriteString(w, xml.Header)
+}
+
+// New creates a new sitemap.
+//
+// The base URL of the site is taken from `site.url` configuration key. If it is not set, the URL is derived from
+// requests, the scheme is taken from the proxy headers only if `service.trustProxy` is set, and sitemaps are not stored
+// in shared caches. Pages are recomputed at most once per `sitemap.cacheTTL`.
+func New(cfg config.Config, db *database.Database) *Sitemap {
+	cfg.SetDefault("sitemap.maxAge", DftMaxAge)
+	cfg.SetDefault("sitemap.pageSize", MaxURLs)
+	cfg.SetDefault("sitemap.cacheTTL", DftCacheTTL)
+	cfg.SetDefault("service.trustProxy", false)
+
+	pageSize := cfg.GetInt("sitemap.pageSize")
+	if pageSize <= 0 || pageSize > MaxURLs {
+		pageSize = MaxURLs
+	}
+
+	return &Sitemap{
+		db:         db,
+		baseURL:    cfg.GetString("site.url"),
+		trustProxy: cfg.GetBool("service.trustProxy"),
+		maxAge:     cfg.GetDuration("sitemap.maxAge"),
+		pageSize:   pageSize,
+		cacheTTL:   cfg.GetDuration("sitemap.cacheTTL"),
+	}
+}
@@ -0,0 +1,137 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package sitemap_test
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/sitemap"
+)
+
+type urlset struct {
+	XMLName xml.Name `xml:"urlset"`
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+func addItem(t *testing.T, db *database.Database, status, path string) {
+	var uuid string
+	err := db.QueryRow(context.Background(), "INSERT INTO content_items (type, status) VALUES ('page', $1) "+
+		"RETURNING uuid::text", status).Scan(&uuid)
+	require.NoError(t, err)
+
+	_, err = db.Exec(context.Background(), "INSERT INTO permalinks (path, entity_uuid) VALUES ($1, $2)", path, uuid)
+	require.NoError(t, err)
+}
+
+func newRouter(db *database.Database, pageSize int) *mux.Router {
+	cfg := config.NewTesting("sitemap")
+	cfg.Set("site.url", "https://example.com")
+	cfg.Set("sitemap.pageSize", pageSize)
+	cfg.Set("sitemap.cacheTTL", 0)
+
+	r := mux.NewRouter()
+	sitemap.New(cfg, db).Mount(r)
+
+	return r
+}
+
+func get(t *testing.T, r http.Handler, target string, doc interface{}) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code == http.StatusOK {
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), doc))
+	}
+
+	return w.Code
+}
+
+func TestSitemap(t *testing.T) {
+	db := databasetest.New(t)
+
+	for i := 1; i <= 5; i++ {
+		addItem(t, db, "published", "/p"+strconv.Itoa(i))
+	}
+	addItem(t, db, "draft", "/draft")
+
+	// A single page
+	var urls urlset
+	require.Equal(t, http.StatusOK, get(t, newRouter(db, 0), "/sitemap.xml", &urls))
+	require.Len(t, urls.URLs, 5)
+	require.Equal(t, "https://example.com/p1", urls.URLs[0].Loc)
+
+	// Pages listed by the index
+	r := newRouter(db, 2)
+
+	var idx index
+	require.Equal(t, http.StatusOK, get(t, r, "/sitemap.xml", &idx))
+	require.Len(t, idx.Sitemaps, 3)
+	for i, s := range idx.Sitemaps {
+		require.Equal(t, "https://example.com/sitemap-"+strconv.Itoa(i+1)+".xml", s.Loc)
+		require.NotEmpty(t, s.LastMod)
+	}
+
+	for page, paths := range map[int][]string{1: {"/p1", "/p2"}, 2: {"/p3", "/p4"}, 3: {"/p5"}} {
+		var urls urlset
+		require.Equal(t, http.StatusOK, get(t, r, "/sitemap-"+strconv.Itoa(page)+".xml", &urls))
+		require.Len(t, urls.URLs, len(paths))
+		for i, p := range paths {
+			require.Equal(t, "https://example.com"+p, urls.URLs[i].Loc)
+		}
+	}
+
+	require.Equal(t, http.StatusNotFound, get(t, r, "/sitemap-0.xml", nil))
+	require.Equal(t, http.StatusNotFound, get(t, r, "/sitemap-4.xml", nil))
+
+	// Deleted items are not listed
+	_, err := db.Exec(context.Background(), "UPDATE content_items SET deleted_at = now() WHERE uuid = "+
+		"(SELECT entity_uuid FROM permalinks WHERE path = '/p5')")
+	require.NoError(t, err)
+
+	idx = index{}
+	require.Equal(t, http.StatusOK, get(t, r, "/sitemap.xml", &idx))
+	require.Len(t, idx.Sitemaps, 2)
+}
+
+func TestDerivedBaseURL(t *testing.T) {
+	db := databasetest.New(t)
+	addItem(t, db, "published", "/p1")
+
+	r := mux.NewRouter()
+	sitemap.New(config.NewTesting("sitemap"), db).Mount(r)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+	req.Host = "example.org"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The client chose the host, so shared caches must not keep the response
+	var urls urlset
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &urls))
+	require.Equal(t, "http://example.org/p1", urls.URLs[0].Loc)
+	require.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,6 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package slug provides generation of human-readable URL path segments.
+package slug
@@ -0,0 +1,94 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the maximum length of a slug made by Make.
+const MaxLength = 80
+
+// translit maps letters which cannot be reduced to ASCII by stripping diacritics.
+var translit = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i", 'ħ': "h",
+	'ŋ': "ng", 'ŧ': "t",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "yo", 'є': "ye", 'ж': "zh",
+	'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ў': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Apostrophes do not separate words
+	'\'': "", '’': "", 'ʼ': "",
+}
+
+// Make makes a slug from a string, such as a title. Letters are transliterated to ASCII and lowercased, any other
+// characters become hyphens. The result is at most MaxLength characters long and is cut at a word boundary.
+func Make(s string) string {
+	var b strings.Builder
+
+	sep := false
+	write := func(t string) {
+		if t == "" {
+			return
+		}
+		if sep && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(t)
+		sep = false
+	}
+
+	for _, r := range strings.ToLower(s) {
+		if t, ok := translit[r]; ok {
+			write(t)
+			continue
+		}
+
+		// Strip diacritics off the letter
+		for _, d := range norm.NFD.String(string(r)) {
+			if t, ok := translit[d]; ok {
+				write(t)
+			} else if d < unicode.MaxASCII && (unicode.IsLetter(d) || unicode.IsDigit(d)) {
+				write(string(d))
+			} else if !unicode.Is(unicode.Mn, d) {
+				sep = true
+			}
+		}
+	}
+
+	r := b.String()
+	if len(r) > MaxLength {
+		cut := r[MaxLength] == '-'
+		r = r[:MaxLength]
+		if i := strings.LastIndexByte(r, '-'); !cut && i > 0 {
+			r = r[:i]
+		}
+	}
+
+	return r
+}
+
+// WithSuffix returns a slug with a numeric uniqueness suffix, such as "hello-2". Numbers less than 2 return the slug
+// unchanged.
+func WithSuffix(slug string, n int) string {
+	if n < 2 {
+		return slug
+	}
+
+	return slug + "-" + strconv.Itoa(n)
+}
@@ -0,0 +1,50 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package slug_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/slug"
+)
+
+func TestMake(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":                 "hello-world",
+		"  Multiple   spaces  ":         "multiple-spaces",
+		"Don't panic":                   "dont-panic",
+		"Crème brûlée à la française":   "creme-brulee-a-la-francaise",
+		"Straße in Łódź":                "strasse-in-lodz",
+		"Ærøskøbing":                    "aeroskobing",
+		"Привіт, світе":                 "privit-svite",
+		"Щастя і їжачок":                "shchastya-i-yizhachok",
+		"Съешь же ещё этих булок":       "sesh-zhe-eshchyo-etikh-bulok",
+		"Καλημέρα κόσμε":                "kalimera-kosme",
+		"Go 1.16 release notes":         "go-1-16-release-notes",
+		"---":                           "",
+		"日本語":                           "",
+		"C++ & Go: the (good) parts...": "c-go-the-good-parts",
+	}
+
+	for in, expected := range tests {
+		require.Equal(t, expected, slug.Make(in), in)
+	}
+}
+
+func TestMakeMaxLength(t *testing.T) {
+	s := slug.Make(strings.Repeat("word ", 40))
+
+	require.LessOrEqual(t, len(s), slug.MaxLength)
+	require.False(t, strings.HasSuffix(s, "-"))
+	require.True(t, strings.HasSuffix(s, "word"))
+}
+
+func TestWithSuffix(t *testing.T) {
+	require.Equal(t, "hello", slug.WithSuffix("hello", 1))
+	require.Equal(t, "hello-2", slug.WithSuffix("hello", 2))
+}
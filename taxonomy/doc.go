@@ -0,0 +1,10 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package taxonomy provides hierarchical classification of entities.
+//
+// Terms belong to vocabularies, such as categories or tags, and form trees of arbitrary depth. Trees are stored using
+// the PostgreSQL ltree extension, so ancestors, descendants and everything attached to a subtree are selected by
+// a single indexed query. Terms are attached to any entities by their UUIDs.
+package taxonomy
@@ -0,0 +1,453 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package taxonomy
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/database"
+)
+
+var (
+	// ErrNotFound is returned when a term does not exist.
+	ErrNotFound = errors.New("term not found")
+
+	// ErrExists is returned when a term with the same name already exists under the same parent.
+	ErrExists = errors.New("term already exists")
+
+	// ErrCycle is returned on attempt to move a term under itself or its descendant.
+	ErrCycle = errors.New("term cannot be moved under itself")
+
+	// ErrVocabularyMismatch is returned on attempt to make a parent-child relation between terms of different
+	// vocabularies.
+	ErrVocabularyMismatch = errors.New("terms belong to different vocabularies")
+)
+
+const termColumns = "id, uuid, created_at, updated_at, deleted_at, vocabulary, name, path::text AS path, " +
+	"nlevel(path) AS depth"
+
+// Taxonomy is a taxonomy terms storage.
+type Taxonomy struct {
+	db *database.Database
+}
+
+// Get returns a term by its UUID.
+func (t *Taxonomy) Get(ctx context.Context, uuid string) (*Term, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	term := &Term{}
+
+	err := t.db.SelectOne(ctx, term, "SELECT "+termColumns+" FROM taxonomy_terms WHERE uuid = $1", uuid)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return term, nil
+}
+
+// Create creates a new term. If parentUUID is empty, the term becomes a root one.
+func (t *Taxonomy) Create(ctx context.Context, vocabulary, name, parentUUID string) (*Term, error) {
+	term := &Term{}
+
+	err := t.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var (
+			parent   *Term
+			parentID interface{}
+		)
+
+		if parentUUID != "" {
+			var err error
+			if parent, err = lockTerm(ctx, tx, parentUUID); err != nil {
+				return err
+			}
+			if parent.Vocabulary != vocabulary {
+				return ErrVocabularyMismatch
+			}
+			parentID = parent.ID
+		}
+
+		var id int64
+		err := tx.QueryRow(ctx, "SELECT nextval(pg_get_serial_sequence('taxonomy_terms', 'id'))").Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return pgxscan.Get(ctx, tx, term, "INSERT INTO taxonomy_terms (id, vocabulary, name, parent_id, path) "+
+			"VALUES ($1, $2, $3, $4, $5::ltree) RETURNING "+termColumns,
+			id, vocabulary, name, parentID, parent.ChildPath(id))
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return term, nil
+}
+
+// Rename changes a term name.
+func (t *Taxonomy) Rename(ctx context.Context, uuid, name string) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	tag, err := t.db.Exec(ctx, "UPDATE taxonomy_terms SET name = $2, updated_at = now() WHERE uuid = $1",
+		uuid, name)
+	if err != nil {
+		return mapError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Move moves a term with all its descendants under a new parent. If parentUUID is empty, the term becomes a root one.
+func (t *Taxonomy) Move(ctx context.Context, uuid, parentUUID string) error {
+	err := t.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		term, err := lockTerm(ctx, tx, uuid)
+		if err != nil {
+			return err
+		}
+
+		var (
+			parentID   interface{}
+			parentPath string
+		)
+
+		if parentUUID != "" {
+			parent, err := lockTerm(ctx, tx, parentUUID)
+			if err != nil {
+				return err
+			}
+			if err = term.CheckMove(parent); err != nil {
+				return err
+			}
+			parentID, parentPath = parent.ID, parent.Path
+		}
+
+		if _, err = tx.Exec(ctx, "UPDATE taxonomy_terms SET parent_id = $2, updated_at = now() WHERE id = $1",
+			term.ID, parentID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE taxonomy_terms SET path = $2::ltree || subpath(path, nlevel($1::ltree) - 1) "+
+			"WHERE path <@ $1::ltree", term.Path, parentPath)
+
+		return err
+	})
+
+	return mapError(err)
+}
+
+// Delete deletes a term with all its descendants.
+func (t *Taxonomy) Delete(ctx context.Context, uuid string) error {
+	if !database.IsUUID(uuid) {
+		return ErrNotFound
+	}
+
+	tag, err := t.db.Exec(ctx, "DELETE FROM taxonomy_terms WHERE path <@ (SELECT path FROM taxonomy_terms "+
+		"WHERE uuid = $1)", uuid)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Roots returns root terms of a vocabulary ordered by name.
+func (t *Taxonomy) Roots(ctx context.Context, vocabulary string) ([]*Term, error) {
+	return t.selectTerms(ctx, "SELECT "+termColumns+" FROM taxonomy_terms WHERE vocabulary = $1 "+
+		"AND parent_id IS NULL ORDER BY name", vocabulary)
+}
+
+// Children returns direct children of a term ordered by name.
+func (t *Taxonomy) Children(ctx context.Context, uuid string) ([]*Term, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	return t.selectTerms(ctx, "SELECT "+termColumns+" FROM taxonomy_terms WHERE parent_id = "+
+		"(SELECT id FROM taxonomy_terms WHERE uuid = $1) ORDER BY name", uuid)
+}
+
+// Ancestors returns ancestors of a term starting from the root.
+func (t *Taxonomy) Ancestors(ctx context.Context, uuid string) ([]*Term, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	return t.selectTerms(ctx, "SELECT "+termColumns+" FROM taxonomy_terms WHERE path @> "+
+		"(SELECT subpath(path, 0, nlevel(path) - 1) FROM taxonomy_terms WHERE uuid = $1 AND nlevel(path) > 1) "+
+		"ORDER BY nlevel(path)", uuid)
+}
+
+// Descendants returns all descendants of a term in depth-first order.
+func (t *Taxonomy) Descendants(ctx context.Context, uuid string) ([]*Term, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	return t.selectTerms(ctx, "WITH p AS (SELECT path FROM taxonomy_terms WHERE uuid = $1) "+
+		"SELECT "+termColumns+" FROM taxonomy_terms WHERE path <@ (SELECT path FROM p) "+
+		"AND path <> (SELECT path FROM p) ORDER BY path", uuid)
+}
+
+// Names returns names of a term ancestors and the term itself starting from the root.
+func (t *Taxonomy) Names(ctx context.Context, uuid string) ([]string, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	terms, err := t.selectTerms(ctx, "SELECT "+termColumns+" FROM taxonomy_terms WHERE path @> "+
+		"(SELECT path FROM taxonomy_terms WHERE uuid = $1) ORDER BY nlevel(path)", uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(terms) == 0 {
+		return nil, ErrNotFound
+	}
+
+	names := make([]string, len(terms))
+	for i, term := range terms {
+		names[i] = term.Name
+	}
+
+	return names, nil
+}
+
+// Ensure returns a term of a vocabulary by names of its ancestors and the term itself starting from the root. Missing
+// terms are created, names are matched case-insensitively.
+func (t *Taxonomy) Ensure(ctx context.Context, vocabulary string, names ...string) (*Term, error) {
+	var term *Term
+
+	for _, name := range names {
+		parentUUID := ""
+		if term != nil {
+			parentUUID = term.GetUUID()
+		}
+
+		child, err := t.child(ctx, vocabulary, parentUUID, name)
+		if err == ErrNotFound {
+			child, err = t.Create(ctx, vocabulary, name, parentUUID)
+			if err == ErrExists {
+				child, err = t.child(ctx, vocabulary, parentUUID, name)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		term = child
+	}
+
+	if term == nil {
+		return nil, ErrNotFound
+	}
+
+	return term, nil
+}
+
+// child returns a child term of a parent by its name. If parentUUID is empty, a root term is returned.
+func (t *Taxonomy) child(ctx context.Context, vocabulary, parentUUID, name string) (*Term, error) {
+	sql := "SELECT " + termColumns + " FROM taxonomy_terms WHERE vocabulary = $1 AND lower(name) = lower($2) AND "
+	args := []interface{}{vocabulary, name}
+
+	if parentUUID == "" {
+		sql += "parent_id IS NULL"
+	} else if !database.IsUUID(parentUUID) {
+		return nil, ErrNotFound
+	} else {
+		sql += "parent_id = (SELECT id FROM taxonomy_terms WHERE uuid = $3)"
+		args = append(args, parentUUID)
+	}
+
+	terms, err := t.selectTerms(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(terms) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return terms[0], nil
+}
+
+// Attach attaches a term to an entity.
+func (t *Taxonomy) Attach(ctx context.Context, termUUID, entityUUID string) error {
+	if !database.IsUUID(termUUID) || !database.IsUUID(entityUUID) {
+		return ErrNotFound
+	}
+
+	tag, err := t.db.Exec(ctx, "INSERT INTO taxonomy_term_entities (term_id, entity_uuid) "+
+		"SELECT id, $2 FROM taxonomy_terms WHERE uuid = $1 ON CONFLICT DO NOTHING", termUUID, entityUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		if _, err = t.Get(ctx, termUUID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Detach detaches a term from an entity.
+func (t *Taxonomy) Detach(ctx context.Context, termUUID, entityUUID string) error {
+	if !database.IsUUID(termUUID) || !database.IsUUID(entityUUID) {
+		return ErrNotFound
+	}
+
+	_, err := t.db.Exec(ctx, "DELETE FROM taxonomy_term_entities WHERE term_id = "+
+		"(SELECT id FROM taxonomy_terms WHERE uuid = $1) AND entity_uuid = $2", termUUID, entityUUID)
+
+	return err
+}
+
+// Tag attaches root terms of a vocabulary with the names to an entity. Missing terms are created, names are matched
+// case-insensitively. It is intended for free-form vocabularies, such as tags.
+func (t *Taxonomy) Tag(ctx context.Context, entityUUID, vocabulary string, names ...string) error {
+	if !database.IsUUID(entityUUID) {
+		return ErrNotFound
+	}
+
+	return t.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			_, err := tx.Exec(ctx, "WITH n AS (SELECT nextval(pg_get_serial_sequence('taxonomy_terms', 'id')) AS id) "+
+				"INSERT INTO taxonomy_terms (id, vocabulary, name, path) SELECT n.id, $1, $2, n.id::text::ltree FROM n "+
+				"ON CONFLICT (vocabulary, (COALESCE(parent_id, 0)), (lower(name))) DO NOTHING", vocabulary, name)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, "INSERT INTO taxonomy_term_entities (term_id, entity_uuid) "+
+				"SELECT id, $3 FROM taxonomy_terms WHERE vocabulary = $1 AND parent_id IS NULL "+
+				"AND lower(name) = lower($2) ON CONFLICT DO NOTHING", vocabulary, name, entityUUID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Terms returns terms of a vocabulary attached to an entity ordered by path. If vocabulary is empty, terms of all
+// vocabularies are returned.
+func (t *Taxonomy) Terms(ctx context.Context, entityUUID, vocabulary string) ([]*Term, error) {
+	if !database.IsUUID(entityUUID) {
+		return nil, nil
+	}
+
+	return t.selectTerms(ctx, "SELECT "+termColumns+" FROM taxonomy_terms WHERE id IN "+
+		"(SELECT term_id FROM taxonomy_term_entities WHERE entity_uuid = $1) "+
+		"AND ($2 = '' OR vocabulary = $2) ORDER BY vocabulary, path", entityUUID, vocabulary)
+}
+
+// Entities returns UUIDs of entities attached to a term or any of its descendants.
+func (t *Taxonomy) Entities(ctx context.Context, termUUID string) ([]string, error) {
+	if !database.IsUUID(termUUID) {
+		return nil, ErrNotFound
+	}
+
+	var r []string
+
+	rows, err := t.db.Query(ctx, "SELECT DISTINCT e.entity_uuid::text FROM taxonomy_term_entities e "+
+		"JOIN taxonomy_terms t ON t.id = e.term_id WHERE "+underSQL+" ORDER BY 1", termUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		r = append(r, s)
+	}
+
+	return r, rows.Err()
+}
+
+func (t *Taxonomy) selectTerms(ctx context.Context, sql string, args ...interface{}) ([]*Term, error) {
+	var terms []*Term
+
+	if err := t.db.SelectAll(ctx, &terms, sql, args...); err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+const underSQL = "t.path <@ (SELECT path FROM taxonomy_terms WHERE uuid = $1)"
+
+// Under returns a condition selecting content items attached to a term or any of its descendants, to be used
+// in content.Query. A malformed UUID selects nothing.
+func Under(termUUID string) content.Cond {
+	if !database.IsUUID(termUUID) {
+		return content.Cond{SQL: "false"}
+	}
+
+	return content.Cond{
+		SQL: "uuid IN (SELECT e.entity_uuid FROM taxonomy_term_entities e JOIN taxonomy_terms t " +
+			"ON t.id = e.term_id WHERE " + underSQL + ")",
+		Args: []interface{}{termUUID},
+	}
+}
+
+func lockTerm(ctx context.Context, tx pgx.Tx, uuid string) (*Term, error) {
+	if !database.IsUUID(uuid) {
+		return nil, ErrNotFound
+	}
+
+	term := &Term{}
+
+	err := pgxscan.Get(ctx, tx, term, "SELECT "+termColumns+" FROM taxonomy_terms WHERE uuid = $1 FOR UPDATE",
+		uuid)
+	if pgxscan.NotFound(err) {
+		return nil, ErrNotFound
+	}
+
+	return term, err
+}
+
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrExists
+	}
+
+	return err
+}
+
+// New creates a new taxonomy terms storage.
+func New(db *database.Database) *Taxonomy {
+	return &Taxonomy{db}
+}
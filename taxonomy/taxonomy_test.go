@@ -0,0 +1,174 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package taxonomy_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/taxonomy"
+)
+
+func TestTermChildPath(t *testing.T) {
+	var root *taxonomy.Term
+	require.Equal(t, "7", root.ChildPath(7))
+
+	term := &taxonomy.Term{Path: "1.20.3"}
+	require.Equal(t, "1.20.3.45", term.ChildPath(45))
+}
+
+func TestTermCheckMove(t *testing.T) {
+	term := &taxonomy.Term{Vocabulary: "categories", Path: "1.2"}
+
+	for path, err := range map[string]error{
+		"1":       nil,
+		"3":       nil,
+		"1.20":    nil,
+		"1.3.2":   nil,
+		"12":      nil,
+		"1.2":     taxonomy.ErrCycle,
+		"1.2.5":   taxonomy.ErrCycle,
+		"1.2.5.6": taxonomy.ErrCycle,
+	} {
+		require.Equal(t, err, term.CheckMove(&taxonomy.Term{Vocabulary: "categories", Path: path}), path)
+	}
+
+	require.Equal(t, taxonomy.ErrVocabularyMismatch, term.CheckMove(&taxonomy.Term{Vocabulary: "tags", Path: "3"}))
+}
+
+func TestMalformedUUID(t *testing.T) {
+	// Malformed UUIDs never reach the database
+	tx := taxonomy.New(nil)
+	ctx := context.Background()
+	uuid := "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f0"
+
+	_, err := tx.Get(ctx, "1")
+	require.Equal(t, taxonomy.ErrNotFound, err)
+	require.Equal(t, taxonomy.ErrNotFound, tx.Rename(ctx, "1", "name"))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Delete(ctx, "1"))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Attach(ctx, "1", uuid))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Attach(ctx, uuid, "1"))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Detach(ctx, "1", uuid))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Detach(ctx, uuid, "1"))
+	require.Equal(t, taxonomy.ErrNotFound, tx.Tag(ctx, "1", "tags", "go"))
+
+	for _, fn := range []func(context.Context, string) ([]*taxonomy.Term, error){
+		tx.Children, tx.Ancestors, tx.Descendants,
+	} {
+		_, err = fn(ctx, "1")
+		require.Equal(t, taxonomy.ErrNotFound, err)
+	}
+
+	_, err = tx.Names(ctx, "1")
+	require.Equal(t, taxonomy.ErrNotFound, err)
+	_, err = tx.Entities(ctx, "1")
+	require.Equal(t, taxonomy.ErrNotFound, err)
+	terms, err := tx.Terms(ctx, "1", "")
+	require.NoError(t, err)
+	require.Empty(t, terms)
+
+	require.Equal(t, "false", taxonomy.Under("1").SQL)
+	require.Equal(t, []interface{}{uuid}, taxonomy.Under(uuid).Args)
+}
+
+func names(t *testing.T, tx *taxonomy.Taxonomy, uuid string) []string {
+	r, err := tx.Names(context.Background(), uuid)
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestMove(t *testing.T) {
+	tx := taxonomy.New(databasetest.New(t))
+	ctx := context.Background()
+
+	generics, err := tx.Ensure(ctx, "categories", "Tech", "Go", "Generics")
+	require.NoError(t, err)
+	require.Equal(t, 3, generics.Depth)
+	goTerm, err := tx.Ensure(ctx, "categories", "tech", "GO")
+	require.NoError(t, err)
+	science, err := tx.Create(ctx, "categories", "Science", "")
+	require.NoError(t, err)
+	_, err = tx.Create(ctx, "categories", "science", "")
+	require.Equal(t, taxonomy.ErrExists, err)
+
+	// The subtree moves along
+	require.NoError(t, tx.Move(ctx, goTerm.GetUUID(), science.GetUUID()))
+	require.Equal(t, []string{"Science", "Go", "Generics"}, names(t, tx, generics.GetUUID()))
+
+	descendants, err := tx.Descendants(ctx, science.GetUUID())
+	require.NoError(t, err)
+	require.Len(t, descendants, 2)
+	require.Equal(t, "Go", descendants[0].Name)
+	require.Equal(t, "Generics", descendants[1].Name)
+	require.Equal(t, 3, descendants[1].Depth)
+
+	ancestors, err := tx.Ancestors(ctx, generics.GetUUID())
+	require.NoError(t, err)
+	require.Len(t, ancestors, 2)
+	require.Equal(t, "Science", ancestors[0].Name)
+
+	children, err := tx.Children(ctx, science.GetUUID())
+	require.NoError(t, err)
+	require.Len(t, children, 1)
+
+	// Not under itself or its descendants, nor into another vocabulary
+	require.Equal(t, taxonomy.ErrCycle, tx.Move(ctx, goTerm.GetUUID(), goTerm.GetUUID()))
+	require.Equal(t, taxonomy.ErrCycle, tx.Move(ctx, science.GetUUID(), generics.GetUUID()))
+	tag, err := tx.Create(ctx, "tags", "go", "")
+	require.NoError(t, err)
+	require.Equal(t, taxonomy.ErrVocabularyMismatch, tx.Move(ctx, goTerm.GetUUID(), tag.GetUUID()))
+	require.Equal(t, []string{"Science", "Go", "Generics"}, names(t, tx, generics.GetUUID()))
+
+	// To the root
+	require.NoError(t, tx.Move(ctx, goTerm.GetUUID(), ""))
+	require.Equal(t, []string{"Go", "Generics"}, names(t, tx, generics.GetUUID()))
+	roots, err := tx.Roots(ctx, "categories")
+	require.NoError(t, err)
+	require.Len(t, roots, 3)
+
+	// Deleted with descendants
+	require.NoError(t, tx.Delete(ctx, goTerm.GetUUID()))
+	_, err = tx.Get(ctx, generics.GetUUID())
+	require.Equal(t, taxonomy.ErrNotFound, err)
+	require.Equal(t, taxonomy.ErrNotFound, tx.Delete(ctx, goTerm.GetUUID()))
+}
+
+func TestTagEntities(t *testing.T) {
+	tx := taxonomy.New(databasetest.New(t))
+	ctx := context.Background()
+	entity := "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f0"
+
+	require.NoError(t, tx.Tag(ctx, entity, "tags", "Go", " ", "go", "SQL"))
+	terms, err := tx.Terms(ctx, entity, "tags")
+	require.NoError(t, err)
+	require.Len(t, terms, 2)
+
+	generics, err := tx.Ensure(ctx, "categories", "Tech", "Go", "Generics")
+	require.NoError(t, err)
+	tech, err := tx.Ensure(ctx, "categories", "Tech")
+	require.NoError(t, err)
+	require.NoError(t, tx.Attach(ctx, generics.GetUUID(), entity))
+	require.NoError(t, tx.Attach(ctx, generics.GetUUID(), entity))
+
+	// Entities of descendants belong to ancestors
+	entities, err := tx.Entities(ctx, tech.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, []string{entity}, entities)
+
+	terms, err = tx.Terms(ctx, entity, "")
+	require.NoError(t, err)
+	require.Len(t, terms, 3)
+
+	require.NoError(t, tx.Detach(ctx, generics.GetUUID(), entity))
+	entities, err = tx.Entities(ctx, tech.GetUUID())
+	require.NoError(t, err)
+	require.Empty(t, entities)
+
+	require.Equal(t, taxonomy.ErrNotFound, tx.Attach(ctx, "2d1b1a38-8d9c-4e0c-a5c4-5e1c0bb6a7f1", entity))
+}
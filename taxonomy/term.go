@@ -0,0 +1,60 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package taxonomy
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"ampho.xyz/core/database"
+)
+
+// Term is a taxonomy term.
+type Term struct {
+	database.Entity
+	Vocabulary string
+	Name       string
+	Path       string // dot separated IDs of the term ancestors and the term itself
+	Depth      int    // number of levels in the path, root terms have depth 1
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *Term) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"uuid":       t.GetUUID(),
+		"vocabulary": t.Vocabulary,
+		"name":       t.Name,
+		"depth":      t.Depth,
+	})
+}
+
+// ChildPath returns the path of a child term with an ID. A nil term is the root of a vocabulary, so the path of a root
+// term is returned.
+func (t *Term) ChildPath(id int64) string {
+	if t == nil {
+		return strconv.FormatInt(id, 10)
+	}
+
+	return t.Path + "." + strconv.FormatInt(id, 10)
+}
+
+// Contains reports whether another term is the term itself or its descendant.
+func (t *Term) Contains(other *Term) bool {
+	return other.Path == t.Path || strings.HasPrefix(other.Path, t.Path+".")
+}
+
+// CheckMove checks the term may be moved under a parent: both belong to the same vocabulary and the parent is neither
+// the term itself nor its descendant.
+func (t *Term) CheckMove(parent *Term) error {
+	if parent.Vocabulary != t.Vocabulary {
+		return ErrVocabularyMismatch
+	}
+	if t.Contains(parent) {
+		return ErrCycle
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package theme
+
+import "time"
+
+const (
+	DftAssetPath   = "/assets/"       // URL path prefix of assets
+	DftAssetMaxAge = time.Hour * 8760 // how long clients may cache versioned assets
+	DftLayout      = "default"        // layout of pages consisting of template definitions only
+	DftLocale      = "en"             // locale of messages if a request has none
+)
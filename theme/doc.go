@@ -0,0 +1,27 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package theme renders server-side pages with html/template.
+//
+// A theme is a directory on disk or an embedded file system with the following layout:
+//
+//	layouts/default.html   page layouts, available to pages as "layouts/default"
+//	partials/header.html   shared fragments, available as "partials/header"
+//	pages/article.html     pages rendered by name, e.g. "article"
+//	i18n/en.yaml           flat maps of message keys to translations by locales
+//	assets/css/site.css    static files served by Theme.Mount
+//
+// A page consisting of template definitions only is rendered by the default layout, which includes the blocks the
+// page defines:
+//
+//	{{define "title"}}{{.Title}}{{end}}
+//	{{define "main"}}<h1>{{t "welcome" .Name}}</h1><a href="{{permalink .UUID}}">…</a>{{end}}
+//
+// Other pages are rendered as is and may include any layout explicitly. Templates may use the helper functions:
+// permalink returns the URL path of an entity by UUID, asset returns the URL of an asset with its content version,
+// t translates a message to the locale of the request, see WithLocale, locale returns the locale, and safeHTML marks
+// a string, e.g. a rendered rich-text field, as trusted HTML.
+//
+// In development mode the theme is reloaded as soon as its files change.
+package theme
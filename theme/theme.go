@@ -0,0 +1,372 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package theme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/permalink"
+)
+
+const (
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+	pagesDir    = "pages"
+	i18nDir     = "i18n"
+	assetsDir   = "assets"
+)
+
+// ErrPageNotFound is returned when a theme has no page with a given name.
+var ErrPageNotFound = errors.New("page not found")
+
+type localeKey struct{}
+
+// WithLocale returns a copy of the context with the locale pages are rendered in. It takes precedence over the
+// request locale, see i18n.Resolver.Middleware.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// Linker resolves entity UUIDs to URL paths. It returns permalink.ErrNotFound if an entity has no path.
+type Linker interface {
+	Path(ctx context.Context, entityUUID string) (string, error)
+}
+
+// files is a parsed theme.
+type files struct {
+	pages    map[string]*template.Template // page templates by names, never executed, so they may be cloned
+	messages map[string]map[string]string  // translations by locales and keys
+	assets   map[string]string             // content versions by asset paths
+	version  string                        // files list and modification times the theme was loaded from
+}
+
+// Theme renders pages of a theme.
+type Theme struct {
+	fsys      fs.FS
+	linker    Linker
+	dev       bool
+	assetPath string
+	maxAge    time.Duration
+	layout    string
+	locale    string
+
+	mu    sync.Mutex
+	files *files
+}
+
+// ExecuteTemplate renders a page. It implements httputil.TemplateExecutor, so pages can be written to responses with
+// httputil.WriteTemplate.
+func (t *Theme) ExecuteTemplate(ctx context.Context, w io.Writer, name string, data interface{}) error {
+	f, err := t.current()
+	if err != nil {
+		return err
+	}
+
+	page := f.pages[name]
+	if page == nil {
+		return fmt.Errorf("%s: %w", name, ErrPageNotFound)
+	}
+
+	tpl, err := page.Clone()
+	if err != nil {
+		return err
+	}
+	tpl.Funcs(t.funcs(ctx, f))
+
+	entry := pagesDir + "/" + name
+	if isEmpty(tpl.Lookup(entry).Tree) {
+		entry = layoutsDir + "/" + t.layout
+	}
+
+	return tpl.ExecuteTemplate(w, entry, data)
+}
+
+// Has reports whether the theme has a page.
+func (t *Theme) Has(name string) bool {
+	f, err := t.current()
+
+	return err == nil && f.pages[name] != nil
+}
+
+// Mount registers the assets handler on a router.
+func (t *Theme) Mount(r *mux.Router) {
+	r.PathPrefix(t.assetPath).Handler(http.StripPrefix(t.assetPath, t.assetsHandler()))
+}
+
+// assetsHandler serves assets. Versioned URLs produced by the asset function are cached by clients for a long time.
+func (t *Theme) assetsHandler() http.Handler {
+	sub, err := fs.Sub(t.fsys, assetsDir)
+	if err != nil {
+		return http.NotFoundHandler()
+	}
+	files := http.FileServer(http.FS(sub))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("v") != "" && !t.dev {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(t.maxAge.Seconds())))
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// current returns the loaded theme, reloading it first in development mode if its files have changed.
+func (t *Theme) current() (*files, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.files != nil && !t.dev {
+		return t.files, nil
+	}
+
+	version, err := t.version()
+	if err != nil {
+		return nil, err
+	}
+	if t.files != nil && t.files.version == version {
+		return t.files, nil
+	}
+
+	f, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	f.version = version
+	t.files = f
+
+	return f, nil
+}
+
+// version returns a string which changes when theme files are added, removed or modified.
+func (t *Theme) version() (string, error) {
+	h := sha256.New()
+
+	err := fs.WalkDir(t.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(h, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// load reads and parses theme files.
+func (t *Theme) load() (*files, error) {
+	f := &files{
+		pages:    make(map[string]*template.Template),
+		messages: make(map[string]map[string]string),
+		assets:   make(map[string]string),
+	}
+
+	base := template.New("").Funcs(t.funcs(context.Background(), f))
+	pages := make(map[string][]byte)
+
+	err := fs.WalkDir(t.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		dir := p
+		if i := strings.IndexByte(p, '/'); i > 0 {
+			dir = p[:i]
+		}
+		ext := path.Ext(p)
+		name := strings.TrimSuffix(p, ext)
+
+		switch {
+		case (dir == layoutsDir || dir == partialsDir) && ext == ".html":
+			b, err := fs.ReadFile(t.fsys, p)
+			if err != nil {
+				return err
+			}
+			_, err = base.New(name).Parse(string(b))
+			return err
+		case dir == pagesDir && ext == ".html":
+			b, err := fs.ReadFile(t.fsys, p)
+			pages[name] = b
+			return err
+		case dir == i18nDir && (ext == ".yaml" || ext == ".yml"):
+			b, err := fs.ReadFile(t.fsys, p)
+			if err != nil {
+				return err
+			}
+			messages := make(map[string]string)
+			if err = yaml.Unmarshal(b, &messages); err != nil {
+				return fmt.Errorf("%s: %v", p, err)
+			}
+			f.messages[path.Base(name)] = messages
+		case dir == assetsDir:
+			b, err := fs.ReadFile(t.fsys, p)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(b)
+			f.assets[strings.TrimPrefix(p, assetsDir+"/")] = hex.EncodeToString(sum[:4])
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, b := range pages {
+		tpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tpl.New(name).Parse(string(b)); err != nil {
+			return nil, err
+		}
+		f.pages[strings.TrimPrefix(name, pagesDir+"/")] = tpl
+	}
+
+	return f, nil
+}
+
+// funcs returns the helper functions available to templates rendered with the context.
+func (t *Theme) funcs(ctx context.Context, f *files) template.FuncMap {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	if locale == "" {
+		locale = i18n.LocaleFrom(ctx)
+	}
+	if locale == "" {
+		locale = t.locale
+	}
+
+	return template.FuncMap{
+		"permalink": func(uuid string) (string, error) {
+			if t.linker == nil {
+				return "", nil
+			}
+
+			p, err := t.linker.Path(ctx, uuid)
+			if errors.Is(err, permalink.ErrNotFound) {
+				return "", nil
+			}
+
+			return p, err
+		},
+		"asset": func(name string) string {
+			name = strings.TrimPrefix(name, "/")
+			if v, ok := f.assets[name]; ok {
+				return t.assetPath + name + "?v=" + v
+			}
+
+			return t.assetPath + name
+		},
+		"t": func(key string, args ...interface{}) string {
+			msg := t.translate(f, locale, key)
+			if len(args) > 0 {
+				return fmt.Sprintf(msg, args...)
+			}
+
+			return msg
+		},
+		"locale": func() string {
+			return locale
+		},
+		"safeHTML": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+	}
+}
+
+// translate returns a message in the locale, falling back to its language, then to the default locale and to the
+// key itself.
+func (t *Theme) translate(f *files, locale, key string) string {
+	candidates := []string{locale}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, t.locale)
+
+	for _, l := range candidates {
+		if msg, ok := f.messages[l][key]; ok {
+			return msg
+		}
+	}
+
+	return key
+}
+
+// isEmpty reports whether a template has no content besides template definitions and white space.
+func isEmpty(tree *parse.Tree) bool {
+	if tree == nil || tree.Root == nil {
+		return true
+	}
+
+	for _, n := range tree.Root.Nodes {
+		if tn, ok := n.(*parse.TextNode); !ok || strings.TrimSpace(string(tn.Text)) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// New creates a new theme from the directory set by the theme.dir setting or from the file system, e.g. an embed.FS
+// subtree, if the setting is empty. The linker resolving permalinks may be nil.
+func New(cfg config.Config, fsys fs.FS, linker Linker) (*Theme, error) {
+	cfg.SetDefault("theme.assetPath", DftAssetPath)
+	cfg.SetDefault("theme.assetMaxAge", DftAssetMaxAge)
+	cfg.SetDefault("theme.layout", DftLayout)
+	cfg.SetDefault("theme.locale", DftLocale)
+
+	if dir := cfg.GetString("theme.dir"); dir != "" {
+		fsys = os.DirFS(dir)
+	}
+	if fsys == nil {
+		return nil, errors.New("no theme directory configured")
+	}
+
+	t := &Theme{
+		fsys:      fsys,
+		linker:    linker,
+		dev:       cfg.GetBool("theme.dev"),
+		assetPath: "/" + strings.Trim(cfg.GetString("theme.assetPath"), "/") + "/",
+		maxAge:    cfg.GetDuration("theme.assetMaxAge"),
+		layout:    cfg.GetString("theme.layout"),
+		locale:    cfg.GetString("theme.locale"),
+	}
+
+	if _, err := t.current(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
@@ -0,0 +1,114 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package theme_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/i18n"
+	"ampho.xyz/core/permalink"
+	"ampho.xyz/core/theme"
+)
+
+const aboutUUID = "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"
+
+type linker map[string]string
+
+func (l linker) Path(_ context.Context, entityUUID string) (string, error) {
+	if p, ok := l[entityUUID]; ok {
+		return p, nil
+	}
+
+	return "", permalink.ErrNotFound
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/default.html": {Data: []byte(`<html lang="{{locale}}"><title>{{block "title" .}}Site{{end}}</title>` +
+			`<link href="{{asset "css/site.css"}}">{{template "partials/header" .}}{{block "main" .}}{{end}}</html>`)},
+		"partials/header.html": {Data: []byte(`<a href="{{permalink .About}}">{{t "about"}}</a>`)},
+		"pages/index.html": {Data: []byte(`{{define "title"}}{{t "welcome" .Name}}{{end}}` + "\n" +
+			`{{define "main"}}<p>{{.Body | safeHTML}}</p>{{end}}`)},
+		"pages/plain.html":    {Data: []byte(`<p>{{.Name}}</p>`)},
+		"i18n/en.yaml":        {Data: []byte("about: About\nwelcome: Welcome, %s\n")},
+		"i18n/de.yaml":        {Data: []byte("about: Über uns\n")},
+		"assets/css/site.css": {Data: []byte("body {}"), ModTime: time.Now()},
+	}
+}
+
+type data struct {
+	Name  string
+	Body  string
+	About string
+}
+
+func render(t *testing.T, th *theme.Theme, ctx context.Context, name string) string {
+	var buf bytes.Buffer
+	require.NoError(t, th.ExecuteTemplate(ctx, &buf, name, data{"<Ann>", "<b>hi</b>", aboutUUID}))
+
+	return buf.String()
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	th, err := theme.New(config.NewTesting("theme"), testFS(), linker{aboutUUID: "/about"})
+	require.NoError(t, err)
+
+	require.Equal(t, `<html lang="en"><title>Welcome, &lt;Ann&gt;</title>`+
+		`<link href="/assets/css/site.css?v=62368a1a"><a href="/about">About</a><p><b>hi</b></p></html>`,
+		render(t, th, context.Background(), "index"))
+
+	// Translations fall back to the language and to the default locale
+	require.Contains(t, render(t, th, theme.WithLocale(context.Background(), "de-AT"), "index"),
+		`<html lang="de-AT"><title>Welcome, &lt;Ann&gt;</title>`)
+	require.Contains(t, render(t, th, theme.WithLocale(context.Background(), "de-AT"), "index"), "Über uns")
+	require.Contains(t, render(t, th, i18n.WithLocale(context.Background(), "de-AT"), "index"), "Über uns")
+
+	require.Equal(t, "<p>&lt;Ann&gt;</p>", render(t, th, context.Background(), "plain"))
+
+	require.True(t, th.Has("plain"))
+	require.False(t, th.Has("missing"))
+	require.ErrorIs(t, th.ExecuteTemplate(context.Background(), &bytes.Buffer{}, "missing", nil), theme.ErrPageNotFound)
+}
+
+func TestReload(t *testing.T) {
+	fsys := testFS()
+	cfg := config.NewTesting("theme")
+	cfg.Set("theme.dev", true)
+
+	th, err := theme.New(cfg, fsys, nil)
+	require.NoError(t, err)
+	require.Equal(t, "<p>&lt;Ann&gt;</p>", render(t, th, context.Background(), "plain"))
+
+	fsys["pages/plain.html"] = &fstest.MapFile{Data: []byte(`<div>{{.Name}}</div>`), ModTime: time.Now()}
+	require.Equal(t, "<div>&lt;Ann&gt;</div>", render(t, th, context.Background(), "plain"))
+}
+
+func TestAssets(t *testing.T) {
+	th, err := theme.New(config.NewTesting("theme"), testFS(), nil)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	th.Mount(r)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets/css/site.css?v=62368a1a", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "body {}", rr.Body.String())
+	require.Equal(t, "public, max-age=31536000, immutable", rr.Header().Get("Cache-Control"))
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets/missing.css", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
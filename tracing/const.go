@@ -0,0 +1,12 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package tracing
+
+const (
+	DftExporter    = ExporterNone     // span exporter: none, stdout or otlp
+	DftServiceName = "ampho"          // service name resource attribute
+	DftSampleRatio = 1.0              // ratio of root spans sampled
+	DftEndpoint    = "localhost:4318" // OTLP/HTTP collector endpoint
+)
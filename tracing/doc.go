@@ -0,0 +1,7 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package tracing provides OpenTelemetry distributed tracing of HTTP requests. Database operations are traced by the
+// database package using the tracer provider installed here.
+package tracing
@@ -0,0 +1,194 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/httputil"
+)
+
+// TracerName is the name of the tracer of HTTP requests.
+const TracerName = "ampho.xyz/core/tracing"
+
+// Exporters.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Provider is a tracer provider installed globally, see otel.GetTracerProvider.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// Shutdown exports the remaining spans and stops the provider. It does nothing if tracing is disabled.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+
+	return p.tp.Shutdown(ctx)
+}
+
+// Middleware starts a server span of a request continuing the trace of the incoming traceparent header. The span is
+// named by the request method until RouteMiddleware names it by the matched route.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := otel.Tracer(TracerName).Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", r)...),
+		)
+		defer span.End()
+
+		sw := httputil.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		status := sw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+	})
+}
+
+// RouteMiddleware names the span of a request by its method and route template, e.g. GET /items/{type}, so spans of
+// the same endpoint are grouped. It is a router middleware, since the route is known after matching only.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + tpl)
+				span.SetAttributes(semconv.HTTPRouteKey.String(tpl))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(TracerName).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(0, trace.SpanKindClient))
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
+
+	return resp, nil
+}
+
+// Transport returns an HTTP transport starting client spans of outbound requests and passing the trace context on in
+// the traceparent header. If base is nil, http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base}
+}
+
+// NewWithExporter creates a tracer provider exporting spans with an exporter, e.g. tracetest.InMemoryExporter in
+// tests, and installs it globally. Spans are exported synchronously if sync is true, in batches otherwise.
+func NewWithExporter(cfg config.Config, exp sdktrace.SpanExporter, sync bool) *Provider {
+	cfg.SetDefault("tracing.serviceName", DftServiceName)
+	cfg.SetDefault("tracing.sampleRatio", DftSampleRatio)
+
+	export := sdktrace.WithBatcher(exp)
+	if sync {
+		export = sdktrace.WithSyncer(exp)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		export,
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.GetString("tracing.serviceName")))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.GetFloat("tracing.sampleRatio")))),
+	)
+
+	otel.SetTracerProvider(tp)
+	setPropagator()
+
+	return &Provider{tp}
+}
+
+// setPropagator installs the W3C trace context and baggage propagator globally.
+func setPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+}
+
+// New creates a tracer provider exporting spans with the exporter set by tracing.exporter and installs it globally.
+// If the exporter is none, spans are not recorded, but the trace context is still propagated.
+//
+// The OTLP exporter sends spans over HTTP to tracing.endpoint, using TLS unless tracing.insecure is set.
+func New(cfg config.Config) (*Provider, error) {
+	cfg.SetDefault("tracing.exporter", DftExporter)
+	cfg.SetDefault("tracing.endpoint", DftEndpoint)
+	cfg.SetDefault("tracing.insecure", false)
+
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+
+	switch e := cfg.GetString("tracing.exporter"); e {
+	case ExporterNone, "":
+		setPropagator()
+		return &Provider{}, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.GetString("tracing.endpoint"))}
+		if cfg.GetBool("tracing.insecure") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithExporter(cfg, exp, false), nil
+}
@@ -0,0 +1,129 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/tracing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	p := tracing.NewWithExporter(config.NewTesting("hello"), exp, true)
+	t.Cleanup(func() { _ = p.Shutdown(context.Background()) })
+
+	return exp
+}
+
+func attr(attrs []attribute.KeyValue, key string) attribute.Value {
+	for _, a := range attrs {
+		if string(a.Key) == key {
+			return a.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	exp := newProvider(t)
+
+	r := mux.NewRouter()
+	r.Use(tracing.RouteMiddleware)
+	r.HandleFunc("/hello/{name}", func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, trace.SpanContextFromContext(r.Context()).IsValid())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h := tracing.Middleware(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello/world", nil)
+	req.Header.Set("traceparent", traceparent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /hello/{name}", spans[0].Name)
+	require.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	require.True(t, spans[0].Parent.IsRemote())
+	require.Equal(t, "/hello/{name}", attr(spans[0].Attributes, "http.route").AsString())
+	require.Equal(t, int64(500), attr(spans[0].Attributes, "http.status_code").AsInt64())
+	require.Equal(t, codes.Error, spans[0].Status.Code)
+
+	// Not matching any route
+	exp.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/missing", nil))
+
+	spans = exp.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "HTTP POST", spans[0].Name)
+	require.False(t, spans[0].Parent.IsValid())
+	require.Equal(t, int64(404), attr(spans[0].Attributes, "http.status_code").AsInt64())
+}
+
+func TestTransport(t *testing.T) {
+	exp := newProvider(t)
+
+	headers := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	// Outbound request made while serving an inbound one
+	h := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := (&http.Client{Transport: tracing.Transport(nil)}).Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", traceparent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+
+	client, server := spans[0], spans[1]
+	require.Equal(t, trace.SpanKindClient, client.SpanKind)
+	require.Equal(t, server.SpanContext.SpanID(), client.Parent.SpanID())
+	require.Equal(t, int64(200), attr(client.Attributes, "http.status_code").AsInt64())
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+client.SpanContext.SpanID().String()+"-01", <-headers)
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.NewTesting("hello")
+
+	p, err := tracing.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	cfg.Set("tracing.exporter", "stdout")
+	p, err = tracing.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	cfg.Set("tracing.exporter", "zipkin")
+	_, err = tracing.New(cfg)
+	require.Error(t, err)
+}
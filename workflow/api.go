@@ -0,0 +1,130 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package workflow
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/httputil"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/security"
+)
+
+// API provides HTTP handlers for workflows. The user applying transitions is taken from the request context, see
+// security.Middleware.
+type API struct {
+	engine *Engine
+}
+
+// Mount registers API handlers on a router. Usually it is a subrouter with a path prefix:
+//
+//	api.Mount(svc.Router().PathPrefix("/workflows").Subrouter())
+func (a *API) Mount(r *mux.Router) {
+	r.HandleFunc("", a.listWorkflows).Methods(http.MethodGet)
+	r.HandleFunc("/{workflow}/{uuid}", a.getState).Methods(http.MethodGet)
+	r.HandleFunc("/{workflow}/{uuid}/history", a.getHistory).Methods(http.MethodGet)
+	r.HandleFunc("/{workflow}/{uuid}/{transition}", a.applyTransition).Methods(http.MethodPost)
+}
+
+func (a *API) listWorkflows(w http.ResponseWriter, _ *http.Request) {
+	workflows := a.engine.Workflows()
+	if workflows == nil {
+		workflows = []*Workflow{}
+	}
+
+	_, _ = httputil.WriteJSON(w, workflows)
+}
+
+// getState returns the state of an entity along with the transitions the user may apply.
+func (a *API) getState(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	wf, err := a.engine.Workflow(vars["workflow"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !database.IsUUID(vars["uuid"]) {
+		_, _ = httputil.WriteError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	state, err := a.engine.State(r.Context(), wf.Name, vars["uuid"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSON(w, map[string]interface{}{
+		"workflow":    wf.Name,
+		"state":       state,
+		"transitions": wf.Available(state, security.PrincipalFrom(r.Context())),
+	})
+}
+
+func (a *API) getHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	history, err := a.engine.History(r.Context(), vars["uuid"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	records := make([]*Record, 0, len(history))
+	for _, rec := range history {
+		if rec.Workflow == vars["workflow"] {
+			records = append(records, rec)
+		}
+	}
+
+	_, _ = httputil.WriteJSON(w, records)
+}
+
+// applyTransition applies a transition. The request body may contain a comment: {"comment": "..."}.
+func (a *API) applyTransition(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if err := httputil.ReadJSON(w, r, &req); err != nil && err != io.EOF {
+		_, _ = httputil.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	rec, err := a.engine.Apply(r.Context(), vars["workflow"], vars["uuid"], vars["transition"],
+		security.PrincipalFrom(r.Context()), req.Comment)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, _ = httputil.WriteJSONStatus(w, http.StatusCreated, rec)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrEntityNotFound), errors.Is(err, ErrUnknownTransition):
+		_, _ = httputil.WriteError(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, ErrInvalidTransition):
+		_, _ = httputil.WriteError(w, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, ErrForbidden):
+		_, _ = httputil.WriteError(w, http.StatusForbidden, err.Error(), nil)
+	default:
+		logger.FromContext(r.Context()).Error("workflow API error", "error", err)
+		_, _ = httputil.WriteError(w, http.StatusInternalServerError, "", nil)
+	}
+}
+
+// NewAPI creates a new workflow API.
+func NewAPI(engine *Engine) *API {
+	return &API{engine}
+}
@@ -0,0 +1,15 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package workflow
+
+import "time"
+
+const (
+	DftWebhookTimeout = time.Second * 10 // how long a webhook call may take
+)
+
+const (
+	EventTransition = "workflow.transition" // a transition was applied, the payload is *Record
+)
@@ -0,0 +1,32 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+// Package workflow provides editorial workflows: state machines moving entities through states like review or
+// archived by named transitions.
+//
+// Workflows are defined in the configuration. A workflow applies to entities of its content types, starts in its
+// initial state and may only change state by its transitions. A transition may be restricted to users with certain
+// roles, see security.Middleware, and may notify subscribers of the event bus and call a webhook:
+//
+//	workflow:
+//	  definitions:
+//	    editorial:
+//	      types: [article]
+//	      initial: draft
+//	      states: [draft, review, approved, archived]
+//	      publish: approved
+//	      transitions:
+//	        submit:
+//	          from: [draft]
+//	          to: review
+//	        approve:
+//	          from: [review]
+//	          to: approved
+//	          roles: [legal]
+//	          notify: true
+//	          webhook: https://hooks.example.com/approved
+//
+// Workflows apply to content items only. An item reaching the publish state of its workflow, if there is one, is
+// published by content.Scheduler. Every applied transition is recorded, so the full history of an entity is kept.
+package workflow
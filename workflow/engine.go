@@ -0,0 +1,280 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/trace"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/database"
+	"ampho.xyz/core/event"
+	"ampho.xyz/core/logger"
+	"ampho.xyz/core/requestid"
+	"ampho.xyz/core/security"
+	"ampho.xyz/core/tracing"
+)
+
+const recordColumns = "created_at, entity_uuid::text AS entity_uuid, workflow, transition, from_state, to_state, " +
+	"actor, comment"
+
+// Record is an applied transition.
+type Record struct {
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	EntityUUID string    `db:"entity_uuid" json:"entity"`
+	Workflow   string    `json:"workflow"`
+	Transition string    `json:"transition"`
+	From       string    `db:"from_state" json:"from"`
+	To         string    `db:"to_state" json:"to"`
+	Actor      string    `json:"actor"`
+	Comment    string    `json:"comment"`
+}
+
+// Engine applies workflow transitions to entities and keeps their states and history.
+type Engine struct {
+	db        *database.Database
+	bus       *event.Bus
+	client    *http.Client
+	workflows []*Workflow
+}
+
+// Workflows returns all workflows.
+func (e *Engine) Workflows() []*Workflow {
+	return e.workflows
+}
+
+// Workflow returns a workflow by its name.
+func (e *Engine) Workflow(name string) (*Workflow, error) {
+	for _, w := range e.workflows {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// ForType returns the workflow of a content type or nil if the type has none.
+func (e *Engine) ForType(typ string) *Workflow {
+	for _, w := range e.workflows {
+		if contains(w.Types, typ) {
+			return w
+		}
+	}
+
+	return nil
+}
+
+// State returns the state of an entity in a workflow. An entity which has not been moved yet is in the initial state.
+// If the entity is not a content item of a type with the workflow, it returns ErrEntityNotFound.
+func (e *Engine) State(ctx context.Context, workflow, entityUUID string) (string, error) {
+	w, err := e.Workflow(workflow)
+	if err != nil {
+		return "", err
+	}
+	if !database.IsUUID(entityUUID) {
+		return "", fmt.Errorf("invalid entity UUID %q", entityUUID)
+	}
+
+	var typ, state pgtype.Text
+	err = e.db.QueryRow(ctx, "SELECT i.type, s.state FROM content_items i LEFT JOIN workflow_states s "+
+		"ON s.entity_uuid = i.uuid AND s.workflow = $2 WHERE i.uuid = $1 AND i.deleted_at IS NULL", entityUUID,
+		workflow).Scan(&typ, &state)
+	if err == pgx.ErrNoRows || err == nil && e.ForType(typ.String) != w {
+		return "", ErrEntityNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	if state.Status != pgtype.Present {
+		return w.Initial, nil
+	}
+
+	return state.String, nil
+}
+
+// Apply applies a transition to an entity on behalf of a user, who may be nil if the transition requires no roles.
+// The entity must be a content item of a type with the workflow, otherwise ErrEntityNotFound is returned. If the
+// transition leads to the publish state of the workflow, the item is scheduled to be published right away, see
+// content.Scheduler. On success the transition hooks are run.
+func (e *Engine) Apply(ctx context.Context, workflow, entityUUID, transition string, p *security.Principal,
+	comment string) (*Record, error) {
+	w, err := e.Workflow(workflow)
+	if err != nil {
+		return nil, err
+	}
+	if !database.IsUUID(entityUUID) {
+		return nil, fmt.Errorf("invalid entity UUID %q", entityUUID)
+	}
+
+	var (
+		t   *Transition
+		rec = &Record{}
+	)
+
+	err = e.db.BeginFunc(ctx, database.ReplicaTypeRW, func(tx pgx.Tx) error {
+		var typ string
+		err := tx.QueryRow(ctx, "SELECT type FROM content_items WHERE uuid = $1 AND deleted_at IS NULL FOR SHARE",
+			entityUUID).Scan(&typ)
+		if err == pgx.ErrNoRows || err == nil && e.ForType(typ) != w {
+			return ErrEntityNotFound
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO workflow_states (entity_uuid, workflow, state) VALUES ($1, $2, $3) "+
+			"ON CONFLICT DO NOTHING", entityUUID, workflow, w.Initial)
+		if err != nil {
+			return err
+		}
+
+		var state string
+		err = tx.QueryRow(ctx, "SELECT state FROM workflow_states WHERE entity_uuid = $1 AND workflow = $2 "+
+			"FOR UPDATE", entityUUID, workflow).Scan(&state)
+		if err != nil {
+			return err
+		}
+
+		if t, err = w.Check(state, transition, p); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE workflow_states SET state = $3, updated_at = now() "+
+			"WHERE entity_uuid = $1 AND workflow = $2", entityUUID, workflow, t.To)
+		if err != nil {
+			return err
+		}
+
+		if w.Publish != "" && t.To == w.Publish {
+			// An expire time which has passed would expire the item as soon as it is published, so it is dropped
+			_, err = tx.Exec(ctx, "UPDATE content_items SET status = 'scheduled', publish_at = now(), "+
+				"expire_at = CASE WHEN expire_at > now() THEN expire_at END, updated_at = now() "+
+				"WHERE uuid = $1 AND status <> 'published'", entityUUID)
+			if err != nil {
+				return err
+			}
+		}
+
+		actor := ""
+		if p != nil {
+			actor = p.Subject
+		}
+
+		return pgxscan.Get(ctx, tx, rec, "INSERT INTO workflow_transitions (entity_uuid, workflow, transition, "+
+			"from_state, to_state, actor, comment) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+recordColumns,
+			entityUUID, workflow, t.Name, state, t.To, actor, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.runHooks(ctx, t, rec)
+
+	return rec, nil
+}
+
+// History returns transitions applied to an entity in all workflows, the oldest first.
+func (e *Engine) History(ctx context.Context, entityUUID string) ([]*Record, error) {
+	if !database.IsUUID(entityUUID) {
+		return nil, nil
+	}
+
+	var r []*Record
+
+	err := e.db.SelectAll(ctx, &r, "SELECT "+recordColumns+" FROM workflow_transitions WHERE entity_uuid = $1 "+
+		"ORDER BY id", entityUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// runHooks publishes EventTransition and posts the record to the webhook of a transition. The webhook is called in
+// the background with the request ID, failures are logged.
+func (e *Engine) runHooks(ctx context.Context, t *Transition, rec *Record) {
+	if t.Notify && e.bus != nil {
+		e.bus.Publish(ctx, EventTransition, rec)
+	}
+
+	if t.Webhook != "" {
+		// The request context is done when the request is served, only its ID, span and logger are passed on
+		hookCtx := requestid.WithID(context.Background(), requestid.FromContext(ctx))
+		hookCtx = trace.ContextWithSpanContext(hookCtx, trace.SpanContextFromContext(ctx))
+		hookCtx = logger.WithContext(hookCtx, logger.FromContext(ctx))
+
+		go func() {
+			if err := e.callWebhook(hookCtx, t.Webhook, rec); err != nil {
+				logger.FromContext(hookCtx).Error("workflow webhook failed", "workflow", rec.Workflow,
+					"transition", rec.Transition, "error", err)
+			}
+		}()
+	}
+}
+
+func (e *Engine) callWebhook(ctx context.Context, url string, rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// New creates a new workflow engine with workflows defined in the configuration. Events are published to the bus,
+// which may be nil.
+func New(cfg config.Config, db *database.Database, bus *event.Bus) (*Engine, error) {
+	cfg.SetDefault("workflow.webhookTimeout", DftWebhookTimeout)
+
+	workflows, err := parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string)
+	for _, w := range workflows {
+		for _, t := range w.Types {
+			if other, ok := types[t]; ok {
+				return nil, fmt.Errorf("content type %s belongs to workflows %s and %s", t, other, w.Name)
+			}
+			types[t] = w.Name
+		}
+	}
+
+	return &Engine{
+		db:  db,
+		bus: bus,
+		client: &http.Client{
+			Timeout:   cfg.GetDuration("workflow.webhookTimeout"),
+			Transport: requestid.Transport(tracing.Transport(nil)),
+		},
+		workflows: workflows,
+	}, nil
+}
@@ -0,0 +1,167 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/security"
+)
+
+var (
+	// ErrNotFound is returned when a workflow does not exist.
+	ErrNotFound = errors.New("workflow not found")
+
+	// ErrUnknownTransition is returned when a workflow has no transition with a given name.
+	ErrUnknownTransition = errors.New("unknown transition")
+
+	// ErrInvalidTransition is returned when a transition cannot be applied in the current state.
+	ErrInvalidTransition = errors.New("transition is not allowed in the current state")
+
+	// ErrForbidden is returned when a user has none of the roles a transition requires.
+	ErrForbidden = errors.New("transition is not permitted")
+
+	// ErrEntityNotFound is returned when an entity does not exist or its type has another workflow.
+	ErrEntityNotFound = errors.New("entity not found in workflow")
+)
+
+// Transition moves an entity from one of the states to another one.
+type Transition struct {
+	Name    string   `json:"name"`
+	From    []string `json:"from"`
+	To      string   `json:"to"`
+	Roles   []string `json:"roles,omitempty"`  // roles permitted to apply the transition, anyone if empty
+	Notify  bool     `json:"notify,omitempty"` // whether EventTransition is published
+	Webhook string   `json:"-"`                // URL the transition record is posted to
+}
+
+// Permits reports whether a user may apply the transition.
+func (t *Transition) Permits(p *security.Principal) bool {
+	return len(t.Roles) == 0 || p.HasRole(t.Roles...)
+}
+
+// Workflow is a state machine.
+type Workflow struct {
+	Name        string        `json:"name"`
+	Types       []string      `json:"types"`
+	Initial     string        `json:"initial"`
+	States      []string      `json:"states"`
+	Publish     string        `json:"publish,omitempty"` // state which publishes entities reaching it, if any
+	Transitions []*Transition `json:"transitions"`
+}
+
+// Transition returns a transition by its name or nil.
+func (w *Workflow) Transition(name string) *Transition {
+	for _, t := range w.Transitions {
+		if t.Name == name {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// Check returns the transition by its name if it can be applied by the user in the state.
+func (w *Workflow) Check(state, name string, p *security.Principal) (*Transition, error) {
+	t := w.Transition(name)
+	if t == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTransition, name)
+	}
+	if !contains(t.From, state) {
+		return nil, fmt.Errorf("%w: %s from %s", ErrInvalidTransition, name, state)
+	}
+	if !t.Permits(p) {
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, name)
+	}
+
+	return t, nil
+}
+
+// Available returns the transitions the user may apply in the state.
+func (w *Workflow) Available(state string, p *security.Principal) []*Transition {
+	r := make([]*Transition, 0)
+	for _, t := range w.Transitions {
+		if contains(t.From, state) && t.Permits(p) {
+			r = append(r, t)
+		}
+	}
+
+	return r
+}
+
+// validate checks the workflow definition is consistent.
+func (w *Workflow) validate() error {
+	if !contains(w.States, w.Initial) {
+		return fmt.Errorf("workflow %s: unknown initial state %q", w.Name, w.Initial)
+	}
+	if w.Publish != "" && !contains(w.States, w.Publish) {
+		return fmt.Errorf("workflow %s: unknown publish state %q", w.Name, w.Publish)
+	}
+
+	for _, t := range w.Transitions {
+		if len(t.From) == 0 {
+			return fmt.Errorf("workflow %s: transition %s has no source states", w.Name, t.Name)
+		}
+		states := append([]string{t.To}, t.From...)
+		for _, s := range states {
+			if !contains(w.States, s) {
+				return fmt.Errorf("workflow %s: transition %s refers to unknown state %q", w.Name, t.Name, s)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parse reads workflow definitions from the configuration.
+func parse(cfg config.Config) ([]*Workflow, error) {
+	var r []*Workflow
+
+	for name := range cfg.GetStringMap("workflow.definitions") {
+		key := "workflow.definitions." + name
+		w := &Workflow{
+			Name:    name,
+			Types:   cfg.GetStringSlice(key + ".types"),
+			Initial: cfg.GetString(key + ".initial"),
+			States:  cfg.GetStringSlice(key + ".states"),
+			Publish: cfg.GetString(key + ".publish"),
+		}
+
+		for tName := range cfg.GetStringMap(key + ".transitions") {
+			tKey := key + ".transitions." + tName
+			w.Transitions = append(w.Transitions, &Transition{
+				Name:    tName,
+				From:    cfg.GetStringSlice(tKey + ".from"),
+				To:      cfg.GetString(tKey + ".to"),
+				Roles:   cfg.GetStringSlice(tKey + ".roles"),
+				Notify:  cfg.GetBool(tKey + ".notify"),
+				Webhook: cfg.GetString(tKey + ".webhook"),
+			})
+		}
+		sort.Slice(w.Transitions, func(i, j int) bool { return w.Transitions[i].Name < w.Transitions[j].Name })
+
+		if err := w.validate(); err != nil {
+			return nil, err
+		}
+
+		r = append(r, w)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name < r[j].Name })
+
+	return r, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
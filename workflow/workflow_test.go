@@ -0,0 +1,209 @@
+// Author:  Alexander Shepetko
+// Email:   a@shepetko.com
+// License: MIT
+
+package workflow_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"ampho.xyz/core/config"
+	"ampho.xyz/core/content"
+	"ampho.xyz/core/databasetest"
+	"ampho.xyz/core/event"
+	"ampho.xyz/core/security"
+	"ampho.xyz/core/workflow"
+)
+
+func newConfig(definitions map[string]interface{}) config.Config {
+	cfg := config.NewTesting("workflow")
+	cfg.Set("workflow.definitions", definitions)
+
+	return cfg
+}
+
+func editorial() map[string]interface{} {
+	return map[string]interface{}{
+		"editorial": map[string]interface{}{
+			"types":   []string{"article"},
+			"initial": "draft",
+			"states":  []string{"draft", "review", "approved", "archived"},
+			"transitions": map[string]interface{}{
+				"submit":  map[string]interface{}{"from": []string{"draft"}, "to": "review"},
+				"approve": map[string]interface{}{"from": []string{"review"}, "to": "approved", "roles": []string{"legal"}},
+				"archive": map[string]interface{}{
+					"from": []string{"draft", "approved"}, "to": "archived", "roles": []string{"editor", "legal"},
+				},
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := workflow.New(newConfig(editorial()), nil, nil)
+	require.NoError(t, err)
+	require.Len(t, e.Workflows(), 1)
+
+	w, err := e.Workflow("editorial")
+	require.NoError(t, err)
+	require.Equal(t, "draft", w.Initial)
+	require.Equal(t, []string{"article"}, w.Types)
+	require.Len(t, w.Transitions, 3)
+	require.Equal(t, "approve", w.Transitions[0].Name)
+	require.Equal(t, w, e.ForType("article"))
+	require.Nil(t, e.ForType("page"))
+
+	_, err = e.Workflow("legal")
+	require.ErrorIs(t, err, workflow.ErrNotFound)
+}
+
+func TestCheck(t *testing.T) {
+	e, err := workflow.New(newConfig(editorial()), nil, nil)
+	require.NoError(t, err)
+	w, _ := e.Workflow("editorial")
+
+	editor := &security.Principal{Subject: "ann", Roles: []string{"editor"}}
+	lawyer := &security.Principal{Subject: "bob", Roles: []string{"legal"}}
+
+	tr, err := w.Check("draft", "submit", nil)
+	require.NoError(t, err)
+	require.Equal(t, "review", tr.To)
+
+	_, err = w.Check("draft", "publish", editor)
+	require.ErrorIs(t, err, workflow.ErrUnknownTransition)
+	_, err = w.Check("draft", "approve", lawyer)
+	require.ErrorIs(t, err, workflow.ErrInvalidTransition)
+	_, err = w.Check("review", "approve", editor)
+	require.ErrorIs(t, err, workflow.ErrForbidden)
+	_, err = w.Check("review", "approve", nil)
+	require.ErrorIs(t, err, workflow.ErrForbidden)
+	_, err = w.Check("review", "approve", lawyer)
+	require.NoError(t, err)
+
+	names := func(ts []*workflow.Transition) []string {
+		r := make([]string, 0, len(ts))
+		for _, t := range ts {
+			r = append(r, t.Name)
+		}
+		return r
+	}
+	require.Equal(t, []string{"submit"}, names(w.Available("draft", nil)))
+	require.Equal(t, []string{"archive", "submit"}, names(w.Available("draft", editor)))
+	require.Equal(t, []string{}, names(w.Available("review", editor)))
+	require.Equal(t, []string{"approve"}, names(w.Available("review", lawyer)))
+}
+
+func TestInvalidDefinitions(t *testing.T) {
+	tt := map[string]map[string]interface{}{
+		"unknown initial": {"initial": "new", "states": []string{"draft"}},
+		"unknown target": {
+			"initial": "draft", "states": []string{"draft"},
+			"transitions": map[string]interface{}{"submit": map[string]interface{}{"from": []string{"draft"}, "to": "x"}},
+		},
+		"unknown source": {
+			"initial": "draft", "states": []string{"draft"},
+			"transitions": map[string]interface{}{"submit": map[string]interface{}{"from": []string{"x"}, "to": "draft"}},
+		},
+		"unknown publish state": {"initial": "draft", "states": []string{"draft"}, "publish": "published"},
+		"no source": {
+			"initial": "draft", "states": []string{"draft"},
+			"transitions": map[string]interface{}{"submit": map[string]interface{}{"to": "draft"}},
+		},
+	}
+
+	for name, def := range tt {
+		_, err := workflow.New(newConfig(map[string]interface{}{"w": def}), nil, nil)
+		require.Error(t, err, name)
+	}
+
+	// A type in two workflows
+	defs := editorial()
+	defs["legal"] = map[string]interface{}{"types": []string{"article"}, "initial": "new", "states": []string{"new"}}
+	_, err := workflow.New(newConfig(defs), nil, nil)
+	require.Error(t, err)
+}
+
+func TestAPI(t *testing.T) {
+	e, err := workflow.New(newConfig(editorial()), nil, nil)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	workflow.NewAPI(e).Mount(r.PathPrefix("/workflows").Subrouter())
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/workflows")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"name":"editorial"`)
+	require.NotContains(t, rec.Body.String(), "webhook")
+
+	uuid := "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/workflows/legal/"+uuid).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/workflows/editorial/123").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/workflows/legal/"+uuid+"/submit").Code)
+}
+
+func TestApply(t *testing.T) {
+	db := databasetest.New(t)
+	ctx := context.Background()
+
+	types := content.NewRegistry(db)
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "article", Fields: []content.Field{}}))
+	require.NoError(t, types.Create(ctx, &content.Type{Name: "page", Fields: []content.Field{}}))
+	store := content.NewStore(db)
+	article := &content.Item{Type: "article"}
+	require.NoError(t, store.Create(ctx, article))
+	page := &content.Item{Type: "page"}
+	require.NoError(t, store.Create(ctx, page))
+
+	defs := editorial()
+	defs["editorial"].(map[string]interface{})["publish"] = "approved"
+	e, err := workflow.New(newConfig(defs), db, nil)
+	require.NoError(t, err)
+
+	// Only items of the workflow types are moved
+	lawyer := &security.Principal{Subject: "bob", Roles: []string{"legal"}}
+	for _, uuid := range []string{page.GetUUID(), "6f1c2b4e-1d2a-4c3b-8e5f-0a1b2c3d4e5f"} {
+		_, err = e.State(ctx, "editorial", uuid)
+		require.ErrorIs(t, err, workflow.ErrEntityNotFound)
+		_, err = e.Apply(ctx, "editorial", uuid, "submit", lawyer, "")
+		require.ErrorIs(t, err, workflow.ErrEntityNotFound)
+	}
+
+	state, err := e.State(ctx, "editorial", article.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, "draft", state)
+
+	rec, err := e.Apply(ctx, "editorial", article.GetUUID(), "submit", nil, "ready")
+	require.NoError(t, err)
+	require.Equal(t, "review", rec.To)
+	item, err := store.Get(ctx, article.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, content.StatusDraft, item.Status)
+
+	// The publish state publishes the item
+	_, err = e.Apply(ctx, "editorial", article.GetUUID(), "approve", lawyer, "")
+	require.NoError(t, err)
+	require.NoError(t, content.NewScheduler(config.NewTesting("workflow"), db, event.NewBus()).Run(ctx))
+	item, err = store.Get(ctx, article.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, content.StatusPublished, item.Status)
+
+	state, err = e.State(ctx, "editorial", article.GetUUID())
+	require.NoError(t, err)
+	require.Equal(t, "approved", state)
+
+	history, err := e.History(ctx, article.GetUUID())
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+}